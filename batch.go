@@ -0,0 +1,188 @@
+package nutsdb
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrWriteBatchClosed is returned when writing to or flushing a WriteBatch that has already been flushed
+var ErrWriteBatchClosed = errors.New("write batch is closed")
+
+const (
+	// writeBatchMaxCount is the max number of entries of a tx of a WriteBatch
+	writeBatchMaxCount = 10000
+
+	// writeBatchAttempts is the number of times a tx of a WriteBatch is run while it conflicts with another tx
+	writeBatchAttempts = 10
+)
+
+type (
+	// WriteBatch writes many keys of the BPTree buckets through as many txs as needed, for bulk loading
+	// The entries are grouped in txs that fit in a segment and in the tx limits of the Options, and a filled tx
+	// is committed in the background while the next one is filled, so the batch is not atomic: the txs committed
+	// before an error are kept
+	// A WriteBatch must not be used by several goroutines at the same time
+	WriteBatch struct {
+		db		*DB
+		ops		[]*batchOp // the entries of the tx being filled
+		size	int64 // the size of the entries of ops
+		txs		chan []*batchOp // the filled txs, committed in order by commitTxs
+		done	chan struct{} // closed when commitTxs returns
+		errMu	sync.Mutex
+		err		error // the first error of the committed txs
+		closed	bool
+	}
+
+	// batchOp represents an entry written by a WriteBatch
+	batchOp struct {
+		bucket		string
+		key			[]byte
+		value		[]byte
+		ttl			uint32
+		flag		uint16
+		timestamp	uint64
+	}
+)
+
+// NewWriteBatch returns a new WriteBatch, Flush must be called when done
+func (db *DB) NewWriteBatch() *WriteBatch {
+	wb := &WriteBatch{
+		db:		db,
+		txs:	make(chan []*batchOp, 1),
+		done:	make(chan struct{}),
+	}
+
+	go wb.commitTxs()
+
+	return wb
+}
+
+// Put sets the value for a key in the bucket, like Tx.Put, the key and the value are copied
+// It returns the error of a tx committed before, if any, since the next txs are not committed anymore
+func (wb *WriteBatch) Put(bucket string, key, value []byte, ttl uint32) error {
+	return wb.add(bucket, key, value, ttl, DataSetFlag)
+}
+
+// Delete removes a key from the bucket, like Tx.Delete
+func (wb *WriteBatch) Delete(bucket string, key []byte) error {
+	return wb.add(bucket, key, nil, Persistent, DataDeleteFlag)
+}
+
+// Flush commits the remaining entries, waits for all the txs of the batch
+// and returns the first error of the batch
+func (wb *WriteBatch) Flush() error {
+	if wb.closed {
+		return ErrWriteBatchClosed
+	}
+
+	wb.closed = true
+
+	if len(wb.ops) > 0 {
+		wb.send()
+	}
+
+	close(wb.txs)
+	<-wb.done
+
+	return wb.error()
+}
+
+// add adds the entry to the tx being filled, sending the tx to commitTxs first if the entry does not fit in it
+func (wb *WriteBatch) add(bucket string, key, value []byte, ttl uint32, flag uint16) error {
+	if wb.closed {
+		return ErrWriteBatchClosed
+	}
+
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+
+	if err := wb.error(); err != nil {
+		return err
+	}
+
+	size := int64(DataEntryHeaderSize + len(key) + len(value) + len(bucket))
+	if size > wb.db.opt.SegmentSize {
+		return ErrKeyAndValSize
+	}
+
+	maxCount, maxSize := wb.limits()
+	if size > maxSize {
+		return ErrTxnTooBig
+	}
+
+	if len(wb.ops) > 0 && (wb.size+size > maxSize || len(wb.ops) >= maxCount) {
+		wb.send()
+	}
+
+	// the tx is committed in the background, so the caller may reuse the buffers once add returns
+	wb.ops = append(wb.ops, &batchOp{
+		bucket:		bucket,
+		key:		append([]byte(nil), key...),
+		value:		append([]byte(nil), value...),
+		ttl:		ttl,
+		flag:		flag,
+		timestamp:	uint64(time.Now().Unix()),
+	})
+	wb.size += size
+
+	return nil
+}
+
+// limits returns the max number and size of the entries of a tx of the batch
+func (wb *WriteBatch) limits() (maxCount int, maxSize int64) {
+	maxCount, maxSize = writeBatchMaxCount, wb.db.opt.SegmentSize
+
+	if n := wb.db.opt.MaxTxPendingCount; n > 0 && n < maxCount {
+		maxCount = n
+	}
+
+	if n := wb.db.opt.MaxTxPendingSize; n > 0 && n < maxSize {
+		maxSize = n
+	}
+
+	return
+}
+
+// send sends the tx being filled to commitTxs, it blocks while the previous tx sent is not committed yet
+func (wb *WriteBatch) send() {
+	wb.txs <- wb.ops
+	wb.ops = nil
+	wb.size = 0
+}
+
+// commitTxs commits the txs sent in order, the txs sent after an error are dropped
+func (wb *WriteBatch) commitTxs() {
+	defer close(wb.done)
+
+	for ops := range wb.txs {
+		if wb.error() != nil {
+			continue
+		}
+
+		err := wb.db.UpdateWithRetry(writeBatchAttempts, func(tx *Tx) error {
+			for _, op := range ops {
+				if err := tx.put(op.bucket, op.key, op.value, op.ttl, op.flag, op.timestamp, DataStrucctureBPTree); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			wb.errMu.Lock()
+			wb.err = err
+			wb.errMu.Unlock()
+		}
+	}
+}
+
+// error returns the first error of the committed txs
+func (wb *WriteBatch) error() error {
+	wb.errMu.Lock()
+	defer wb.errMu.Unlock()
+
+	return wb.err
+}
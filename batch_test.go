@@ -0,0 +1,154 @@
+package nutsdb
+
+import (
+	"fmt"
+	"testing"
+)
+
+// commitSeq returns the commit sequence of the db
+func commitSeq(db *DB) uint64 {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.seq
+}
+
+func TestWriteBatch_Split(t *testing.T) {
+	// every entry is 42+6+4+6 = 58 bytes
+	entrySize := int64(DataEntryHeaderSize + len("bucket") + len("k000") + len("v00000"))
+
+	tests := []struct {
+		name		string
+		maxCount	int
+		maxSize		int64
+		txs			uint64
+	}{
+		{"MaxTxPendingCount", 10, 0, 10},
+		{"MaxTxPendingSize", 0, 5 * entrySize, 19},
+		{"both", 7, 5*entrySize + 1, 19},
+		{"unlimited", 0, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := testOptions(t)
+			opt.MaxTxPendingCount, opt.MaxTxPendingSize = tt.maxCount, tt.maxSize
+			db := openDB(t, opt)
+
+			before := commitSeq(db)
+
+			// the buffers are reused, the batch must copy them
+			key, value := make([]byte, 4), make([]byte, 6)
+
+			wb := db.NewWriteBatch()
+			for i := 0; i < 95; i++ {
+				copy(key, fmt.Sprintf("k%03d", i))
+				copy(value, fmt.Sprintf("v%05d", i))
+
+				if err := wb.Put("bucket", key, value, Persistent); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := wb.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			if n := commitSeq(db) - before; n != tt.txs {
+				t.Errorf("the batch was committed in %d txs, want %d", n, tt.txs)
+			}
+
+			check := func(db *DB) {
+				view(t, db, func(tx *Tx) error {
+					for i := 0; i < 95; i++ {
+						if got, want := getValue(tx, "bucket", fmt.Sprintf("k%03d", i)), fmt.Sprintf("v%05d", i); got != want {
+							t.Fatalf("Get(k%03d) = %q, want %q", i, got, want)
+						}
+					}
+
+					return nil
+				})
+			}
+
+			check(db)
+			db = reopenDB(t, db)
+			check(db)
+		})
+	}
+}
+
+func TestWriteBatch_Errors(t *testing.T) {
+	opt := testOptions(t)
+	opt.MaxTxPendingSize = 100
+	db := openDB(t, opt)
+
+	update(t, db, func(tx *Tx) error {
+		return tx.Put("bucket", []byte("old"), []byte("1"), Persistent)
+	})
+
+	wb := db.NewWriteBatch()
+
+	if err := wb.Put("bucket", nil, []byte("v"), Persistent); err != ErrKeyEmpty {
+		t.Errorf("Put of an empty key returned %v, want ErrKeyEmpty", err)
+	}
+
+	if err := wb.Put("bucket", []byte("big"), make([]byte, 100), Persistent); err != ErrTxnTooBig {
+		t.Errorf("Put of an entry over MaxTxPendingSize returned %v, want ErrTxnTooBig", err)
+	}
+
+	if err := wb.Put("bucket", []byte("new"), []byte("1"), Persistent); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wb.Delete("bucket", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wb.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wb.Put("bucket", []byte("late"), nil, Persistent); err != ErrWriteBatchClosed {
+		t.Errorf("Put after Flush returned %v, want ErrWriteBatchClosed", err)
+	}
+
+	if err := wb.Flush(); err != ErrWriteBatchClosed {
+		t.Errorf("Flush after Flush returned %v, want ErrWriteBatchClosed", err)
+	}
+
+	view(t, db, func(tx *Tx) error {
+		if got := getValue(tx, "bucket", "old"); got != ErrNotFoundKey.Error() {
+			t.Errorf("Get of the deleted key = %q", got)
+		}
+
+		if got := getValue(tx, "bucket", "new"); got != "1" {
+			t.Errorf("Get(new) = %q, want 1", got)
+		}
+
+		return nil
+	})
+}
+
+func TestWriteBatch_CommitError(t *testing.T) {
+	opt := testOptions(t)
+	opt.MaxTxPendingCount = 2
+	db := openDB(t, opt)
+
+	failSyncs(db)
+
+	wb := db.NewWriteBatch()
+
+	// the first tx fails in the background, the next writes return its error
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = wb.Put("bucket", []byte(fmt.Sprint(i)), nil, Persistent)
+	}
+
+	if err != errSync {
+		t.Errorf("Put after a failed commit returned %v, want the sync error", err)
+	}
+
+	if err = wb.Flush(); err != errSync {
+		t.Errorf("Flush returned %v, want the sync error", err)
+	}
+}
@@ -37,12 +37,15 @@ type (
 	// BPTree records toot node and valid key number
 	BPTree struct {
 		root 			*Node
-		ValidKeyCount	int // the number of the key that not expired or deleted
+		ValidKeyCount	int		// the number of the keys that not deleted, the expired keys are counted until deleted
+		validSize		int64	// the size of the entries of the keys counted by ValidKeyCount
+		baseKeyVersion	uint64	// the versions of the new keys start above it, see DB.keyVersions
+		maxKeyVersion	uint64	// the greatest version of the keys of the tree
 		idxType			int
 	}
 
 	// Records records multi-records as result when is called Range or PrefixScan
-	Records map[string]*Records
+	Records map[string]*Record
 
 	// Node records keys and pointers and parent node
 	Node struct {
@@ -217,6 +220,21 @@ func (t *BPTree) PrefixScan(prefix []byte, limitNum int) (records Records, err e
 	return getRecordWrapper(numberFound, keys, pointers)
 }
 
+// All returns all the records of the tree in the order of the keys
+func (t *BPTree) All() (records []*Record) {
+	n := t.FindLeaf(nil)
+
+	for n != nil {
+		for i := 0; i < n.KeysNum; i++ {
+			records = append(records, n.pointers[i].(*Record))
+		}
+
+		n, _ = n.pointers[order-1].(*Node)
+	}
+
+	return
+}
+
 // Find retrieves record at the given key
 func (t *BPTree) Find(key []byte) (*Record, error) {
 	var (
@@ -244,6 +262,33 @@ func (t *BPTree) Find(key []byte) (*Record, error) {
 	return leaf.pointers[i].(*Record), nil
 }
 
+// ValidSize returns the size of the entries of the keys counted by ValidKeyCount
+func (t *BPTree) ValidSize() int64 {
+	return t.validSize
+}
+
+// updateMaxKeyVersion records the version of a key written to the tree
+func (t *BPTree) updateMaxKeyVersion(version uint64) {
+	if version > t.maxKeyVersion {
+		t.maxKeyVersion = version
+	}
+}
+
+// liveSize returns the size of the entry of the record made of the hint and the entry, 0 for a deletion
+// The value of a SetBit record is the patched value kept in memory, not the patch logged by the hint
+func liveSize(h *Hint, e *Entry) int64 {
+	if h.meta.Flag == DataDeleteFlag {
+		return 0
+	}
+
+	valueSize := int64(h.meta.valueSize)
+	if h.meta.Flag == DataSetBitFlag && e != nil {
+		valueSize = int64(len(e.Value))
+	}
+
+	return int64(DataEntryHeaderSize+h.meta.keySize+h.meta.bucketSize) + valueSize
+}
+
 // startNewTree returns a start new tree
 func (t *BPTree) startNewTree(key []byte, pointer *Record) error {
 	t.root = newLeaf()
@@ -257,6 +302,15 @@ func (t *BPTree) startNewTree(key []byte, pointer *Record) error {
 // Insert inserts record to the b+ tree
 // and if the key exists, update the record and the counter(if countFlag set true, it will start count)
 func (t *BPTree)Insert(key []byte, e *Entry, h *Hint, countFlag bool) error {
+	_, err := t.InsertVersion(key, e, h, countFlag, 0, nil)
+
+	return err
+}
+
+// InsertVersion inserts record to the b+ tree as the version seq of the key and returns the record
+// If the key exists and keep returns true for the seq of its current version, that version is kept
+// as the previous one for the snapshots still reading it, else the record is updated in place
+func (t *BPTree) InsertVersion(key []byte, e *Entry, h *Hint, countFlag bool, seq uint64, keep func(seq uint64) bool) (*Record, error) {
 	if r, err := t.Find(key); err == nil && r != nil {
 		if countFlag && h.meta.Flag == DataDeleteFlag && r.H.meta.Flag != DataDeleteFlag && t.ValidKeyCount > 0 {
 			t.ValidKeyCount--
@@ -266,19 +320,35 @@ func (t *BPTree)Insert(key []byte, e *Entry, h *Hint, countFlag bool) error {
 			t.ValidKeyCount++
 		}
 
-		return r.UpdateRecord(h, e)
+		t.validSize += liveSize(h, e) - liveSize(r.H, r.E)
+
+		if keep != nil && keep(r.seq) {
+			prev := *r
+			r.prev = &prev
+		}
+
+		r.seq = seq
+		r.keyVersion++
+		t.updateMaxKeyVersion(r.keyVersion)
+
+		return r, r.UpdateRecord(h, e)
 	}
 
 	// Initialize the Record object when key does not exist
-	pointer := &Record{H:h, E:e}
+	pointer := &Record{H:h, E:e, seq:seq, keyVersion:t.baseKeyVersion+1}
+	t.updateMaxKeyVersion(pointer.keyVersion)
+
+	// UPdate the validKeyCount number, a tombstone of an unknown key is not counted
+	if h.meta.Flag != DataDeleteFlag {
+		t.ValidKeyCount++
+	}
 
-	// UPdate the validKeyCount number
-	t.ValidKeyCount++
+	t.validSize += liveSize(h, e)
 
 	// Check if the root nodes is nil or not
 	// if nil build a start new tree for insert
 	if t.root == nil {
-		return t.startNewTree(key, pointer)
+		return pointer, t.startNewTree(key, pointer)
 	}
 
 	// Find the leaf node to insert
@@ -288,11 +358,11 @@ func (t *BPTree)Insert(key []byte, e *Entry, h *Hint, countFlag bool) error {
 	// if not full insert into the leaf node
 	if leaf.KeysNum < order-1 {
 		insertIntoLeaf(leaf, key, pointer)
-		return nil
+		return pointer, nil
 	}
 
 	// split the leaf node when it is not enough space to insert
-	return t.splitLeaf(leaf, key, pointer)
+	return pointer, t.splitLeaf(leaf, key, pointer)
 }
 
 // getSplitIndex returns split index at the given length.
@@ -467,8 +537,19 @@ func (t *BPTree) splitParent(node *Node, leftIndex int, key []byte, right *Node)
 	// Get the split index for he intermediate node
 	splitIndex := getSplitIndex(order-1)
 
+	// Reset the keys and pointers of the node, the key at splitIndex moves up to the parent.
+	node.KeysNum = 0
+	for i = 0; i < splitIndex; i++ {
+		node.Keys[i] = tmpKeys[i]
+		node.pointers[i] = tmpPointers[i]
+		node.KeysNum++
+	}
+
 	// Reset the last pointer of the node.
 	node.pointers[i] = tmpPointers[i]
+	for k := i + 1; k < order; k++ {
+		node.pointers[k] = nil
+	}
 
 	newNode := newNode()
 
@@ -0,0 +1,82 @@
+package nutsdb
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// testHint returns the hint of a put or a deletion of the key
+func testHint(key []byte, flag uint16) *Hint {
+	return &Hint{key: key, meta: &MetaData{keySize: uint32(len(key)), Flag: flag}}
+}
+
+func TestBPTree_InsertSplits(t *testing.T) {
+	tree := NewTree()
+
+	keys := rand.New(rand.NewSource(1)).Perm(1000)
+	for _, i := range keys {
+		key := []byte(fmt.Sprintf("key%04d", i))
+		if err := tree.Insert(key, &Entry{Key: key}, testHint(key, DataSetFlag), CountFlagEnabled); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every split keeps the leaves linked in the order of the keys
+	records := tree.All()
+	if len(records) != len(keys) || tree.ValidKeyCount != len(keys) {
+		t.Fatalf("All returned %d records, ValidKeyCount = %d, want %d", len(records), tree.ValidKeyCount, len(keys))
+	}
+
+	for i := 1; i < len(records); i++ {
+		if bytes.Compare(records[i-1].H.key, records[i].H.key) >= 0 {
+			t.Fatalf("records out of order: %s before %s", records[i-1].H.key, records[i].H.key)
+		}
+	}
+
+	for _, i := range keys {
+		key := []byte(fmt.Sprintf("key%04d", i))
+		if r, err := tree.Find(key); err != nil || !bytes.Equal(r.E.Key, key) {
+			t.Fatalf("Find(%s) = %v, %v", key, r, err)
+		}
+	}
+
+	if _, err := tree.Find([]byte("key1000")); err != ErrKeyNotFound {
+		t.Errorf("Find of a missing key returned %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestBPTree_InsertVersion(t *testing.T) {
+	tree := NewTree()
+	key := []byte("k")
+
+	if _, err := tree.InsertVersion(key, &Entry{Value: []byte("1")}, testHint(key, DataSetFlag), CountFlagEnabled, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the version 1 is kept for a snapshot, the version 2 is updated in place
+	keep := func(seq uint64) bool { return seq == 1 }
+	_, _ = tree.InsertVersion(key, &Entry{Value: []byte("2")}, testHint(key, DataSetFlag), CountFlagEnabled, 2, keep)
+	r, _ := tree.InsertVersion(key, &Entry{Value: []byte("3")}, testHint(key, DataDeleteFlag), CountFlagEnabled, 3, keep)
+
+	if tree.ValidKeyCount != 0 {
+		t.Errorf("ValidKeyCount after a deletion = %d, want 0", tree.ValidKeyCount)
+	}
+
+	// nobody read the version 2, so a snapshot of seq 2 sees the version 1
+	for seq, want := range map[uint64]string{1: "1", 2: "1", 3: "3"} {
+		if v := r.version(seq); v == nil || string(v.E.Value) != want {
+			t.Errorf("version(%d) = %v, want %s", seq, v, want)
+		}
+	}
+
+	if v := r.version(0); v != nil {
+		t.Errorf("version(0) = %v, want nil", v)
+	}
+
+	r.prune(3)
+	if r.prev != nil {
+		t.Error("prune kept the versions older than the snapshot")
+	}
+}
@@ -0,0 +1,193 @@
+package nutsdb
+
+import "time"
+
+type (
+	// commitRequest represents a tx queued for the group commit
+	commitRequest struct {
+		tx		*Tx
+		done	chan error // receives the result of the commit of the tx
+	}
+
+	// pendingCommit represents a tx of a group whose entries were written and not yet applied to the indexes
+	pendingCommit struct {
+		index	int // the index of the tx in the group
+		tx		*Tx
+		writes	*accessSet
+		fileIDs	[]int64
+		offs	[]int64
+	}
+)
+
+// queueCommit queues the tx for the group commit and waits for the result of its commit
+func (db *DB) queueCommit(tx *Tx) error {
+	req := &commitRequest{tx: tx, done: make(chan error, 1)}
+	db.groupCommits <- req
+
+	return <-req.done
+}
+
+// runGroupCommits commits the queued txs by groups until the queue is closed by Close
+func (db *DB) runGroupCommits() {
+	for req := range db.groupCommits {
+		group := db.collectGroup(req)
+
+		txs := make([]*Tx, len(group))
+		for i, r := range group {
+			txs[i] = r.tx
+		}
+
+		errs := db.commitGroup(txs)
+		for i, r := range group {
+			r.done <- errs[i]
+		}
+	}
+}
+
+// collectGroup returns the group starting at the given request: the txs queued while the previous group was committed,
+// and the txs queued within GroupCommitMaxWait, up to GroupCommitMaxBatch txs
+func (db *DB) collectGroup(first *commitRequest) []*commitRequest {
+	group := []*commitRequest{first}
+
+	var timeout <-chan time.Time
+	if db.opt.GroupCommitMaxWait > 0 {
+		timer := time.NewTimer(db.opt.GroupCommitMaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for len(group) < db.opt.GroupCommitMaxBatch {
+		if timeout == nil {
+			select {
+			case req, ok := <-db.groupCommits:
+				if !ok {
+					return group
+				}
+				group = append(group, req)
+			default:
+				return group
+			}
+
+			continue
+		}
+
+		select {
+		case req, ok := <-db.groupCommits:
+			if !ok {
+				return group
+			}
+			group = append(group, req)
+		case <-timeout:
+			return group
+		}
+	}
+
+	return group
+}
+
+// commitGroup commits the txs in order, writing their entries back-to-back with a single sync of the ActiveFile,
+// and returns the error of every tx
+// A tx fails with ErrConflict if it conflicts with a tx committed after it began, including the previous txs of the group,
+// and a write error fails the tx and the next txs of the group
+// A sync error fails the txs written to the ActiveFile, they are marked not committed on disk so the recovery skips them,
+// and makes the db read-only since the data written before may be lost too, see ErrWriteFailed
+func (db *DB) commitGroup(txs []*Tx) []error {
+	db.commitMu.Lock()
+	defer db.commitMu.Unlock()
+
+	errs := make([]error, len(txs))
+	if db.writeErr != nil {
+		for i := range errs {
+			errs[i] = ErrWriteFailed
+		}
+
+		return errs
+	}
+
+	var (
+		pending		[]*pendingCommit
+		writeErr	error
+	)
+
+	for i, tx := range txs {
+		if writeErr != nil {
+			errs[i] = writeErr
+			continue
+		}
+
+		c := &pendingCommit{index: i, tx: tx, writes: tx.writeSet()}
+		if errs[i] = db.validateGroup(tx, c.writes, pending); errs[i] != nil {
+			continue
+		}
+
+		if errs[i] = tx.checkEntrySize(); errs[i] != nil {
+			continue
+		}
+
+		if c.fileIDs, c.offs, errs[i] = tx.writeEntries(); errs[i] != nil {
+			writeErr = errs[i]
+			continue
+		}
+
+		pending = append(pending, c)
+	}
+
+	if len(pending) > 0 && db.opt.SyncEnable && db.writeErr == nil {
+		db.writeErr = db.ActiveFile.Sync()
+	}
+
+	// the sync failed here or when the ActiveFile was rotated,
+	// the txs completed in the files synced by the previous rotations are durable
+	if db.writeErr != nil {
+		n := 0
+		for n < len(pending) && pending[n].fileIDs[len(pending[n].fileIDs)-1] != db.ActiveFile.fileID {
+			n++
+		}
+
+		for _, c := range pending[n:] {
+			errs[c.index] = db.writeErr
+		}
+
+		_ = db.invalidate(pending[n:])
+		pending = pending[:n]
+	}
+
+	for _, c := range pending {
+		c.tx.apply(c.writes, c.fileIDs, c.offs)
+	}
+
+	return errs
+}
+
+// invalidate rewrites the last entry of the txs as not committed and syncs the ActiveFile again,
+// so the entries of the txs are skipped by the recovery if the first sync wrote them anyway
+func (db *DB) invalidate(pending []*pendingCommit) error {
+	for _, c := range pending {
+		last := len(c.tx.pendingWrites) - 1
+
+		entry := c.tx.pendingWrites[last]
+		entry.Meta.status = UnCommitted
+
+		if _, err := db.ActiveFile.WriteAt(entry.Encode(), c.offs[last]); err != nil {
+			return err
+		}
+	}
+
+	return db.ActiveFile.Sync()
+}
+
+// validateGroup returns ErrConflict if the tx conflicts with the txs committed after it began,
+// or with the txs of its group written before it, db.commitMu must be locked
+func (db *DB) validateGroup(tx *Tx, writes *accessSet, pending []*pendingCommit) error {
+	if err := db.validate(tx.snap.seq, &tx.reads, writes); err != nil {
+		return err
+	}
+
+	for _, c := range pending {
+		if tx.reads.conflicts(c.writes) || writes.conflicts(c.writes) {
+			return ErrConflict
+		}
+	}
+
+	return nil
+}
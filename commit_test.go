@@ -0,0 +1,218 @@
+package nutsdb
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errSync = errors.New("sync failed")
+
+// failingSync represents a RWManager whose Sync fails
+type failingSync struct {
+	RWManager
+}
+
+// Sync returns errSync
+func (f *failingSync) Sync() error {
+	return errSync
+}
+
+// failSyncs makes the syncs of the ActiveFile fail
+func failSyncs(db *DB) {
+	db.commitMu.Lock()
+	defer db.commitMu.Unlock()
+
+	db.ActiveFile.rwManager = &failingSync{RWManager: db.ActiveFile.rwManager}
+}
+
+// putKey commits the key in a tx of its own
+func putKey(db *DB, key string, size int) error {
+	return db.UpdateWithRetry(1, func(tx *Tx) error {
+		return tx.Put("bucket", []byte(key), make([]byte, size), Persistent)
+	})
+}
+
+// hasKey returns if the key exists in the bucket
+func hasKey(t *testing.T, db *DB, key string) (ok bool) {
+	t.Helper()
+
+	view(t, db, func(tx *Tx) error {
+		_, err := tx.Get("bucket", []byte(key))
+		ok = err == nil
+		return nil
+	})
+
+	return
+}
+
+func TestDB_CommitSyncFailure(t *testing.T) {
+	for name, batch := range map[string]int{"single": 1, "group": 16} {
+		t.Run(name, func(t *testing.T) {
+			opt := testOptions(t)
+			opt.GroupCommitMaxBatch = batch
+			db := openDB(t, opt)
+
+			if err := putKey(db, "a", 10); err != nil {
+				t.Fatal(err)
+			}
+
+			failSyncs(db)
+
+			if err := putKey(db, "b", 10); err != errSync {
+				t.Fatalf("Commit with a failing sync returned %v, want the sync error", err)
+			}
+
+			// the db accepts no write anymore, but can still be read
+			if err := putKey(db, "c", 10); err != ErrWriteFailed {
+				t.Errorf("Commit after a failed sync returned %v, want ErrWriteFailed", err)
+			}
+
+			if !hasKey(t, db, "a") || hasKey(t, db, "b") || hasKey(t, db, "c") {
+				t.Errorf("keys after the failed sync: a %v, b %v, c %v, want only a", hasKey(t, db, "a"), hasKey(t, db, "b"), hasKey(t, db, "c"))
+			}
+
+			// the entries of b reached the file but are marked not committed
+			db = reopenDB(t, db)
+
+			if !hasKey(t, db, "a") || hasKey(t, db, "b") {
+				t.Errorf("keys after reopen: a %v, b %v, want only a", hasKey(t, db, "a"), hasKey(t, db, "b"))
+			}
+
+			if err := putKey(db, "d", 10); err != nil {
+				t.Errorf("Commit after reopen returned %v", err)
+			}
+		})
+	}
+}
+
+func TestDB_GroupCommitSyncFailure(t *testing.T) {
+	opt := testOptions(t)
+	opt.GroupCommitMaxWait = 20 * time.Millisecond
+	db := openDB(t, opt)
+
+	failSyncs(db)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = putKey(db, string(rune('a'+i)), 10)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != errSync && err != ErrWriteFailed {
+			t.Errorf("Commit %d returned %v, want the sync error or ErrWriteFailed", i, err)
+		}
+	}
+
+	db = reopenDB(t, db)
+
+	view(t, db, func(tx *Tx) error {
+		if names, _ := tx.Buckets(DataStrucctureBPTree); len(names) != 0 {
+			t.Errorf("buckets after reopen = %v, want none", names)
+		}
+
+		return nil
+	})
+}
+
+func TestDB_RotationSyncFailure(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		opt.SegmentSize = 1024
+		db := openDB(t, opt)
+
+		if err := putKey(db, "a", 100); err != nil {
+			t.Fatal(err)
+		}
+
+		failSyncs(db)
+
+		first, second := beginWritable(t, db), beginWritable(t, db)
+		_ = first.Put("bucket", []byte("b"), make([]byte, 100), Persistent)
+		_ = second.Put("bucket", []byte("c"), make([]byte, 900), Persistent)
+
+		// the second tx rotates the ActiveFile, whose sync fails for both txs
+		errs := db.commitGroup([]*Tx{first, second})
+		first.close()
+		second.close()
+
+		if errs[0] != errSync || errs[1] != errSync {
+			t.Errorf("commitGroup returned %v, want the sync error for both txs", errs)
+		}
+
+		db = reopenDB(t, db)
+
+		if !hasKey(t, db, "a") || hasKey(t, db, "b") || hasKey(t, db, "c") {
+			t.Errorf("keys after reopen: a %v, b %v, c %v, want only a", hasKey(t, db, "a"), hasKey(t, db, "b"), hasKey(t, db, "c"))
+		}
+	})
+}
+
+func TestDB_CloseTimeout(t *testing.T) {
+	opt := testOptions(t)
+	opt.CloseTimeout = 20 * time.Millisecond
+	db := openDB(t, opt)
+
+	tx, err := db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = db.Close(); err != ErrTxInProgress {
+		t.Fatalf("Close with a tx in progress returned %v, want ErrTxInProgress", err)
+	}
+
+	// the db stays open after the timeout
+	if err = putKey(db, "a", 1); err != nil {
+		t.Errorf("Commit after the Close timeout returned %v", err)
+	}
+
+	_ = tx.Rollback()
+
+	if err = db.Close(); err != nil {
+		t.Errorf("Close returned %v", err)
+	}
+
+	if _, err = db.Begin(false); err != ErrDBClosed {
+		t.Errorf("Begin after Close returned %v, want ErrDBClosed", err)
+	}
+}
+
+func TestDB_CloseWaitsForTxs(t *testing.T) {
+	opt := testOptions(t)
+	opt.CloseTimeout = 0
+	db := openDB(t, opt)
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = tx.Put("bucket", []byte("a"), []byte("1"), Persistent)
+
+	committed := make(chan error, 1)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		committed <- tx.Commit()
+	}()
+
+	if err = db.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	if err = <-committed; err != nil {
+		t.Errorf("Commit during Close returned %v", err)
+	}
+
+	db = openDB(t, opt)
+	if !hasKey(t, db, "a") {
+		t.Error("the tx committed during Close is lost")
+	}
+}
@@ -75,7 +75,7 @@ func (df *DataFile) ReadAt(off int) (e *Entry, err error) {
 
 	e = &Entry {
 		crc:	binary.LittleEndian.Uint32(buf[0:4]),
-		Meta:	meta
+		Meta:	meta,
 	}
 
 	if e.IsZero() {
@@ -100,7 +100,7 @@ func (df *DataFile) ReadAt(off int) (e *Entry, err error) {
 	if err != nil {
 		return nil, err
 	}
-	e.Value = keyBuf
+	e.Key = keyBuf
 
 	// read value
 	off += int(meta.keySize)
@@ -152,6 +152,6 @@ func readMetaData(buf []byte) *MetaData {
 		bucketSize:	binary.LittleEndian.Uint32(buf[26:30]),
 		status:		binary.LittleEndian.Uint16(buf[30:32]),
 		ds:			binary.LittleEndian.Uint16(buf[32:34]),
-		txID:		binary.LittleEndian.Uint64(buf[34,42]),
+		txID:		binary.LittleEndian.Uint64(buf[34:42]),
 	}
 }
\ No newline at end of file
@@ -1,11 +1,17 @@
 package nutsdb
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"github.com/HelloChenHZ/nutsdb/ds/bitmap"
+	"github.com/HelloChenHZ/nutsdb/ds/hash"
+	"github.com/HelloChenHZ/nutsdb/ds/hll"
 	"github.com/HelloChenHZ/nutsdb/ds/list"
 	"github.com/HelloChenHZ/nutsdb/ds/set"
+	"github.com/HelloChenHZ/nutsdb/ds/stream"
 	"github.com/HelloChenHZ/nutsdb/ds/zset"
+	"github.com/bwmarrin/snowflake"
 	"github.com/xujiajun/utils/filesystem"
 	"github.com/xujiajun/utils/strconv2"
 	"io"
@@ -21,6 +27,9 @@ var (
 	// ErrDBClosed is returned when db is closed
 	ErrDBClosed = errors.New("db is closed")
 
+	// ErrTxInProgress is returned by Close when the txs in progress are not closed within Options.CloseTimeout
+	ErrTxInProgress = errors.New("txs still in progress")
+
 	// ErrBucket is returned when bucket is not in the HintIdx
 	ErrBucket = errors.New("err bucket")
 
@@ -29,6 +38,12 @@ var (
 
 	// ErrFn is returned when fn is nil
 	ErrFn = errors.New("err fn")
+
+	// ErrDataStructure is returned when the data structure flag is unknown
+	ErrDataStructure = errors.New("err data structure")
+
+	// ErrBucketExists is returned when creating a bucket that already exists
+	ErrBucketExists = errors.New("bucket already exists")
 )
 
 const (
@@ -73,6 +88,48 @@ const (
 
 	// DataZPopMinFlag represents the data aZPopMin flag
 	DataZPopMinFlag
+
+	// DataZRemRangeByLexFlag represents the data ZRemRangeByLex flag
+	DataZRemRangeByLexFlag
+
+	// DataHSetFlag represents the data HSet flag
+	DataHSetFlag
+
+	// DataHDelFlag represents the data HDel flag
+	DataHDelFlag
+
+	// DataSetBitFlag represents the data SetBit flag
+	DataSetBitFlag
+
+	// DataPFAddFlag represents the data PFAdd flag
+	DataPFAddFlag
+
+	// DataPFMergeFlag represents the data PFMerge flag
+	DataPFMergeFlag
+
+	// DataXAddFlag represents the data XAdd flag
+	DataXAddFlag
+
+	// DataXTrimFlag represents the data XTrim flag
+	DataXTrimFlag
+
+	// DataXGroupCreateFlag represents the data XGroupCreate flag
+	DataXGroupCreateFlag
+
+	// DataXGroupDestroyFlag represents the data XGroupDestroy flag
+	DataXGroupDestroyFlag
+
+	// DataXReadGroupFlag represents the data XReadGroup flag
+	DataXReadGroupFlag
+
+	// DataXAckFlag represents the data XAck flag
+	DataXAckFlag
+
+	// DataCreateBucketFlag represents the data CreateBucket flag
+	DataCreateBucketFlag
+
+	// DataDeleteBucketFlag represents the data DeleteBucket flag
+	DataDeleteBucketFlag
 )
 
 
@@ -88,6 +145,14 @@ const (
 
 	// ScanNoLimit represents the data scan no limit flag
 	ScanNoLimit int = -1
+
+	// SeparatorForZSetKey represents the separator between the key and the score of the ZAdd entry key
+	SeparatorForZSetKey = "|"
+
+	// SeparatorForBucketPath represents the separator between the names of the nested buckets in the bucket field,
+	// the field of a nested bucket starts with it too, so the nested buckets never collide with the buckets
+	// named by the callers, like "a/b" or "a", as long as these names do not start with NUL
+	SeparatorForBucketPath = "\x00"
 )
 
 const (
@@ -102,22 +167,56 @@ const (
 
 	// DataStructureList represents the data structure list flag
 	DataStructureList
+
+	// DataStructureHash represents the data structure hash flag
+	DataStructureHash
+
+	// DataStructureHLL represents the data structure HyperLogLog flag
+	DataStructureHLL
+
+	// DataStructureStream represents the data structure stream flag
+	DataStructureStream
 )
 
 type (
 	DB struct {
 		opt 			Options		// the database options
-		BPTreeIdx 		BPTreeIdx	// Hint Index
-		SetIdx 			SetIdx
-		SortedSetIdx	SortedSetIdx
-		ListIdx 		ListIdx
+		indexes
 		ActiveFile		*DataFile
 		MaxFileID		int64
-		mu 				sync.RWMutex
+		mu 				sync.RWMutex // guards the indexes, held by the lookups of the txs and by the commits
+		commitMu		sync.Mutex // serializes the commits
+		groupCommits	chan *commitRequest // the txs queued for the group commit, nil if the group commit is disabled
+		fileMu			sync.RWMutex // guards the ActiveFile against the rotation while it is read
 		KeyCount		int // total key number, include expired, deleted, repeated
 		closed			bool
 		isMergeing		bool
 		committedTxIds	map[uint64]struct{}
+		seq				uint64 // the commit sequence, the snapshot of a tx is the state as of its value when the tx began
+		snap			*snapshot // the snapshot of seq, built by the first tx that begins after the commit
+		snapMu			sync.Mutex // guards snap, readers and writers
+		readers			map[uint64]int // the number of the txs reading the snapshot of every seq
+		writers			map[uint64]int // the number of the read/write txs reading the snapshot of every seq
+		committed		[]committedWrites // the writes of the last commits, by seq, for the conflict detection
+		commit			commitState
+		bucketSeqs		map[bucketKey]uint64 // the seq of the commit that created or copied the index object of every bucket
+		versions		[]version // the records with previous versions, by seq
+		txCount			int // the number of the txs in progress, guarded by snapMu
+		txsDone			chan struct{} // closed when txCount drops to 0 while Close waits, guarded by snapMu
+		writeErr		error // the sync error that made the db read-only, guarded by commitMu
+		keyVersions		map[string]uint64 // the greatest key version of the deleted BPTree buckets, rebuilt by the recovery
+		idNode			*snowflake.Node // generates the tx IDs and the stream IDs, shared so that they never repeat
+	}
+
+	// indexes represents the indexes of all the data structures
+	indexes struct {
+		BPTreeIdx 		BPTreeIdx	// Hint Index
+		SetIdx 			SetIdx
+		SortedSetIdx	SortedSetIdx
+		ListIdx 		ListIdx
+		HashIdx			HashIdx
+		HLLIdx			HLLIdx
+		StreamIdx		StreamIdx
 	}
 
 	// BPTreeIdx represents the B+ tree index
@@ -127,57 +226,50 @@ type (
 	SetIdx map[string]*set.Set
 
 	// SortedSetIdx represents the sorted set index
-	SortedSetIdx map[string]*zset.SortedSetNode
+	SortedSetIdx map[string]*zset.SortedSet
 
 	// ListIdx represents the list index
 	ListIdx map[string]*list.List
 
-	// Entries represents entry map
-	Entries map[string]*Entry
-)
-
-// NewDataFile returns a newly initialized DataFile object
-func NewDataFile(path string, capacity int64, rwMode RWMode) (df *DataFile, err error) {
-	var rwManager RWManager
+	// HashIdx represents the hash index
+	HashIdx map[string]*hash.Hash
 
-	if capacity <= 0 {
-		return nil, ErrCapacity
-	}
-
-	if rwMode == FileIO {
-		rwManager, err = NewFileIORWManager(path, capacity)
-		if err != nil {
-			return nil, err
-		}
-	}
+	// HLLIdx represents the HyperLogLog index
+	HLLIdx map[string]*hll.HLL
 
-	if rwMode == MMap {
-		rwManager, err = NewMMapRWManager(path, capacity)
-		if err != nil {
-			return nil, err
-		}
-	}
+	// StreamIdx represents the stream index
+	StreamIdx map[string]*stream.Stream
 
-	return &DataFile {
-		path:		path,
-		writeOff:	0,
-		ActualSize:	0,
-		rwManager:	rwManager,
-	}, nil
-}
+	// Entries represents entry map
+	Entries map[string]*Entry
+)
 
 // Open returns a newly initialized DB object
 func Open(opt Options) (*DB, error) {
 	db := &DB{
-		BPTreeIdx: 		make(BPTreeIdx),
-		SetIdx:			make(SetIdx),
-		SortedSetIdx: 	make(SortedSetIdx),
-		ListIdx:		make(ListIdx),
+		indexes:		indexes{
+			BPTreeIdx: 		make(BPTreeIdx),
+			SetIdx:			make(SetIdx),
+			SortedSetIdx: 	make(SortedSetIdx),
+			ListIdx:		make(ListIdx),
+			HashIdx:		make(HashIdx),
+			HLLIdx:			make(HLLIdx),
+			StreamIdx:		make(StreamIdx),
+		},
 		MaxFileID:		0,
 		opt:			opt,
 		KeyCount: 		0,
 		closed:			false,
 		committedTxIds:	make(map[uint64]struct{}),
+		readers:		make(map[uint64]int),
+		writers:		make(map[uint64]int),
+		bucketSeqs:		make(map[bucketKey]uint64),
+		keyVersions:	make(map[string]uint64),
+	}
+
+	var err error
+	if db.idNode, err = snowflake.NewNode(opt.NodeNum); err != nil {
+		return nil, err
 	}
 
 	if ok := filesystem.PathIsExist(db.opt.Dir); !ok {
@@ -190,13 +282,25 @@ func Open(opt Options) (*DB, error) {
 		return nil, fmt.Errorf("db.buildIdexes error: %s", err)
 	}
 
+	if opt.GroupCommitMaxBatch > 1 {
+		db.groupCommits = make(chan *commitRequest, opt.GroupCommitMaxBatch)
+		go db.runGroupCommits()
+	}
+
 	return db, nil
 }
 
 // setActiveFile sets the ActiveFile (DataFile object)
 func (db *DB) setActiveFile() (err error) {
 	filepath := db.getDataPath(db.MaxFileID)
-	db.ActiveFile, err = NewDataFIle(filepath, db.opt.SegmentSize, db.opt.RWMode)
+	db.ActiveFile, err = NewDataFile(filepath, db.opt.SegmentSize, db.opt.RWMode)
+	if err != nil {
+		return
+	}
+
+	db.ActiveFile.fileID = db.MaxFileID
+
+	return nil
 }
 
 // getMaxFileIDAndFileds returns max fileId and fileIds
@@ -220,6 +324,10 @@ func (db *DB) getMaxFileIDAndFileIDs() (maxFileID int64, dataFileIds []int) {
 		dataFileIds = append(dataFileIds, idVal)
 	}
 
+	if len(dataFileIds) == 0 {
+		return 0, nil
+	}
+
 	sort.Ints(dataFileIds)
 	maxFileID = int64(dataFileIds[len(dataFileIds)-1])
 
@@ -287,4 +395,526 @@ func (db *DB) buildIndexes() (err error) {
 // buildHintIdx builds the Hint Indexes
 func (db *DB) buildHintIdx(dataFileIds []int) error {
 	unconfirmedRecords, committedTxIds, err := db.parseDataFiles(dataFileIds)
-}
\ No newline at end of file
+	if err != nil {
+		return err
+	}
+
+	db.committedTxIds = committedTxIds
+
+	for _, r := range unconfirmedRecords {
+		// skip the records of the tx that was not committed
+		if _, ok := db.committedTxIds[r.H.meta.txID]; !ok {
+			continue
+		}
+
+		r.H.meta.status = Committed
+
+		if err := db.buildIdxByRecord(r); err != nil {
+			return err
+		}
+
+		db.KeyCount++
+	}
+
+	return nil
+}
+
+// buildIdxByRecord dispatches the record to the index of its data structure
+func (db *DB) buildIdxByRecord(r *Record) error {
+	bucket := string(r.H.meta.bucket)
+
+	if r.H.meta.Flag == DataCreateBucketFlag || r.H.meta.Flag == DataDeleteBucketFlag {
+		return db.buildBucketIdx(r.H.meta.ds, bucket, r.H.meta.Flag)
+	}
+
+	switch r.H.meta.ds {
+	case DataStrucctureBPTree:
+		db.ownBucket(DataStrucctureBPTree, bucket)
+
+		if r.H.meta.Flag == DataSetBitFlag {
+			return db.buildSetBitIdx(bucket, r.E.Value, r.H, CountFlagEnabled)
+		}
+
+		if err := db.insertRecord(bucket, r.E, r.H, CountFlagEnabled); err != nil {
+			return fmt.Errorf("when build BPTreeIdx insert index err: %s", err)
+		}
+	case DataStructureSet:
+		return db.buildSetIdx(bucket, r)
+	case DataStructureSortedSet:
+		return db.buildSortedSetIdx(bucket, r.E)
+	case DataStructureHash:
+		return db.buildHashIdx(bucket, r.E)
+	case DataStructureHLL:
+		return db.buildHLLIdx(bucket, r.E)
+	case DataStructureStream:
+		return db.buildStreamIdx(bucket, r.E)
+	}
+
+	return nil
+}
+
+// buildSetBitIdx applies the SetBit patch to the value of the key in the BPTreeIdx
+// The patched value is kept in memory whatever the EntryIdxMode is, since the hint points to the patch
+func (db *DB) buildSetBitIdx(bucket string, patch []byte, h *Hint, countFlag bool) error {
+	offset, bit, err := bitmap.DecodePatch(patch)
+	if err != nil {
+		return fmt.Errorf("when build BPTreeIdx SetBit index err: %s", err)
+	}
+
+	idx := db.BPTreeIdx[bucket]
+
+	var value []byte
+	if r, err := idx.Find(h.key); err == nil && r.H.meta.Flag != DataDeleteFlag && !r.IsExpired() {
+		base := r.E
+		if base == nil {
+			if base, err = db.readEntry(r.H); err != nil {
+				return fmt.Errorf("when build BPTreeIdx SetBit index err: %s", err)
+			}
+		}
+
+		value = base.Value
+
+		// only the values patched before are owned by the index and can be modified in place,
+		// unless a snapshot in use may read them
+		if base.Meta.Flag != DataSetBitFlag || db.commit.isShared(r.seq) {
+			value = append([]byte(nil), value...)
+		}
+	}
+
+	if value, _, err = bitmap.SetBit(value, offset, bit); err != nil {
+		return fmt.Errorf("when build BPTreeIdx SetBit index err: %s", err)
+	}
+
+	e := &Entry{
+		Key:	h.key,
+		Value:	value,
+		Meta:	h.meta,
+	}
+
+	return db.insertRecord(bucket, e, h, countFlag)
+}
+
+// buildSetIdx builds the SetIdx at the given record
+func (db *DB) buildSetIdx(bucket string, r *Record) error {
+	db.ownBucket(DataStructureSet, bucket)
+
+	key, value := string(r.H.key), r.E.Value
+
+	// the set was removed by a previous removal of its last member
+	if r.H.meta.Flag == DataDeleteFlag && db.SetIdx[bucket].SHasKey(key) {
+		if err := db.SetIdx[bucket].SRem(key, value); err != nil {
+			return fmt.Errorf("when build SetIdx SRem index err: %s", err)
+		}
+	}
+
+	if r.H.meta.Flag == DataSetFlag {
+		if err := db.SetIdx[bucket].SAdd(key, value); err != nil {
+			return fmt.Errorf("when build SetIdx SAdd index err: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// buildSortedSetIdx applies the sorted set entry to the SortedSetIdx
+func (db *DB) buildSortedSetIdx(bucket string, e *Entry) error {
+	db.ownBucket(DataStructureSortedSet, bucket)
+
+	return applySortedSetEntry(db.SortedSetIdx[bucket], e)
+}
+
+// applySortedSetEntry applies the sorted set entry to ss, the committed index or the one seen inside a tx
+func applySortedSetEntry(ss *zset.SortedSet, e *Entry) error {
+	switch e.Meta.Flag {
+	case DataZAddFlag:
+		key, score, err := decodeZSetKey(e.Key)
+		if err != nil {
+			return fmt.Errorf("when build SortedSetIdx ZAdd index err: %s", err)
+		}
+
+		return ss.Put(key, zset.SCORE(score), e.Value)
+	case DataZRemFlag:
+		ss.Remove(string(e.Key))
+	case DataZRemRangeByRankFlag:
+		start, err := strconv2.StrToInt(string(e.Key))
+		if err != nil {
+			return fmt.Errorf("when build SortedSetIdx ZRemRangeByRank index err: %s", err)
+		}
+
+		end, err := strconv2.StrToInt(string(e.Value))
+		if err != nil {
+			return fmt.Errorf("when build SortedSetIdx ZRemRangeByRank index err: %s", err)
+		}
+
+		ss.GetByRankRange(start, end, true)
+	case DataZRemRangeByLexFlag:
+		min, err := zset.ParseLexBound(string(e.Key))
+		if err != nil {
+			return fmt.Errorf("when build SortedSetIdx ZRemRangeByLex index err: %s", err)
+		}
+
+		max, err := zset.ParseLexBound(string(e.Value))
+		if err != nil {
+			return fmt.Errorf("when build SortedSetIdx ZRemRangeByLex index err: %s", err)
+		}
+
+		ss.ZRemRangeByLex(min, max)
+	case DataZPopMaxFlag, DataZPopMinFlag:
+		// the popped member is logged, since the member with the highest or lowest score
+		// may have been changed by the other writes of the tx
+		ss.Remove(string(e.Key))
+	}
+
+	return nil
+}
+
+// buildHashIdx applies the hash entry to the HashIdx
+func (db *DB) buildHashIdx(bucket string, e *Entry) error {
+	db.ownBucket(DataStructureHash, bucket)
+
+	field, value, err := decodeHashValue(e.Value)
+	if err != nil {
+		return fmt.Errorf("when build HashIdx index err: %s", err)
+	}
+
+	switch e.Meta.Flag {
+	case DataHSetFlag:
+		db.HashIdx[bucket].HSet(string(e.Key), field, value)
+	case DataHDelFlag:
+		db.HashIdx[bucket].HDel(string(e.Key), field)
+	}
+
+	return nil
+}
+
+// buildHLLIdx applies the HyperLogLog entry to the HLLIdx
+func (db *DB) buildHLLIdx(bucket string, e *Entry) error {
+	db.ownBucket(DataStructureHLL, bucket)
+
+	switch e.Meta.Flag {
+	case DataPFAddFlag:
+		index, rank, err := hll.DecodeRegister(e.Value)
+		if err != nil {
+			return fmt.Errorf("when build HLLIdx index err: %s", err)
+		}
+
+		db.HLLIdx[bucket].Set(string(e.Key), index, rank)
+	case DataPFMergeFlag:
+		s, err := hll.Unmarshal(e.Value)
+		if err != nil {
+			return fmt.Errorf("when build HLLIdx index err: %s", err)
+		}
+
+		db.HLLIdx[bucket].Merge(string(e.Key), s)
+	}
+
+	return nil
+}
+
+// buildStreamIdx applies the stream entry to the StreamIdx
+// The operations were checked by the tx, so only the malformed entries are reported
+func (db *DB) buildStreamIdx(bucket string, e *Entry) error {
+	db.ownBucket(DataStructureStream, bucket)
+
+	return applyStreamEntry(db.StreamIdx[bucket], e)
+}
+
+// applyStreamEntry applies the stream entry to s, the committed index or the one seen inside a tx
+func applyStreamEntry(s *stream.Stream, e *Entry) error {
+	key := string(e.Key)
+
+	switch e.Meta.Flag {
+	case DataXAddFlag:
+		id, value, err := decodeStreamEntry(e.Value)
+		if err != nil {
+			return fmt.Errorf("when build StreamIdx index err: %s", err)
+		}
+
+		_ = s.XAdd(key, id, value)
+	case DataXTrimFlag:
+		if len(e.Value) != 8 {
+			return fmt.Errorf("when build StreamIdx index err: %s", ErrStreamValue)
+		}
+
+		_, _ = s.XTrim(key, binary.LittleEndian.Uint64(e.Value))
+	default:
+		op, err := decodeStreamGroupOp(e.Value)
+		if err != nil {
+			return fmt.Errorf("when build StreamIdx index err: %s", err)
+		}
+
+		switch e.Meta.Flag {
+		case DataXGroupCreateFlag:
+			if len(op.ids) == 1 {
+				_ = s.XGroupCreate(key, op.group, op.ids[0])
+			}
+		case DataXGroupDestroyFlag:
+			_ = s.XGroupDestroy(key, op.group)
+		case DataXReadGroupFlag:
+			_ = s.Deliver(key, op.group, op.consumer, op.time, op.ids...)
+		case DataXAckFlag:
+			_, _ = s.XAck(key, op.group, op.ids...)
+		}
+	}
+
+	return nil
+}
+
+// buildBucketIdx creates or drops the whole index of the bucket of the data structure
+func (db *DB) buildBucketIdx(ds uint16, bucket string, flag uint16) error {
+	if !isDataStructure(ds) {
+		return ErrDataStructure
+	}
+
+	if flag == DataDeleteBucketFlag {
+		switch ds {
+		case DataStrucctureBPTree:
+			// the versions of the keys never go back, even if the bucket is created again
+			if t, ok := db.BPTreeIdx[bucket]; ok {
+				db.keyVersions[bucket] = t.maxKeyVersion
+			}
+
+			delete(db.BPTreeIdx, bucket)
+		case DataStructureSet:
+			delete(db.SetIdx, bucket)
+		case DataStructureSortedSet:
+			delete(db.SortedSetIdx, bucket)
+		case DataStructureList:
+			delete(db.ListIdx, bucket)
+		case DataStructureHash:
+			delete(db.HashIdx, bucket)
+		case DataStructureHLL:
+			delete(db.HLLIdx, bucket)
+		case DataStructureStream:
+			delete(db.StreamIdx, bucket)
+		}
+
+		delete(db.bucketSeqs, bucketKey{ds: ds, bucket: bucket})
+
+		return nil
+	}
+
+	if db.hasBucket(ds, bucket) {
+		return nil
+	}
+
+	return db.newBucket(ds, bucket)
+}
+
+// Buckets returns the sorted names of the buckets of the data structure
+// It reads the last committed state, use Tx.Buckets to read the snapshot of a tx
+func (db *DB) Buckets(ds uint16) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrDBClosed
+	}
+
+	return db.bucketNames(ds)
+}
+
+// bucketNames returns the sorted names of the buckets of the data structure
+func (idx *indexes) bucketNames(ds uint16) ([]string, error) {
+	var names []string
+
+	switch ds {
+	case DataStrucctureBPTree:
+		for name := range idx.BPTreeIdx {
+			names = append(names, name)
+		}
+	case DataStructureSet:
+		for name := range idx.SetIdx {
+			names = append(names, name)
+		}
+	case DataStructureSortedSet:
+		for name := range idx.SortedSetIdx {
+			names = append(names, name)
+		}
+	case DataStructureList:
+		for name := range idx.ListIdx {
+			names = append(names, name)
+		}
+	case DataStructureHash:
+		for name := range idx.HashIdx {
+			names = append(names, name)
+		}
+	case DataStructureHLL:
+		for name := range idx.HLLIdx {
+			names = append(names, name)
+		}
+	case DataStructureStream:
+		for name := range idx.StreamIdx {
+			names = append(names, name)
+		}
+	default:
+		return nil, ErrDataStructure
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// isDataStructure returns if ds is a known data structure flag
+func isDataStructure(ds uint16) bool {
+	return ds <= DataStructureStream
+}
+
+// hasBucket returns if the bucket of the data structure exists
+func (idx *indexes) hasBucket(ds uint16, bucket string) bool {
+	var ok bool
+
+	switch ds {
+	case DataStrucctureBPTree:
+		_, ok = idx.BPTreeIdx[bucket]
+	case DataStructureSet:
+		_, ok = idx.SetIdx[bucket]
+	case DataStructureSortedSet:
+		_, ok = idx.SortedSetIdx[bucket]
+	case DataStructureList:
+		_, ok = idx.ListIdx[bucket]
+	case DataStructureHash:
+		_, ok = idx.HashIdx[bucket]
+	case DataStructureHLL:
+		_, ok = idx.HLLIdx[bucket]
+	case DataStructureStream:
+		_, ok = idx.StreamIdx[bucket]
+	}
+
+	return ok
+}
+
+// newSet returns a newly initialized set seeded by the RandSeed option
+func (db *DB) newSet() *set.Set {
+	s := set.New()
+	if db.opt.RandSeed != 0 {
+		s.Seed(db.opt.RandSeed)
+	}
+
+	return s
+}
+
+// parseDataFiles parses the data files and returns the records with the committed tx ids
+func (db *DB) parseDataFiles(dataFileIds []int) (unconfirmedRecords []*Record, committedTxIds map[uint64]struct{}, err error) {
+	var (
+		off	int64
+		e	*Entry
+	)
+
+	committedTxIds = make(map[uint64]struct{})
+
+	for _, dataID := range dataFileIds {
+		off = 0
+		fID := int64(dataID)
+
+		f, err := NewDataFile(db.getDataPath(fID), db.opt.SegmentSize, db.opt.StartFileLoadingMode)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for {
+			entry, err := f.ReadAt(int(off))
+			if err != nil {
+				if err == io.EOF || off >= db.opt.SegmentSize {
+					break
+				}
+
+				f.Close()
+				return nil, nil, fmt.Errorf("when build hintIndex readAt err: %s", err)
+			}
+
+			if entry == nil {
+				break
+			}
+
+			// the values of the other data structures are always needed to rebuild them
+			e = nil
+			if db.opt.EntryIdxMode == HintKeyValAndRAMIdxMode || entry.Meta.ds != DataStrucctureBPTree || entry.Meta.Flag == DataSetBitFlag {
+				e = entry
+			}
+
+			if entry.Meta.status == Committed {
+				committedTxIds[entry.Meta.txID] = struct{}{}
+			}
+
+			unconfirmedRecords = append(unconfirmedRecords, &Record{
+				H: &Hint{
+					key:		entry.Key,
+					fileID:		fID,
+					meta:		entry.Meta,
+					dataPos:	uint64(off),
+				},
+				E: e,
+			})
+
+			off += entry.Size()
+		}
+
+		f.Close()
+	}
+
+	return
+}
+
+// readEntry reads the entry at given hint from the data file
+func (db *DB) readEntry(h *Hint) (*Entry, error) {
+	db.fileMu.RLock()
+	if h.fileID == db.ActiveFile.fileID {
+		defer db.fileMu.RUnlock()
+		return db.ActiveFile.ReadAt(int(h.dataPos))
+	}
+	db.fileMu.RUnlock()
+
+	df, err := NewDataFile(db.getDataPath(h.fileID), db.opt.SegmentSize, db.opt.RWMode)
+	if err != nil {
+		return nil, err
+	}
+	defer df.Close()
+
+	return df.ReadAt(int(h.dataPos))
+}
+
+// Close releases all db resources
+// No tx can begin once Close is called, and the txs in progress are waited for up to Options.CloseTimeout:
+// if they are not closed by then, Close returns ErrTxInProgress and the db stays open, so Close can be called again
+func (db *DB) Close() error {
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrDBClosed
+	}
+
+	db.closed = true
+	db.mu.Unlock()
+
+	if err := db.waitTxs(db.opt.CloseTimeout); err != nil {
+		db.mu.Lock()
+		db.closed = false
+		db.mu.Unlock()
+
+		return err
+	}
+
+	if db.groupCommits != nil {
+		close(db.groupCommits)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.ActiveFile.Close(); err != nil {
+		return err
+	}
+
+	db.ActiveFile = nil
+	db.BPTreeIdx = nil
+	db.SetIdx = nil
+	db.SortedSetIdx = nil
+	db.ListIdx = nil
+	db.HashIdx = nil
+	db.HLLIdx = nil
+	db.StreamIdx = nil
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package nutsdb
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// testOptions returns the default options with a data directory removed at the end of the test
+func testOptions(t *testing.T) Options {
+	t.Helper()
+
+	opt := DefaultOptions
+	opt.Dir = t.TempDir()
+	opt.SegmentSize = 64 * 1024
+	opt.CloseTimeout = 10 * time.Second // a tx left open fails the test instead of blocking it
+
+	return opt
+}
+
+// openDB opens a db with the given options and closes it at the end of the test
+func openDB(t *testing.T, opt Options) *DB {
+	t.Helper()
+
+	db, err := Open(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db
+}
+
+// openTestDB opens a db with the test options
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	return openDB(t, testOptions(t))
+}
+
+// reopenDB closes the db and opens it again, so its indexes are rebuilt from the data files
+func reopenDB(t *testing.T, db *DB) *DB {
+	t.Helper()
+
+	opt := db.opt
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return openDB(t, opt)
+}
+
+// forEachIdxMode runs the test with every EntryIdxMode
+func forEachIdxMode(t *testing.T, test func(t *testing.T, opt Options)) {
+	modes := map[string]EntryIdxMode{
+		"HintKeyValAndRAMIdxMode":	HintKeyValAndRAMIdxMode,
+		"HintKeyAndRAMIdxMode":		HintKeyANDRAMIdxMode,
+	}
+
+	for name, mode := range modes {
+		t.Run(name, func(t *testing.T) {
+			opt := testOptions(t)
+			opt.EntryIdxMode = mode
+			test(t, opt)
+		})
+	}
+}
+
+// update runs fn in a read/write tx and commits it
+func update(t *testing.T, db *DB, fn func(tx *Tx) error) {
+	t.Helper()
+
+	if err := db.UpdateWithRetry(1, fn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// view runs fn in a read-only tx
+func view(t *testing.T, db *DB, fn func(tx *Tx) error) {
+	t.Helper()
+
+	tx, err := db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = fn(tx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sortedStrings returns the byte slices as sorted strings
+func sortedStrings(list [][]byte) []string {
+	s := make([]string, len(list))
+	for i, item := range list {
+		s[i] = string(item)
+	}
+
+	sort.Strings(s)
+
+	return s
+}
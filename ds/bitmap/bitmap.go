@@ -0,0 +1,245 @@
+package bitmap
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+var (
+	// ErrBitOffset is returned when the bit offset is out of range
+	ErrBitOffset = errors.New("bit offset is not an integer or out of range")
+
+	// ErrBitValue is returned when the bit is not 0 or 1
+	ErrBitValue = errors.New("bit is not an integer or out of range")
+
+	// ErrBitOp is returned when the bit operation is unknown or has wrong number of the sources
+	ErrBitOp = errors.New("err bit op")
+
+	// ErrPatch is returned when the SetBit patch is malformed
+	ErrPatch = errors.New("err setbit patch")
+)
+
+// MaxBitOffset represents the max bit offset, the bitmaps are limited to 512MB
+const MaxBitOffset = 1<<32 - 1
+
+// PatchSize represents the size of the SetBit patch
+const PatchSize = 9
+
+// Op represents the bit operation
+type Op int
+
+const (
+	// OpAnd represents the bitwise AND
+	OpAnd Op = iota
+
+	// OpOr represents the bitwise OR
+	OpOr
+
+	// OpXor represents the bitwise XOR
+	OpXor
+
+	// OpNot represents the bitwise NOT, it takes only one source
+	OpNot
+)
+
+// SetBit sets or clears the bit at offset in the value and returns the value with the original bit
+// The value is grown with zero bytes when the offset is beyond its length,
+// it is modified in place if it is long enough
+func SetBit(value []byte, offset uint64, bit int) ([]byte, int, error) {
+	if offset > MaxBitOffset {
+		return nil, 0, ErrBitOffset
+	}
+
+	if bit != 0 && bit != 1 {
+		return nil, 0, ErrBitValue
+	}
+
+	byteIdx := int(offset >> 3)
+	if byteIdx >= len(value) {
+		grown := make([]byte, byteIdx+1)
+		copy(grown, value)
+		value = grown
+	}
+
+	mask := byte(1 << (7 - offset&7))
+	orig := 0
+	if value[byteIdx]&mask != 0 {
+		orig = 1
+	}
+
+	if bit == 1 {
+		value[byteIdx] |= mask
+	} else {
+		value[byteIdx] &^= mask
+	}
+
+	return value, orig, nil
+}
+
+// GetBit returns the bit value at offset in the value
+// When offset is beyond the value length, the bit is assumed to be 0
+func GetBit(value []byte, offset uint64) int {
+	byteIdx := offset >> 3
+	if byteIdx >= uint64(len(value)) {
+		return 0
+	}
+
+	if value[byteIdx]&byte(1<<(7-offset&7)) != 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// BitCount returns the number of the set bits in the bytes between start and end (both inclusive)
+// Negative start and end are the offsets from the end of the value, -1 is the last byte
+func BitCount(value []byte, start, end int) int {
+	start, end, ok := sanitizeRange(len(value), start, end)
+	if !ok {
+		return 0
+	}
+
+	count := 0
+	for _, b := range value[start : end+1] {
+		count += bits.OnesCount8(b)
+	}
+
+	return count
+}
+
+// BitPos returns the position of the first bit set to bit in the bytes between start and end (both inclusive)
+// If no such bit is found, -1 is returned, except when looking for a clear bit up to the end of the value
+// (end is -1), as the value is considered padded with zero bytes, the first bit after the value is returned
+func BitPos(value []byte, bit int, start, end int) (int64, error) {
+	if bit != 0 && bit != 1 {
+		return 0, ErrBitValue
+	}
+
+	toEnd := end == -1
+
+	start, end, ok := sanitizeRange(len(value), start, end)
+	if !ok {
+		if bit == 0 && toEnd && start >= len(value) {
+			return int64(len(value)) * 8, nil
+		}
+
+		return -1, nil
+	}
+
+	for i := start; i <= end; i++ {
+		b := value[i]
+		if bit == 0 {
+			b = ^b
+		}
+
+		if b != 0 {
+			return int64(i)*8 + int64(bits.LeadingZeros8(b)), nil
+		}
+	}
+
+	if bit == 0 && toEnd {
+		return int64(end+1) * 8, nil
+	}
+
+	return -1, nil
+}
+
+// BitOp returns the result of the bit operation between the values
+// The shorter values are treated as padded with zero bytes up to the length of the longest one
+func BitOp(op Op, values ...[]byte) ([]byte, error) {
+	if len(values) == 0 || op == OpNot && len(values) != 1 {
+		return nil, ErrBitOp
+	}
+
+	maxLen := 0
+	for _, value := range values {
+		if len(value) > maxLen {
+			maxLen = len(value)
+		}
+	}
+
+	result := make([]byte, maxLen)
+
+	if op == OpNot {
+		for i, b := range values[0] {
+			result[i] = ^b
+		}
+
+		return result, nil
+	}
+
+	copy(result, values[0])
+	for _, value := range values[1:] {
+		for i := range result {
+			var b byte
+			if i < len(value) {
+				b = value[i]
+			}
+
+			switch op {
+			case OpAnd:
+				result[i] &= b
+			case OpOr:
+				result[i] |= b
+			case OpXor:
+				result[i] ^= b
+			default:
+				return nil, ErrBitOp
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// EncodePatch returns the SetBit patch at given offset and bit
+//
+//  the SetBit patch format:
+//  |-----------------|
+//  | offset  |  bit  |
+//  |-----------------|
+//  | uint64  | uint8 |
+//  |-----------------|
+//
+func EncodePatch(offset uint64, bit int) []byte {
+	buf := make([]byte, PatchSize)
+	binary.LittleEndian.PutUint64(buf[0:8], offset)
+	buf[8] = byte(bit)
+
+	return buf
+}
+
+// DecodePatch returns the offset and the bit at given SetBit patch
+func DecodePatch(buf []byte) (offset uint64, bit int, err error) {
+	if len(buf) != PatchSize {
+		return 0, 0, ErrPatch
+	}
+
+	return binary.LittleEndian.Uint64(buf[0:8]), int(buf[8]), nil
+}
+
+// sanitizeRange returns the byte range between start and end (both inclusive) within the length
+func sanitizeRange(length, start, end int) (int, int, bool) {
+	if start < 0 {
+		start = length + start
+	}
+
+	if end < 0 {
+		end = length + end
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	if end >= length {
+		end = length - 1
+	}
+
+	if length == 0 || start > end {
+		return start, end, false
+	}
+
+	return start, end, true
+}
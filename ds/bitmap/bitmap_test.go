@@ -0,0 +1,123 @@
+package bitmap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSetGetBit(t *testing.T) {
+	value, orig, err := SetBit(nil, 10, 1)
+	if err != nil || orig != 0 || !bytes.Equal(value, []byte{0x00, 0x20}) {
+		t.Fatalf("SetBit(nil, 10, 1) = %08b, %d, %v", value, orig, err)
+	}
+
+	if value, orig, _ = SetBit(value, 10, 0); orig != 1 || !bytes.Equal(value, []byte{0x00, 0x00}) {
+		t.Errorf("SetBit(10, 0) = %08b, %d", value, orig)
+	}
+
+	value, _, _ = SetBit(value, 0, 1)
+	if GetBit(value, 0) != 1 || GetBit(value, 1) != 0 || GetBit(value, 1000) != 0 {
+		t.Errorf("GetBit on %08b returned the wrong bits", value)
+	}
+
+	if _, _, err = SetBit(nil, MaxBitOffset+1, 1); err != ErrBitOffset {
+		t.Errorf("SetBit out of range returned %v, want ErrBitOffset", err)
+	}
+
+	if _, _, err = SetBit(nil, 0, 2); err != ErrBitValue {
+		t.Errorf("SetBit of 2 returned %v, want ErrBitValue", err)
+	}
+}
+
+func TestBitCount(t *testing.T) {
+	value := []byte("foobar")
+
+	tests := []struct {
+		start, end, want int
+	}{
+		{0, -1, 26},
+		{0, 0, 4},
+		{1, 1, 6},
+		{-2, -1, 7},
+		{5, 2, 0},
+		{10, 20, 0},
+	}
+
+	for _, tt := range tests {
+		if n := BitCount(value, tt.start, tt.end); n != tt.want {
+			t.Errorf("BitCount(%d, %d) = %d, want %d", tt.start, tt.end, n, tt.want)
+		}
+	}
+}
+
+func TestBitPos(t *testing.T) {
+	tests := []struct {
+		value				[]byte
+		bit, start, end		int
+		want				int64
+	}{
+		{[]byte{0xff, 0xf0, 0x00}, 0, 0, -1, 12},
+		{[]byte{0x00, 0xff, 0xf0}, 1, 0, -1, 8},
+		{[]byte{0x00, 0xff, 0xf0}, 1, 2, -1, 16},
+		{[]byte{0x00, 0x00, 0x00}, 1, 0, -1, -1},
+		// a clear bit up to the end of the value is found after the value
+		{[]byte{0xff, 0xff}, 0, 0, -1, 16},
+		{[]byte{0xff, 0xff}, 0, 0, 1, -1},
+		{nil, 0, 0, -1, 0},
+	}
+
+	for _, tt := range tests {
+		pos, err := BitPos(tt.value, tt.bit, tt.start, tt.end)
+		if err != nil || pos != tt.want {
+			t.Errorf("BitPos(%x, %d, %d, %d) = %d, %v, want %d", tt.value, tt.bit, tt.start, tt.end, pos, err, tt.want)
+		}
+	}
+
+	if _, err := BitPos(nil, 2, 0, -1); err != ErrBitValue {
+		t.Errorf("BitPos of 2 returned %v, want ErrBitValue", err)
+	}
+}
+
+func TestBitOp(t *testing.T) {
+	a, b := []byte{0xf0, 0x0f}, []byte{0xff}
+
+	tests := []struct {
+		op		Op
+		values	[][]byte
+		want	[]byte
+	}{
+		{OpAnd, [][]byte{a, b}, []byte{0xf0, 0x00}},
+		{OpOr, [][]byte{a, b}, []byte{0xff, 0x0f}},
+		{OpXor, [][]byte{a, b}, []byte{0x0f, 0x0f}},
+		{OpNot, [][]byte{a}, []byte{0x0f, 0xf0}},
+	}
+
+	for _, tt := range tests {
+		if got, err := BitOp(tt.op, tt.values...); err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("BitOp(%d) = %x, %v, want %x", tt.op, got, err, tt.want)
+		}
+	}
+
+	if _, err := BitOp(OpNot, a, b); err != ErrBitOp {
+		t.Errorf("BitOp NOT with two sources returned %v, want ErrBitOp", err)
+	}
+
+	if _, err := BitOp(OpAnd); err != ErrBitOp {
+		t.Errorf("BitOp without source returned %v, want ErrBitOp", err)
+	}
+
+	if !bytes.Equal(a, []byte{0xf0, 0x0f}) {
+		t.Error("BitOp modified a source")
+	}
+}
+
+func TestPatch(t *testing.T) {
+	offset, bit, err := DecodePatch(EncodePatch(1<<32-1, 1))
+	if err != nil || offset != 1<<32-1 || bit != 1 {
+		t.Errorf("DecodePatch = %d, %d, %v", offset, bit, err)
+	}
+
+	if _, _, err = DecodePatch([]byte{1}); err != ErrPatch {
+		t.Errorf("DecodePatch of a short patch returned %v, want ErrPatch", err)
+	}
+}
@@ -0,0 +1,115 @@
+package geo
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	// Steps is the number of the bits used to encode the longitude and the latitude each,
+	// the 52 bits geohash fits exactly in the mantissa of the score of the sorted set
+	Steps = 26
+
+	// LonMin is the min longitude that can be indexed
+	LonMin = -180.0
+
+	// LonMax is the max longitude that can be indexed
+	LonMax = 180.0
+
+	// LatMin is the min latitude that can be indexed, the limit of the EPSG:900913 projection
+	LatMin = -85.05112878
+
+	// LatMax is the max latitude that can be indexed
+	LatMax = 85.05112878
+
+	// EarthRadius is the radius of the earth in meters used by the distance computation
+	EarthRadius = 6372797.560856
+)
+
+// ErrCoordinates is returned when the longitude or the latitude is out of range
+var ErrCoordinates = errors.New("invalid longitude or latitude")
+
+// Point represents a position on the earth
+type Point struct {
+	Longitude	float64
+	Latitude	float64
+}
+
+// Valid returns if the point can be indexed
+func (p Point) Valid() bool {
+	return p.Longitude >= LonMin && p.Longitude <= LonMax && p.Latitude >= LatMin && p.Latitude <= LatMax
+}
+
+// Encode returns the 52 bits geohash of the point, the longitude bits are at the odd positions
+func Encode(p Point) (uint64, error) {
+	if !p.Valid() {
+		return 0, ErrCoordinates
+	}
+
+	lat := scale(p.Latitude, LatMin, LatMax)
+	lon := scale(p.Longitude, LonMin, LonMax)
+
+	return interleave(lat, lon), nil
+}
+
+// Decode returns the center of the area represented by the 52 bits geohash
+func Decode(hash uint64) Point {
+	lat, lon := deinterleave(hash)
+
+	return Point{
+		Longitude:	math.Max(LonMin, math.Min(LonMax, LonMin+(float64(lon)+0.5)*(LonMax-LonMin)/(1<<Steps))),
+		Latitude:	math.Max(LatMin, math.Min(LatMax, LatMin+(float64(lat)+0.5)*(LatMax-LatMin)/(1<<Steps))),
+	}
+}
+
+// Distance returns the great circle distance in meters between p1 and p2
+func Distance(p1, p2 Point) float64 {
+	lat1, lat2 := radians(p1.Latitude), radians(p2.Latitude)
+	u := math.Sin((lat2 - lat1) / 2)
+	v := math.Sin(radians(p2.Longitude-p1.Longitude) / 2)
+
+	return 2 * EarthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1)*math.Cos(lat2)*v*v))
+}
+
+// latDistance returns the distance in meters between two latitudes along a meridian
+func latDistance(lat1, lat2 float64) float64 {
+	return EarthRadius * math.Abs(radians(lat2)-radians(lat1))
+}
+
+// scale returns the position of v in the range split into 2^Steps parts
+func scale(v, min, max float64) uint64 {
+	n := uint64((v - min) / (max - min) * (1 << Steps))
+	if n >= 1<<Steps {
+		n = 1<<Steps - 1
+	}
+
+	return n
+}
+
+// interleave returns the bits of lat at the even positions and the bits of lon at the odd positions
+func interleave(lat, lon uint64) uint64 {
+	var hash uint64
+	for i := uint(0); i < Steps; i++ {
+		hash |= (lat>>i&1)<<(2*i) | (lon>>i&1)<<(2*i+1)
+	}
+
+	return hash
+}
+
+// deinterleave is the inverse of interleave
+func deinterleave(hash uint64) (lat, lon uint64) {
+	for i := uint(0); i < Steps; i++ {
+		lat |= (hash >> (2 * i) & 1) << i
+		lon |= (hash >> (2*i + 1) & 1) << i
+	}
+
+	return
+}
+
+func radians(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func degrees(r float64) float64 {
+	return r * 180 / math.Pi
+}
@@ -0,0 +1,46 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+var (
+	palermo = Point{Longitude: 13.361389, Latitude: 38.115556}
+	catania = Point{Longitude: 15.087269, Latitude: 37.502669}
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, p := range []Point{palermo, catania, {0, 0}, {LonMin, LatMin}, {LonMax, LatMax}, {-122.27652, 37.805186}} {
+		hash, err := Encode(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hash >= 1<<(2*Steps) {
+			t.Errorf("Encode(%v) = %d, more than %d bits", p, hash, 2*Steps)
+		}
+
+		// the center of a 26 bits area is less than a meter away
+		if d := Distance(p, Decode(hash)); d > 1 {
+			t.Errorf("Decode(Encode(%v)) = %v, %f meters away", p, Decode(hash), d)
+		}
+	}
+
+	for _, p := range []Point{{181, 0}, {0, 86}, {-180.1, 0}, {0, -85.1}} {
+		if _, err := Encode(p); err != ErrCoordinates {
+			t.Errorf("Encode(%v) returned %v, want ErrCoordinates", p, err)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// the distance given by Redis for the same points, to a meter
+	if d := Distance(palermo, catania); math.Abs(d-166274.1516) > 1 {
+		t.Errorf("Distance(palermo, catania) = %f, want about 166274.1516", d)
+	}
+
+	if d := Distance(palermo, palermo); d != 0 {
+		t.Errorf("Distance to itself = %f, want 0", d)
+	}
+}
@@ -0,0 +1,241 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/HelloChenHZ/nutsdb/ds/zset"
+)
+
+var (
+	// ErrShape is returned when the query is neither a radius nor a box search
+	ErrShape = errors.New("exactly one of radius or width and height must be positive")
+
+	// ErrUnit is returned when the unit is not positive
+	ErrUnit = errors.New("invalid unit")
+
+	// ErrCount is returned when count is negative or ANY is set without count
+	ErrCount = errors.New("invalid count")
+)
+
+// Unit represents the unit of the distances, in meters
+type Unit float64
+
+const (
+	// Meters represents the meter unit
+	Meters Unit = 1
+
+	// Kilometers represents the kilometer unit
+	Kilometers Unit = 1000
+
+	// Miles represents the mile unit
+	Miles Unit = 1609.34
+
+	// Feet represents the foot unit
+	Feet Unit = 0.3048
+)
+
+// Sort represents the order of the search results
+type Sort int
+
+const (
+	// SortNone returns the results in no particular order
+	SortNone Sort = iota
+
+	// SortAsc returns the results from the nearest to the farthest
+	SortAsc
+
+	// SortDesc returns the results from the farthest to the nearest
+	SortDesc
+)
+
+// Query represents the options of the search
+type Query struct {
+	Center	Point	// the center of the search
+	Radius	float64	// the radius of the circle to search, in Unit
+	Width	float64	// the width of the box to search, in Unit
+	Height	float64	// the height of the box to search, in Unit
+	Unit	Unit
+	Sort	Sort
+	Count	int		// if greater than 0, at most Count results are returned
+	Any		bool	// with Count, the search stops as soon as Count results are found, not the nearest ones
+}
+
+// Location represents a member of the sorted set found by the search
+type Location struct {
+	Key			string
+	Value		[]byte
+	Point		Point
+	Distance	float64	// the distance to the center, in Unit
+	Hash		uint64
+}
+
+// Validate returns an error if the query is malformed
+func (q *Query) Validate() error {
+	if !q.Center.Valid() {
+		return ErrCoordinates
+	}
+
+	if q.Unit <= 0 {
+		return ErrUnit
+	}
+
+	radius := q.Radius > 0 && q.Width == 0 && q.Height == 0
+	box := q.Radius == 0 && q.Width > 0 && q.Height > 0
+	if radius == box {
+		return ErrShape
+	}
+
+	if q.Count < 0 || q.Any && q.Count == 0 {
+		return ErrCount
+	}
+
+	return nil
+}
+
+// Search returns the members of the sorted set within the circle or the box of the query
+// Like Redis, the results are sorted by ascending distance when Count is set without Any and Sort
+func Search(ss *zset.SortedSet, q *Query) ([]*Location, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	sortBy := q.Sort
+	if q.Count > 0 && !q.Any && sortBy == SortNone {
+		sortBy = SortAsc
+	}
+
+	var locations []*Location
+
+	for _, r := range q.scoreRanges() {
+		for _, node := range ss.ZRangeByScore(zset.SCORE(r[0]), zset.SCORE(r[1]), &zset.GetByScoreRangeOptions{ExcludeEnd: true}) {
+			hash := uint64(node.Score())
+			p := Decode(hash)
+
+			distance, ok := q.contains(p)
+			if !ok {
+				continue
+			}
+
+			locations = append(locations, &Location{
+				Key:		node.Key(),
+				Value:		node.Value,
+				Point:		p,
+				Distance:	distance / float64(q.Unit),
+				Hash:		hash,
+			})
+
+			if q.Any && len(locations) == q.Count {
+				return sortLocations(locations, sortBy), nil
+			}
+		}
+	}
+
+	locations = sortLocations(locations, sortBy)
+	if q.Count > 0 && len(locations) > q.Count {
+		locations = locations[:q.Count]
+	}
+
+	return locations, nil
+}
+
+// contains returns the distance in meters between the center and p, and if p is within the shape of the query
+func (q *Query) contains(p Point) (float64, bool) {
+	if q.Radius > 0 {
+		distance := Distance(q.Center, p)
+		return distance, distance <= q.Radius*float64(q.Unit)
+	}
+
+	if latDistance(q.Center.Latitude, p.Latitude) > q.Height*float64(q.Unit)/2 {
+		return 0, false
+	}
+
+	if Distance(Point{q.Center.Longitude, p.Latitude}, p) > q.Width*float64(q.Unit)/2 {
+		return 0, false
+	}
+
+	return Distance(q.Center, p), true
+}
+
+// scoreRanges returns the [min, max) score ranges of the geohash areas covering the shape of the query
+// The step is the finest one whose areas are larger than the bounding box of the shape,
+// so that at most 2x2 areas have to be searched
+func (q *Query) scoreRanges() [][2]float64 {
+	latExtent, lonExtent := q.Radius, q.Radius
+	if q.Radius == 0 {
+		latExtent, lonExtent = q.Height/2, q.Width/2
+	}
+
+	latDelta := degrees(latExtent * float64(q.Unit) / EarthRadius)
+	minLat := math.Max(LatMin, q.Center.Latitude-latDelta)
+	maxLat := math.Min(LatMax, q.Center.Latitude+latDelta)
+
+	// the longitude span of the shape is the widest at the latitude the farthest from the equator,
+	// both the great circle and the parallel bounds are taken since the shapes are measured either way
+	edge := radians(math.Max(math.Abs(q.Center.Latitude-latDelta), math.Abs(q.Center.Latitude+latDelta)))
+	a := lonExtent * float64(q.Unit) / EarthRadius
+	lonDelta := 360.0
+	if s1, s2 := math.Sin(a)/math.Cos(edge), math.Sin(a/2)/math.Cos(edge); edge < math.Pi/2 && a < math.Pi/2 && s1 < 1 && s2 < 1 {
+		lonDelta = degrees(math.Max(math.Asin(s1), 2*math.Asin(s2)))
+	}
+
+	step := uint(Steps)
+	for step > 0 && ((LonMax-LonMin)/float64(uint64(1)<<step) < 2*lonDelta || (LatMax-LatMin)/float64(uint64(1)<<step) < maxLat-minLat) {
+		step--
+	}
+
+	n := int64(1) << step
+	latSize, lonSize := (LatMax-LatMin)/float64(n), (LonMax-LonMin)/float64(n)
+
+	latLo, latHi := clampIndex(int64(math.Floor((minLat-LatMin)/latSize)), n), clampIndex(int64(math.Floor((maxLat-LatMin)/latSize)), n)
+	lonLo, lonHi := int64(0), n-1
+	if lonDelta < 180 {
+		lonLo = int64(math.Floor((q.Center.Longitude - lonDelta - LonMin) / lonSize))
+		lonHi = int64(math.Floor((q.Center.Longitude + lonDelta - LonMin) / lonSize))
+	}
+
+	shift := 2 * (Steps - step)
+	seen := make(map[uint64]struct{})
+
+	var ranges [][2]float64
+	for lat := latLo; lat <= latHi; lat++ {
+		for lon := lonLo; lon <= lonHi; lon++ {
+			// the areas across the antimeridian wrap around
+			hash := interleave(uint64(lat), uint64((lon%n+n)%n)) << shift
+			if _, ok := seen[hash]; ok {
+				continue
+			}
+
+			seen[hash] = struct{}{}
+			ranges = append(ranges, [2]float64{float64(hash), float64(hash + 1<<shift)})
+		}
+	}
+
+	return ranges
+}
+
+// clampIndex returns i within [0, n)
+func clampIndex(i, n int64) int64 {
+	if i < 0 {
+		return 0
+	}
+
+	if i >= n {
+		return n - 1
+	}
+
+	return i
+}
+
+// sortLocations sorts the locations by distance in the given order
+func sortLocations(locations []*Location, by Sort) []*Location {
+	switch by {
+	case SortAsc:
+		sort.SliceStable(locations, func(i, j int) bool { return locations[i].Distance < locations[j].Distance })
+	case SortDesc:
+		sort.SliceStable(locations, func(i, j int) bool { return locations[i].Distance > locations[j].Distance })
+	}
+
+	return locations
+}
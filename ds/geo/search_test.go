@@ -0,0 +1,138 @@
+package geo
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/HelloChenHZ/nutsdb/ds/zset"
+)
+
+// newGeoSet returns a sorted set of the given points by key
+func newGeoSet(t *testing.T, points map[string]Point) *zset.SortedSet {
+	t.Helper()
+
+	ss := zset.New()
+	for key, p := range points {
+		hash, err := Encode(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = ss.Put(key, zset.SCORE(hash), nil)
+	}
+
+	return ss
+}
+
+// locationKeys returns the keys of the locations
+func locationKeys(locations []*Location) []string {
+	keys := make([]string, len(locations))
+	for i, l := range locations {
+		keys[i] = l.Key
+	}
+
+	return keys
+}
+
+func TestSearch(t *testing.T) {
+	ss := newGeoSet(t, map[string]Point{"Palermo": palermo, "Catania": catania, "Rome": {12.496366, 41.902782}})
+	center := Point{Longitude: 15, Latitude: 37}
+
+	locations, err := Search(ss, &Query{Center: center, Radius: 200, Unit: Kilometers, Sort: SortAsc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := locationKeys(locations); !reflect.DeepEqual(got, []string{"Catania", "Palermo"}) {
+		t.Fatalf("Search by radius = %v, want [Catania Palermo]", got)
+	}
+
+	// the distances given by Redis for the same query
+	if d := locations[0].Distance; d < 56.44 || d > 56.45 {
+		t.Errorf("distance to Catania = %f, want 56.4413", d)
+	}
+
+	if locations, _ = Search(ss, &Query{Center: center, Radius: 200, Unit: Kilometers, Sort: SortDesc}); locations[0].Key != "Palermo" {
+		t.Errorf("Search SortDesc = %v", locationKeys(locations))
+	}
+
+	if locations, _ = Search(ss, &Query{Center: center, Radius: 100, Unit: Kilometers}); !reflect.DeepEqual(locationKeys(locations), []string{"Catania"}) {
+		t.Errorf("Search by a small radius = %v, want [Catania]", locationKeys(locations))
+	}
+
+	if locations, _ = Search(ss, &Query{Center: center, Width: 400, Height: 400, Unit: Kilometers, Count: 1}); !reflect.DeepEqual(locationKeys(locations), []string{"Catania"}) {
+		t.Errorf("Search by box with count = %v, want [Catania]", locationKeys(locations))
+	}
+
+	if locations, _ = Search(ss, &Query{Center: center, Width: 2000, Height: 2000, Unit: Kilometers, Count: 2, Any: true}); len(locations) != 2 {
+		t.Errorf("Search with count and any returned %d locations, want 2", len(locations))
+	}
+}
+
+func TestSearchMatchesScan(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	points := make(map[string]Point)
+	for i := 0; i < 2000; i++ {
+		points[string(rune('A'+i%26))+string(rune(i))] = Point{Longitude: r.Float64()*360 - 180, Latitude: r.Float64()*170 - 85}
+	}
+
+	ss := newGeoSet(t, points)
+
+	for i := 0; i < 50; i++ {
+		q := &Query{Center: Point{Longitude: r.Float64()*360 - 180, Latitude: r.Float64()*160 - 80}, Unit: Kilometers}
+		if i%2 == 0 {
+			q.Radius = r.Float64() * 3000
+		} else {
+			q.Width, q.Height = r.Float64()*5000, r.Float64()*5000
+		}
+
+		locations, err := Search(ss, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := locationKeys(locations)
+		sort.Strings(got)
+
+		// the points are compared at the centers of their geohash areas, like the search does
+		want := []string{}
+		for key, p := range points {
+			hash, _ := Encode(p)
+			if _, ok := q.contains(Decode(hash)); ok {
+				want = append(want, key)
+			}
+		}
+
+		sort.Strings(want)
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("query %+v found %d points, want %d", q, len(got), len(want))
+		}
+	}
+}
+
+func TestQuery_Validate(t *testing.T) {
+	tests := []struct {
+		query	Query
+		err		error
+	}{
+		{Query{Radius: 1, Unit: Meters}, nil},
+		{Query{Width: 1, Height: 1, Unit: Meters}, nil},
+		{Query{Center: Point{Longitude: 200}, Radius: 1, Unit: Meters}, ErrCoordinates},
+		{Query{Radius: 1}, ErrUnit},
+		{Query{Unit: Meters}, ErrShape},
+		{Query{Radius: 1, Width: 1, Height: 1, Unit: Meters}, ErrShape},
+		{Query{Width: 1, Unit: Meters}, ErrShape},
+		{Query{Radius: 1, Unit: Meters, Count: -1}, ErrCount},
+		{Query{Radius: 1, Unit: Meters, Any: true}, ErrCount},
+	}
+
+	for _, tt := range tests {
+		if err := tt.query.Validate(); err != tt.err {
+			t.Errorf("Validate(%+v) returned %v, want %v", tt.query, err, tt.err)
+		}
+	}
+}
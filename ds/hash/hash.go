@@ -0,0 +1,212 @@
+package hash
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	// ErrHashNotFound is returned when the hash at given key not exists
+	ErrHashNotFound = errors.New("the hash not found")
+
+	// ErrFieldNotFound is returned when the field not exists in the hash
+	ErrFieldNotFound = errors.New("the field not found")
+
+	// ErrNotInteger is returned when the value of the field is not an integer or out of the int64 range
+	ErrNotInteger = errors.New("value is not an integer or out of range")
+
+	// ErrIncrOverflow is returned when the increment or decrement would overflow
+	ErrIncrOverflow = errors.New("increment or decrement would overflow")
+)
+
+// Hash represents the Hash, a map of fields and values stored at every key
+type Hash struct {
+	M		map[string]map[string][]byte
+	owned	map[string]struct{}	// the keys written since Clone, nil if the hash is not a clone
+}
+
+// New returns a newly initialized Hash Object that implements the Hash
+func New() *Hash {
+	return &Hash{
+		M: make(map[string]map[string][]byte),
+	}
+}
+
+// Clone returns a copy of the hash in O(number of keys), the values are shared
+// The fields of a key are copied on the first write of the copy to the key, so h must not be modified anymore
+func (h *Hash) Clone() *Hash {
+	c := &Hash{
+		M:		make(map[string]map[string][]byte, len(h.M)),
+		owned:	make(map[string]struct{}),
+	}
+
+	for key, fields := range h.M {
+		c.M[key] = fields
+	}
+
+	return c
+}
+
+// own copies the fields of the hash stored at key if they are still shared with the cloned hash
+func (h *Hash) own(key string) {
+	if h.owned == nil {
+		return
+	}
+
+	if _, ok := h.owned[key]; ok {
+		return
+	}
+
+	if fields, ok := h.M[key]; ok {
+		copied := make(map[string][]byte, len(fields))
+		for field, value := range fields {
+			copied[field] = value
+		}
+
+		h.M[key] = copied
+	}
+
+	h.owned[key] = struct{}{}
+}
+
+// HSet sets field in the hash stored at key to value
+// It returns true if field is a new field in the hash, false if the value is updated
+func (h *Hash) HSet(key, field string, value []byte) bool {
+	h.own(key)
+
+	if _, ok := h.M[key]; !ok {
+		h.M[key] = make(map[string][]byte)
+	}
+
+	_, exists := h.M[key][field]
+	h.M[key][field] = value
+
+	return !exists
+}
+
+// HGet returns the value associated with field in the hash stored at key
+func (h *Hash) HGet(key, field string) ([]byte, error) {
+	if _, ok := h.M[key]; !ok {
+		return nil, ErrHashNotFound
+	}
+
+	value, ok := h.M[key][field]
+	if !ok {
+		return nil, ErrFieldNotFound
+	}
+
+	return value, nil
+}
+
+// HMGet returns the values associated with the specified fields in the hash stored at key
+// For every field that does not exist in the hash, a nil value is returned
+func (h *Hash) HMGet(key string, fields ...string) [][]byte {
+	values := make([][]byte, len(fields))
+	for i, field := range fields {
+		values[i] = h.M[key][field]
+	}
+
+	return values
+}
+
+// HDel removes the specified fields from the hash stored at key
+// It returns the number of fields that were removed, the hash is removed when it becomes empty
+func (h *Hash) HDel(key string, fields ...string) int {
+	if _, ok := h.M[key]; !ok {
+		return 0
+	}
+
+	h.own(key)
+
+	removed := 0
+	for _, field := range fields {
+		if _, ok := h.M[key][field]; ok {
+			delete(h.M[key], field)
+			removed++
+		}
+	}
+
+	if len(h.M[key]) == 0 {
+		delete(h.M, key)
+	}
+
+	return removed
+}
+
+// HExists returns if field is an existing field in the hash stored at key
+func (h *Hash) HExists(key, field string) bool {
+	_, ok := h.M[key][field]
+
+	return ok
+}
+
+// HHasKey returns if has the hash at given key
+func (h *Hash) HHasKey(key string) bool {
+	_, ok := h.M[key]
+
+	return ok
+}
+
+// HLen returns the number of fields contained in the hash stored at key
+func (h *Hash) HLen(key string) int {
+	return len(h.M[key])
+}
+
+// HKeys returns all field names in the hash stored at key
+func (h *Hash) HKeys(key string) (fields []string) {
+	for field := range h.M[key] {
+		fields = append(fields, field)
+	}
+
+	return
+}
+
+// HVals returns all values in the hash stored at key
+func (h *Hash) HVals(key string) (values [][]byte) {
+	for _, value := range h.M[key] {
+		values = append(values, value)
+	}
+
+	return
+}
+
+// HGetAll returns all fields and values of the hash stored at key
+func (h *Hash) HGetAll(key string) map[string][]byte {
+	all := make(map[string][]byte, len(h.M[key]))
+	for field, value := range h.M[key] {
+		all[field] = value
+	}
+
+	return all
+}
+
+// HIncrBy increments the number stored at field in the hash stored at key by increment
+// If field does not exist the value is set to 0 before the operation is performed
+func (h *Hash) HIncrBy(key, field string, increment int64) (int64, error) {
+	n, err := IncrBy(h.M[key][field], increment)
+	if err != nil {
+		return 0, err
+	}
+
+	h.HSet(key, field, []byte(strconv.FormatInt(n, 10)))
+
+	return n, nil
+}
+
+// IncrBy returns the integer value plus increment, a nil value is treated as 0
+func IncrBy(value []byte, increment int64) (int64, error) {
+	var n int64
+
+	if value != nil {
+		var err error
+		if n, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+
+	if increment > 0 && n > (1<<63-1)-increment || increment < 0 && n < (-1<<63)-increment {
+		return 0, ErrIncrOverflow
+	}
+
+	return n + increment, nil
+}
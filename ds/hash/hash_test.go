@@ -0,0 +1,97 @@
+package hash
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestHash_SetGetDel(t *testing.T) {
+	h := New()
+
+	if !h.HSet("k", "a", []byte("1")) || h.HSet("k", "a", []byte("2")) {
+		t.Error("HSet did not report the new field")
+	}
+
+	_ = h.HSet("k", "b", []byte("3"))
+
+	if value, err := h.HGet("k", "a"); err != nil || string(value) != "2" {
+		t.Errorf("HGet(a) = %s, %v, want 2", value, err)
+	}
+
+	if _, err := h.HGet("k", "missing"); err != ErrFieldNotFound {
+		t.Errorf("HGet of a missing field returned %v, want ErrFieldNotFound", err)
+	}
+
+	if _, err := h.HGet("missing", "a"); err != ErrHashNotFound {
+		t.Errorf("HGet of a missing hash returned %v, want ErrHashNotFound", err)
+	}
+
+	if values := h.HMGet("k", "b", "missing"); string(values[0]) != "3" || values[1] != nil {
+		t.Errorf("HMGet = %q", values)
+	}
+
+	fields := h.HKeys("k")
+	sort.Strings(fields)
+	if !reflect.DeepEqual(fields, []string{"a", "b"}) || h.HLen("k") != 2 || len(h.HVals("k")) != 2 {
+		t.Errorf("HKeys = %v, HLen = %d", fields, h.HLen("k"))
+	}
+
+	if all := h.HGetAll("k"); len(all) != 2 || string(all["b"]) != "3" {
+		t.Errorf("HGetAll = %q", all)
+	}
+
+	if n := h.HDel("k", "a", "missing"); n != 1 || h.HExists("k", "a") {
+		t.Errorf("HDel = %d, want 1", n)
+	}
+
+	// the hash is removed with its last field
+	if n := h.HDel("k", "b"); n != 1 || h.HHasKey("k") {
+		t.Error("the hash without field still exists")
+	}
+}
+
+func TestHash_Clone(t *testing.T) {
+	h := New()
+	_ = h.HSet("k", "a", []byte("1"))
+
+	c := h.Clone()
+	_ = c.HSet("k", "b", []byte("2"))
+	c.HDel("k", "a")
+
+	if !h.HExists("k", "a") || h.HExists("k", "b") {
+		t.Error("writing the clone changed the hash")
+	}
+}
+
+func TestIncrBy(t *testing.T) {
+	tests := []struct {
+		value		[]byte
+		increment	int64
+		want		int64
+		err			error
+	}{
+		{nil, 5, 5, nil},
+		{[]byte("10"), -3, 7, nil},
+		{[]byte("abc"), 1, 0, ErrNotInteger},
+		{[]byte(strconv.FormatInt(1<<63-1, 10)), 1, 0, ErrIncrOverflow},
+		{[]byte(strconv.FormatInt(-1<<63, 10)), -1, 0, ErrIncrOverflow},
+		{[]byte(strconv.FormatInt(-1<<63, 10)), 1, -1<<63 + 1, nil},
+	}
+
+	for _, tt := range tests {
+		if n, err := IncrBy(tt.value, tt.increment); n != tt.want || err != tt.err {
+			t.Errorf("IncrBy(%s, %d) = %d, %v, want %d, %v", tt.value, tt.increment, n, err, tt.want, tt.err)
+		}
+	}
+
+	h := New()
+	if n, err := h.HIncrBy("k", "n", 3); err != nil || n != 3 {
+		t.Errorf("HIncrBy = %d, %v, want 3", n, err)
+	}
+
+	if value, _ := h.HGet("k", "n"); string(value) != "3" {
+		t.Errorf("HIncrBy stored %s, want 3", value)
+	}
+}
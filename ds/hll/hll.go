@@ -0,0 +1,143 @@
+package hll
+
+import "errors"
+
+// ErrKeysEmpty is returned when no key is given
+var ErrKeysEmpty = errors.New("keys empty")
+
+// HLL represents the HyperLogLog, a cardinality estimation sketch stored at every key
+type HLL struct {
+	M		map[string]*Sketch
+	owned	map[string]struct{}	// the keys written since Clone, nil if the HyperLogLog is not a clone
+}
+
+// New returns a newly initialized HLL Object that implements the HyperLogLog
+func New() *HLL {
+	return &HLL{
+		M: make(map[string]*Sketch),
+	}
+}
+
+// Clone returns a copy of the HyperLogLog in O(number of keys)
+// The sketch of a key is copied on the first write of the copy to the key, so h must not be modified anymore
+func (h *HLL) Clone() *HLL {
+	c := &HLL{
+		M:		make(map[string]*Sketch, len(h.M)),
+		owned:	make(map[string]struct{}),
+	}
+
+	for key, s := range h.M {
+		c.M[key] = s
+	}
+
+	return c
+}
+
+// own copies the sketch stored at key if it is still shared with the cloned HyperLogLog
+func (h *HLL) own(key string) {
+	if h.owned == nil {
+		return
+	}
+
+	if _, ok := h.owned[key]; ok {
+		return
+	}
+
+	if s, ok := h.M[key]; ok {
+		h.M[key] = s.Clone()
+	}
+
+	h.owned[key] = struct{}{}
+}
+
+// PFAdd adds the elements to the sketch stored at key, the sketch is created if key not exists
+// It returns true if a register was updated or the sketch was created
+func (h *HLL) PFAdd(key string, elements ...[]byte) bool {
+	h.own(key)
+
+	s, ok := h.M[key]
+	if !ok {
+		s = NewSketch()
+		h.M[key] = s
+	}
+
+	changed := !ok
+	for _, element := range elements {
+		if s.Add(element) {
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// PFCount returns the estimated cardinality of the union of the sketches stored at keys
+// The keys that not exist are treated as empty sketches
+func (h *HLL) PFCount(keys ...string) (uint64, error) {
+	if len(keys) == 0 {
+		return 0, ErrKeysEmpty
+	}
+
+	if len(keys) == 1 {
+		if s, ok := h.M[keys[0]]; ok {
+			return s.Count(), nil
+		}
+
+		return 0, nil
+	}
+
+	union := NewSketch()
+	for _, key := range keys {
+		if s, ok := h.M[key]; ok {
+			union.Merge(s)
+		}
+	}
+
+	return union.Count(), nil
+}
+
+// PFMerge merges the sketches stored at srcs into the sketch stored at dst, the sketch is created if dst not exists
+func (h *HLL) PFMerge(dst string, srcs ...string) {
+	h.own(dst)
+
+	s, ok := h.M[dst]
+	if !ok {
+		s = NewSketch()
+		h.M[dst] = s
+	}
+
+	for _, src := range srcs {
+		if other, ok := h.M[src]; ok && src != dst {
+			s.Merge(other)
+		}
+	}
+}
+
+// Set stores rank at the register index of the sketch stored at key, the sketch is created if key not exists
+func (h *HLL) Set(key string, index uint16, rank uint8) bool {
+	h.own(key)
+
+	if _, ok := h.M[key]; !ok {
+		h.M[key] = NewSketch()
+	}
+
+	return h.M[key].Set(index, rank)
+}
+
+// Merge merges the sketch into the sketch stored at key, the sketch is created if key not exists
+func (h *HLL) Merge(key string, s *Sketch) {
+	h.own(key)
+
+	if _, ok := h.M[key]; !ok {
+		h.M[key] = NewSketch()
+	}
+
+	h.M[key].Merge(s)
+}
+
+// HasKey returns if the sketch stored at key exists
+func (h *HLL) HasKey(key string) bool {
+	_, ok := h.M[key]
+
+	return ok
+}
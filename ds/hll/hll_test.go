@@ -0,0 +1,25 @@
+package hll
+
+import "testing"
+
+func TestHLL_Clone(t *testing.T) {
+	h := New()
+	h.PFAdd("a", []byte("1"))
+	h.PFAdd("b", []byte("2"))
+
+	c := h.Clone()
+	c.PFAdd("a", []byte("3"))
+	c.PFMerge("b", "a")
+
+	if n, _ := h.PFCount("a"); n != 1 {
+		t.Errorf("PFAdd on the clone changed the HyperLogLog, PFCount = %d", n)
+	}
+
+	if n, _ := h.PFCount("b"); n != 1 {
+		t.Errorf("PFMerge on the clone changed the HyperLogLog, PFCount = %d", n)
+	}
+
+	if n, _ := c.PFCount("b"); n != 3 {
+		t.Errorf("PFCount of the clone = %d, want 3", n)
+	}
+}
@@ -0,0 +1,420 @@
+package hll
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	// P is the number of the hash bits used to select the register
+	P = 14
+
+	// M is the number of the registers
+	M = 1 << P
+
+	// Q is the number of the hash bits used to compute the rank
+	Q = 64 - P
+
+	// StdError is the standard error of the estimation, 1.04/sqrt(M)
+	StdError = 0.0081
+
+	// SparseMaxEntries is the max number of the registers kept by the sparse representation,
+	// above it the sketch is converted to the dense representation which has the same memory footprint
+	SparseMaxEntries = denseSize / 4
+
+	// RegisterSize is the size of the encoded register
+	RegisterSize = 3
+
+	// denseSize is the size of the 6 bits packed registers, the extra byte makes the last register readable as two bytes
+	denseSize = M*6/8 + 1
+
+	encodingSparse byte = 1
+	encodingDense  byte = 2
+
+	hashSeed = 0xadc83b19
+	alphaInf = 0.721347520444481703680 // 0.5/ln(2)
+)
+
+var (
+	// ErrSketch is returned when the encoded sketch is malformed
+	ErrSketch = errors.New("err hll sketch")
+
+	// ErrRegister is returned when the encoded register is malformed
+	ErrRegister = errors.New("err hll register")
+)
+
+// Sketch represents a HyperLogLog sketch
+// It starts with a sparse representation, a sorted list of the non zero registers,
+// and is converted to a dense representation of 6 bits packed registers once the list grows too large
+type Sketch struct {
+	sparse	[]uint32 // index<<8 | rank, sorted by index
+	dense	[]byte
+}
+
+// NewSketch returns a newly initialized empty sketch
+func NewSketch() *Sketch {
+	return &Sketch{}
+}
+
+// Register returns the register index and the rank of the given element
+func Register(element []byte) (index uint16, rank uint8) {
+	hash := murmurHash64A(element, hashSeed)
+	index = uint16(hash & (M - 1))
+
+	// the bit set at the position Q bounds the rank to Q+1
+	hash = hash>>P | 1<<Q
+
+	return index, uint8(bits.TrailingZeros64(hash)) + 1
+}
+
+// EncodeRegister returns the encoded register at given index and rank
+func EncodeRegister(index uint16, rank uint8) []byte {
+	buf := make([]byte, RegisterSize)
+	binary.LittleEndian.PutUint16(buf[0:2], index)
+	buf[2] = rank
+
+	return buf
+}
+
+// DecodeRegister returns the register index and the rank at given encoded register
+func DecodeRegister(buf []byte) (index uint16, rank uint8, err error) {
+	if len(buf) != RegisterSize {
+		return 0, 0, ErrRegister
+	}
+
+	index, rank = binary.LittleEndian.Uint16(buf[0:2]), buf[2]
+	if index >= M || rank > Q+1 {
+		return 0, 0, ErrRegister
+	}
+
+	return index, rank, nil
+}
+
+// IsSparse returns if the sketch uses the sparse representation
+func (s *Sketch) IsSparse() bool {
+	return s.dense == nil
+}
+
+// Add adds the element to the sketch, it returns true if a register was updated
+func (s *Sketch) Add(element []byte) bool {
+	return s.Set(Register(element))
+}
+
+// Get returns the rank stored at the register index
+func (s *Sketch) Get(index uint16) uint8 {
+	if s.dense != nil {
+		return denseGet(s.dense, int(index))
+	}
+
+	i := s.search(index)
+	if i < len(s.sparse) && uint16(s.sparse[i]>>8) == index {
+		return uint8(s.sparse[i])
+	}
+
+	return 0
+}
+
+// Set stores rank at the register index if it is greater than the current one
+// It returns true if the register was updated
+func (s *Sketch) Set(index uint16, rank uint8) bool {
+	if s.dense != nil {
+		if denseGet(s.dense, int(index)) >= rank {
+			return false
+		}
+
+		denseSet(s.dense, int(index), rank)
+		return true
+	}
+
+	i := s.search(index)
+	if i < len(s.sparse) && uint16(s.sparse[i]>>8) == index {
+		if uint8(s.sparse[i]) >= rank {
+			return false
+		}
+
+		s.sparse[i] = uint32(index)<<8 | uint32(rank)
+		return true
+	}
+
+	if rank == 0 {
+		return false
+	}
+
+	s.sparse = append(s.sparse, 0)
+	copy(s.sparse[i+1:], s.sparse[i:])
+	s.sparse[i] = uint32(index)<<8 | uint32(rank)
+
+	if len(s.sparse) > SparseMaxEntries {
+		s.toDense()
+	}
+
+	return true
+}
+
+// Merge merges other into the sketch, every register keeps the max rank of both
+func (s *Sketch) Merge(other *Sketch) {
+	if other.dense == nil {
+		for _, r := range other.sparse {
+			s.Set(uint16(r>>8), uint8(r))
+		}
+
+		return
+	}
+
+	if s.dense == nil {
+		s.toDense()
+	}
+
+	for i := 0; i < M; i++ {
+		if rank := denseGet(other.dense, i); rank > denseGet(s.dense, i) {
+			denseSet(s.dense, i, rank)
+		}
+	}
+}
+
+// Count returns the estimated cardinality of the sketch
+// It uses the estimator of Otmar Ertl, which needs no bias correction for small or large cardinalities
+func (s *Sketch) Count() uint64 {
+	var histogram [Q + 2]int
+
+	if s.dense == nil {
+		histogram[0] = M - len(s.sparse)
+		for _, r := range s.sparse {
+			histogram[uint8(r)]++
+		}
+	} else {
+		for i := 0; i < M; i++ {
+			histogram[denseGet(s.dense, i)]++
+		}
+	}
+
+	z := M * tau(float64(M-histogram[Q+1])/M)
+	for k := Q; k >= 1; k-- {
+		z += float64(histogram[k])
+		z *= 0.5
+	}
+
+	z += M * sigma(float64(histogram[0])/M)
+
+	return uint64(math.Round(alphaInf * M * M / z))
+}
+
+// Size returns the size of the encoded sketch
+func (s *Sketch) Size() int {
+	if s.dense != nil {
+		return 1 + denseSize
+	}
+
+	return 1 + len(s.sparse)*RegisterSize
+}
+
+// Clone returns a copy of the sketch
+func (s *Sketch) Clone() *Sketch {
+	c := &Sketch{}
+	if s.dense != nil {
+		c.dense = append([]byte(nil), s.dense...)
+	} else if len(s.sparse) > 0 {
+		c.sparse = append([]uint32(nil), s.sparse...)
+	}
+
+	return c
+}
+
+// Marshal returns the encoded sketch
+//
+//  the sparse sketch format:
+//  |------------------------------------|
+//  | encoding | index  |  rank  |  ...  |
+//  |------------------------------------|
+//  |   byte   | uint16 |  byte  |  ...  |
+//  |------------------------------------|
+//
+//  the dense sketch format:
+//  |------------------------------------|
+//  | encoding |  6 bits packed registers |
+//  |------------------------------------|
+//
+func (s *Sketch) Marshal() []byte {
+	if s.dense != nil {
+		buf := make([]byte, 1+denseSize)
+		buf[0] = encodingDense
+		copy(buf[1:], s.dense)
+
+		return buf
+	}
+
+	buf := make([]byte, 1, 1+len(s.sparse)*RegisterSize)
+	buf[0] = encodingSparse
+	for _, r := range s.sparse {
+		buf = append(buf, EncodeRegister(uint16(r>>8), uint8(r))...)
+	}
+
+	return buf
+}
+
+// Unmarshal returns the sketch at given encoded sketch
+func Unmarshal(buf []byte) (*Sketch, error) {
+	if len(buf) == 0 {
+		return nil, ErrSketch
+	}
+
+	s := NewSketch()
+
+	switch buf[0] {
+	case encodingSparse:
+		if (len(buf)-1)%RegisterSize != 0 {
+			return nil, ErrSketch
+		}
+
+		for i := 1; i < len(buf); i += RegisterSize {
+			index, rank, err := DecodeRegister(buf[i : i+RegisterSize])
+			if err != nil {
+				return nil, ErrSketch
+			}
+
+			s.Set(index, rank)
+		}
+	case encodingDense:
+		if len(buf) != 1+denseSize {
+			return nil, ErrSketch
+		}
+
+		s.dense = append([]byte(nil), buf[1:]...)
+		for i := 0; i < M; i++ {
+			if denseGet(s.dense, i) > Q+1 {
+				return nil, ErrSketch
+			}
+		}
+	default:
+		return nil, ErrSketch
+	}
+
+	return s, nil
+}
+
+// search returns the position of the register index in the sparse list, or where it would be inserted
+func (s *Sketch) search(index uint16) int {
+	return sort.Search(len(s.sparse), func(i int) bool {
+		return uint16(s.sparse[i]>>8) >= index
+	})
+}
+
+// toDense converts the sketch to the dense representation
+func (s *Sketch) toDense() {
+	s.dense = make([]byte, denseSize)
+	for _, r := range s.sparse {
+		denseSet(s.dense, int(r>>8), uint8(r))
+	}
+
+	s.sparse = nil
+}
+
+// denseGet returns the 6 bits register at index i
+func denseGet(regs []byte, i int) uint8 {
+	b, fb := i*6/8, uint(i*6%8)
+
+	return uint8((uint16(regs[b])>>fb | uint16(regs[b+1])<<(8-fb)) & 63)
+}
+
+// denseSet stores the 6 bits register at index i
+func denseSet(regs []byte, i int, v uint8) {
+	b, fb := i*6/8, uint(i*6%8)
+
+	regs[b] &^= 63 << fb
+	regs[b] |= v << fb
+	regs[b+1] &^= 63 >> (8 - fb)
+	regs[b+1] |= v >> (8 - fb)
+}
+
+// sigma is the helper function of the estimator for the registers equal to 0
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+
+	y, z := 1.0, x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+// tau is the helper function of the estimator for the registers equal to Q+1
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// murmurHash64A returns the 64 bits MurmurHash2 of data
+func murmurHash64A(data []byte, seed uint64) uint64 {
+	const (
+		m = 0xc6a4a7935bd1e995
+		r = 47
+	)
+
+	h := seed ^ uint64(len(data))*m
+
+	n := len(data) / 8
+	for i := 0; i < n; i++ {
+		k := binary.LittleEndian.Uint64(data[i*8:])
+		k *= m
+		k ^= k >> r
+		k *= m
+
+		h ^= k
+		h *= m
+	}
+
+	tail := data[n*8:]
+	switch len(tail) {
+	case 7:
+		h ^= uint64(tail[6]) << 48
+		fallthrough
+	case 6:
+		h ^= uint64(tail[5]) << 40
+		fallthrough
+	case 5:
+		h ^= uint64(tail[4]) << 32
+		fallthrough
+	case 4:
+		h ^= uint64(tail[3]) << 24
+		fallthrough
+	case 3:
+		h ^= uint64(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint64(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint64(tail[0])
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+
+	return h
+}
@@ -0,0 +1,135 @@
+package hll
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// newFilledSketch returns a sketch of the elements from to to-1
+func newFilledSketch(from, to int) *Sketch {
+	s := NewSketch()
+	for i := from; i < to; i++ {
+		s.Add([]byte(fmt.Sprintf("element-%d", i)))
+	}
+
+	return s
+}
+
+// checkEstimate fails the test if the estimate is not within 4 standard errors of n
+func checkEstimate(t *testing.T, name string, estimate uint64, n int) {
+	t.Helper()
+
+	if diff := math.Abs(float64(estimate)-float64(n)) / float64(n); diff > 4*StdError {
+		t.Errorf("%s: estimated %d for %d elements, error %.4f", name, estimate, n, diff)
+	}
+}
+
+func TestSketch_Count(t *testing.T) {
+	if n := NewSketch().Count(); n != 0 {
+		t.Errorf("Count of an empty sketch = %d, want 0", n)
+	}
+
+	for _, n := range []int{10, 1000, 100000} {
+		s := newFilledSketch(0, n)
+		checkEstimate(t, fmt.Sprint(n), s.Count(), n)
+
+		// adding known elements changes nothing
+		if s.Add([]byte("element-0")) {
+			t.Errorf("%d: adding a known element updated a register", n)
+		}
+	}
+}
+
+func TestSketch_Dense(t *testing.T) {
+	s := newFilledSketch(0, 10)
+	if !s.IsSparse() {
+		t.Error("a small sketch is not sparse")
+	}
+
+	s = newFilledSketch(0, 20000)
+	if s.IsSparse() {
+		t.Error("a large sketch is still sparse")
+	}
+
+	if s.Size() != 1+denseSize {
+		t.Errorf("Size of a dense sketch = %d, want %d", s.Size(), 1+denseSize)
+	}
+}
+
+func TestSketch_Merge(t *testing.T) {
+	a, b := newFilledSketch(0, 6000), newFilledSketch(3000, 9000)
+
+	union := a.Clone()
+	union.Merge(b)
+	checkEstimate(t, "merge", union.Count(), 9000)
+
+	if a.Count() == union.Count() {
+		t.Error("merging the clone changed the sketch")
+	}
+
+	// the merged registers are the max of both
+	for i := 0; i < M; i++ {
+		want := a.Get(uint16(i))
+		if r := b.Get(uint16(i)); r > want {
+			want = r
+		}
+
+		if got := union.Get(uint16(i)); got != want {
+			t.Fatalf("register %d = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestSketch_Marshal(t *testing.T) {
+	for _, s := range []*Sketch{NewSketch(), newFilledSketch(0, 100), newFilledSketch(0, 20000)} {
+		c, err := Unmarshal(s.Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.Count() != s.Count() || c.IsSparse() != s.IsSparse() || len(s.Marshal()) != s.Size() {
+			t.Errorf("the unmarshaled sketch differs: %d, %d", c.Count(), s.Count())
+		}
+	}
+
+	for _, buf := range [][]byte{nil, {0}, {encodingSparse, 1}, {encodingDense, 1}, {encodingSparse, 0xff, 0xff, 1}} {
+		if _, err := Unmarshal(buf); err != ErrSketch {
+			t.Errorf("Unmarshal(%x) returned %v, want ErrSketch", buf, err)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	index, rank, err := DecodeRegister(EncodeRegister(M-1, Q+1))
+	if err != nil || index != M-1 || rank != Q+1 {
+		t.Errorf("DecodeRegister = %d, %d, %v", index, rank, err)
+	}
+
+	if _, _, err = DecodeRegister(EncodeRegister(M, 1)); err != ErrRegister {
+		t.Errorf("DecodeRegister of an out of range index returned %v, want ErrRegister", err)
+	}
+
+	if _, _, err = DecodeRegister(EncodeRegister(0, Q+2)); err != ErrRegister {
+		t.Errorf("DecodeRegister of an out of range rank returned %v, want ErrRegister", err)
+	}
+}
+
+func TestHLL_PFCount(t *testing.T) {
+	h := New()
+	h.PFAdd("a", []byte("1"), []byte("2"), []byte("3"))
+	h.PFAdd("b", []byte("3"), []byte("4"))
+
+	if n, _ := h.PFCount("a", "b", "missing"); n != 4 {
+		t.Errorf("PFCount = %d, want 4", n)
+	}
+
+	if _, err := h.PFCount(); err != ErrKeysEmpty {
+		t.Errorf("PFCount without key returned %v, want ErrKeysEmpty", err)
+	}
+
+	h.PFMerge("c", "a", "b")
+	if n, _ := h.PFCount("c"); n != 4 {
+		t.Errorf("PFCount after PFMerge = %d, want 4", n)
+	}
+}
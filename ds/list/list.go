@@ -71,11 +71,9 @@ func (l *List) LPush(key string, values ...[]byte) (size int, err error) {
 
 	var i, j int
 	j = valueLen
-	for i = 1; i <= size; i++ {
-		for i-1 < size {
-			newList[j] = l.Items[key][i-1]
-			j++
-		}
+	for i = 0; i < size; i++ {
+		newList[j] = l.Items[key][i]
+		j++
 	}
 
 	j = 0
@@ -83,6 +81,10 @@ func (l *List) LPush(key string, values ...[]byte) (size int, err error) {
 		newList[i] = values[j]
 		j++
 	}
+
+	l.Items[key] = newList
+
+	return l.Size(key)
 }
 
 
@@ -0,0 +1,25 @@
+package list
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestList_LPush(t *testing.T) {
+	l := New()
+	l.RPush("k", []byte("c"))
+
+	size, err := l.LPush("k", []byte("b"), []byte("a"))
+	if err != nil || size != 3 {
+		t.Fatalf("LPush returned %d, %v", size, err)
+	}
+
+	want := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	if !reflect.DeepEqual(l.Items["k"], want) {
+		t.Errorf("got %q, want %q", l.Items["k"], want)
+	}
+
+	if size, err = l.LPush("new", []byte("x")); err != nil || size != 1 {
+		t.Errorf("LPush on a new list returned %d, %v", size, err)
+	}
+}
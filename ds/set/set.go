@@ -1,21 +1,110 @@
 package set
 
-import "errors"
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrKeysEmpty is returned when no key is given to the set algebra
+	ErrKeysEmpty = errors.New("keys empty")
+
+	// ErrCount is returned when the count is negative where it is not allowed
+	ErrCount = errors.New("err count")
+)
+
+// DefaultScanCount represents the default number of members examined by SScan
+const DefaultScanCount = 10
 
 // Set represents the Set
 type Set struct {
-	M map[string]map[string]struct{}
+	M		map[string]map[string]struct{}
+	rand	*lockedRand
+	owned	map[string]struct{}	// the keys written since Clone, nil if the set is not a clone
+}
+
+// lockedRand represents a random source that is safe for concurrent use,
+// since it is shared by a set and its clones
+type lockedRand struct {
+	mu	sync.Mutex
+	r	*rand.Rand
+}
+
+// newLockedRand returns a random source seeded with seed
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+// Intn returns a random number in [0, n)
+func (lr *lockedRand) Intn(n int) int {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	return lr.r.Intn(n)
 }
 
 // New returns a newly initialized Set object that implements the Set
 func New() *Set {
 	return &Set {
-		M: make(map[string]map[string]struct{}),
+		M:		make(map[string]map[string]struct{}),
+		rand:	newLockedRand(time.Now().UnixNano()),
 	}
 }
 
+// Seed seeds the random source used by SPop and SRandMember
+// With the same seed and the same members, the same members are selected
+func (s *Set) Seed(seed int64) {
+	s.rand = newLockedRand(seed)
+}
+
+// Clone returns a copy of the set in O(number of keys), the random source is shared
+// The members of a key are copied on the first write of the copy to the key, so s must not be modified anymore
+func (s *Set) Clone() *Set {
+	c := &Set{
+		M:		make(map[string]map[string]struct{}, len(s.M)),
+		rand:	s.rand,
+		owned:	make(map[string]struct{}),
+	}
+
+	for key, members := range s.M {
+		c.M[key] = members
+	}
+
+	return c
+}
+
+// own copies the members of the set stored at key if they are still shared with the cloned set
+func (s *Set) own(key string) {
+	if s.owned == nil {
+		return
+	}
+
+	if _, ok := s.owned[key]; ok {
+		return
+	}
+
+	if members, ok := s.M[key]; ok {
+		copied := make(map[string]struct{}, len(members))
+		for member := range members {
+			copied[member] = struct{}{}
+		}
+
+		s.M[key] = copied
+	}
+
+	s.owned[key] = struct{}{}
+}
+
 // SAdd adds the specified members to the set stored at key
 func (s *Set) SAdd(key string, items ...[]byte) error {
+	s.own(key)
+
 	if _, ok := s.M[key]; !ok {
 		s.M[key] = make(map[string]struct{})
 	}
@@ -27,7 +116,7 @@ func (s *Set) SAdd(key string, items ...[]byte) error {
 	return nil
 }
 
-// SRem removes the specified members from the set stored at key
+// SRem removes the specified members from the set stored at key, the set is removed when it has no member anymore
 func (s *Set) SRem(key string, items ...[]byte) error {
 	if _, ok := s.M[key]; !ok {
 		return errors.New("key not found")
@@ -37,10 +126,16 @@ func (s *Set) SRem(key string, items ...[]byte) error {
 		return errors.New("item empty")
 	}
 
+	s.own(key)
+
 	for _, item := range items {
 		delete(s.M[key], string(item))
 	}
 
+	if len(s.M[key]) == 0 {
+		delete(s.M, key)
+	}
+
 	return nil
 }
 
@@ -54,18 +149,125 @@ func (s *Set) SHasKey (key string) bool {
 }
 
 
-// SPop removes and returns one or more random elements from the set value store at key
-func (s *Set) SPop(key string) []byte {
+// SPop removes and returns count random distinct elements from the set value store at key
+// Every member has the same probability to be selected
+func (s *Set) SPop(key string, count int) (list [][]byte, err error) {
+	if count < 0 {
+		return nil, ErrCount
+	}
+
+	if list, err = s.SRandMember(key, count); err != nil {
+		return nil, err
+	}
+
+	if len(list) > 0 {
+		err = s.SRem(key, list...)
+	}
+
+	return
+}
+
+// SRandMember returns count random elements from the set value store at key
+// The count argument influences the operation in the following ways:
+// count > 0: Return up to count distinct elements
+// count < 0: Return exactly -count elements that may be repeated
+// count = 0: Return no element
+func (s *Set) SRandMember(key string, count int) (list [][]byte, err error) {
 	if !s.SHasKey(key) {
+		return nil, errors.New("set not exists")
+	}
+
+	return s.RandMembers(s.M[key], count), nil
+}
+
+// RandMembers returns count random elements of members, the count argument is used as in SRandMember
+// The members are selected by their rank in lexicographic order, so that the same seed selects the same members,
+// but only the selected ranks are searched for, it costs O(size * log(count)) instead of sorting the members
+func (s *Set) RandMembers(members map[string]struct{}, count int) (list [][]byte) {
+	size := len(members)
+	if size == 0 || count == 0 {
 		return nil
 	}
 
-	for item := range s.M[key] {
-		delete(s.M[key], item)
-		return []byte(item)
+	var ranks []int
+	if count < 0 {
+		for i := 0; i < -count; i++ {
+			ranks = append(ranks, s.rand.Intn(size))
+		}
+	} else {
+		if count > size {
+			count = size
+		}
+
+		// partial Fisher-Yates shuffle of the ranks, only the moved ranks are kept
+		moved := make(map[int]int, count)
+		for i := 0; i < count; i++ {
+			j := i + s.rand.Intn(size-i)
+
+			rank, ok := moved[j]
+			if !ok {
+				rank = j
+			}
+
+			if moved[j], ok = moved[i]; !ok {
+				moved[j] = i
+			}
+
+			ranks = append(ranks, rank)
+		}
 	}
 
-	return nil
+	items := make([]string, 0, size)
+	for item := range members {
+		items = append(items, item)
+	}
+
+	// the distinct ranks in order
+	sorted := append([]int(nil), ranks...)
+	sort.Ints(sorted)
+	distinct := sorted[:0]
+	for i, rank := range sorted {
+		if i == 0 || rank != sorted[i-1] {
+			distinct = append(distinct, rank)
+		}
+	}
+
+	selected := make(map[int]string, len(distinct))
+	selectRanks(items, 0, distinct, selected)
+
+	for _, rank := range ranks {
+		list = append(list, []byte(selected[rank]))
+	}
+
+	return
+}
+
+// selectRanks sets in selected the items at the sorted ranks, the ranks of items start at offset
+// It partitions items around a pivot like a quickselect, and only goes on with the parts holding a rank
+func selectRanks(items []string, offset int, ranks []int, selected map[int]string) {
+	for len(ranks) > 0 {
+		// the pivot is moved to the end, then the smaller items are moved before p
+		last := len(items) - 1
+		items[len(items)/2], items[last] = items[last], items[len(items)/2]
+		p := 0
+		for i := 0; i < last; i++ {
+			if items[i] < items[last] {
+				items[i], items[p] = items[p], items[i]
+				p++
+			}
+		}
+		items[p], items[last] = items[last], items[p]
+
+		i := sort.SearchInts(ranks, offset+p)
+		selectRanks(items[:p], offset, ranks[:i], selected)
+
+		if i < len(ranks) && ranks[i] == offset+p {
+			selected[offset+p] = items[p]
+			i++
+		}
+
+		items, offset, ranks = items[p+1:], offset+p+1, ranks[i:]
+	}
 }
 
 // SCard returns the set cardinality (number of elements) of the set stored at key
@@ -78,46 +280,56 @@ func (s *Set) SCard(key string) int {
 }
 
 // SDiff returns the members of the set resulting from the difference between the first set and all the successive set
-func (s *Set) Sdiff(key1, key2 string) (list [][]byte, err error) {
-	if _, err = s.checkKey1AndKey2(key1, key2); err != nil {
+func (s *Set) Sdiff(keys ...string) (list [][]byte, err error) {
+	sets, err := s.getSets(keys...)
+	if err != nil {
 		return
 	}
 
-	for item1 := range s.M[key1] {
-		if _, ok := s.M[key2][item1]; !ok {
-			list = append(list, []byte(item1))
-		}
-	}
-
-	return
+	return Diff(sets...), nil
 }
 
 // SInter returns the members of the set resulting from the intersection of all the given sets
-func (s *Set) SInter(key1, key2 string) (list [][]byte, err error) {
-	if _, err = s.checkKey1AndKey2(key1, key2); err != nil {
+func (s *Set) SInter(keys ...string) (list [][]byte, err error) {
+	sets, err := s.getSets(keys...)
+	if err != nil {
 		return
 	}
 
-	for item1 := range s.M[key1] {
-		if _, ok := s.M[key1][item1]; ok {
-			list = append(list, []byte(item1))
-		}
+	return Inter(sets...), nil
+}
+
+// SInterCard returns the cardinality of the set resulting from the intersection of all the given sets
+// If limit is greater than 0, the counting stops as soon as the cardinality reaches limit
+func (s *Set) SInterCard(limit int, keys ...string) (int, error) {
+	sets, err := s.getSets(keys...)
+	if err != nil {
+		return 0, err
 	}
 
-	return
+	return InterCard(limit, sets...), nil
 }
 
-// checkkey1AndKey2 return if key1 and key2 exists
-func (s *Set) checkKey1AndKey2(key1, key2 string) (list [][]byte, err error) {
-	if _, ok := s.M[key1]; !ok {
-		return nil, errors.New("set1 is not exists")
+// getSets returns the members of the sets at given keys
+func (s *Set) getSets(keys ...string) (sets []map[string]struct{}, err error) {
+	if len(keys) == 0 {
+		return nil, ErrKeysEmpty
 	}
 
-	if _, ok := s.M[key2]; !ok {
-		return nil, errors.New("set2 is not exists")
+	for _, key := range keys {
+		if _, ok := s.M[key]; !ok {
+			return nil, fmt.Errorf("set %s is not exists", key)
+		}
+
+		sets = append(sets, s.M[key])
 	}
 
-	return nil, nil
+	return
+}
+
+// Members returns the members of the set stored at key, nil if the set not exists
+func (s *Set) Members(key string) map[string]struct{} {
+	return s.M[key]
 }
 
 // SIsMember returns if member is a member of the set stored at key
@@ -140,8 +352,13 @@ func (s *Set) SAreMember(key string, items ...[]byte) (bool, error) {
 		return false, errors.New("key not exists")
 	}
 
-	for _,  item := range items {
-		if _, ok := s.M[key][string(item)]; !ok {
+	return AreMembers(s.M[key], items...)
+}
+
+// AreMembers returns if the items are all members of the member set, like SAreMember
+func AreMembers(members map[string]struct{}, items ...[]byte) (bool, error) {
+	for _, item := range items {
+		if _, ok := members[string(item)]; !ok {
 			return false, errors.New("item not exists")
 		}
 	}
@@ -181,20 +398,289 @@ func (s *Set) SMove(key1, key2 string, item []byte) (bool, error) {
 }
 
 // SUnion returns the members of the set resulting from the union of all the given sets
-func (s *Set) SUnion(key1, key2 string) (list [][]byte, err error) {
-	if _, err = s.checkKey1AndKey2(key1, key2); err != nil {
+func (s *Set) SUnion(keys ...string) (list [][]byte, err error) {
+	sets, err := s.getSets(keys...)
+	if err != nil {
 		return
 	}
 
-	for item1 := range s.M[key1] {
-		list = append(list, []byte(item1))
+	return Union(sets...), nil
+}
+
+// Union returns the members of the union of the given member sets
+func Union(sets ...map[string]struct{}) (list [][]byte) {
+	seen := make(map[string]struct{})
+
+	for _, items := range sets {
+		for item := range items {
+			if _, ok := seen[item]; ok {
+				continue
+			}
+
+			seen[item] = struct{}{}
+			list = append(list, []byte(item))
+		}
+	}
+
+	return
+}
+
+// Inter returns the members of the intersection of the given member sets
+func Inter(sets ...map[string]struct{}) (list [][]byte) {
+	if len(sets) == 0 {
+		return nil
+	}
+
+	smallest := smallestSetIndex(sets)
+
+	for item := range sets[smallest] {
+		if isMemberOfAll(item, sets) {
+			list = append(list, []byte(item))
+		}
+	}
+
+	return
+}
+
+// InterCard returns the cardinality of the intersection of the given member sets
+// If limit is greater than 0, the counting stops as soon as the cardinality reaches limit
+func InterCard(limit int, sets ...map[string]struct{}) (card int) {
+	if len(sets) == 0 {
+		return 0
+	}
+
+	smallest := smallestSetIndex(sets)
+
+	for item := range sets[smallest] {
+		if !isMemberOfAll(item, sets) {
+			continue
+		}
+
+		card++
+		if limit > 0 && card == limit {
+			break
+		}
+	}
+
+	return
+}
+
+// Diff returns the members of the first member set that are not in any of the successive member sets
+func Diff(sets ...map[string]struct{}) (list [][]byte) {
+	if len(sets) == 0 {
+		return nil
+	}
+
+	for item := range sets[0] {
+		found := false
+		for _, other := range sets[1:] {
+			if _, ok := other[item]; ok {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			list = append(list, []byte(item))
+		}
+	}
+
+	return
+}
+
+// smallestSetIndex returns the index of the member set with the fewest members
+func smallestSetIndex(sets []map[string]struct{}) int {
+	smallest := 0
+	for i := range sets {
+		if len(sets[i]) < len(sets[smallest]) {
+			smallest = i
+		}
+	}
+
+	return smallest
+}
+
+// isMemberOfAll returns if item is a member of all the given member sets
+func isMemberOfAll(item string, sets []map[string]struct{}) bool {
+	for _, items := range sets {
+		if _, ok := items[item]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SScan incrementally iterates the members of the set stored at key
+// It examines up to count members that are greater than cursor in lexicographic order,
+// returns the ones matching the glob-style pattern match and the cursor for the next call
+// A nil cursor starts a new iteration and a nil next cursor means the iteration is complete
+// Every member that is present for the full iteration is returned exactly once,
+// and only O(count) memory is used whatever the size of the set
+func (s *Set) SScan(key string, cursor []byte, match string, count int) (next []byte, list [][]byte, err error) {
+	if !s.SHasKey(key) {
+		return nil, nil, errors.New("set not exists")
+	}
+
+	if count <= 0 {
+		count = DefaultScanCount
 	}
 
-	for item2 := range s.M[key2] {
-		if _, ok := s.M[key1][key2]; !ok {
-			list = append(list, []byte(item2))
+	next, list = Scan(s.M[key], cursor, match, count)
+
+	return
+}
+
+// Scan iterates members like SScan, the members are sorted while they are scanned:
+// every call costs O(size * log(count)) and O(count) memory, no order of the members is kept between the calls
+func Scan(members map[string]struct{}, cursor []byte, match string, count int) (next []byte, list [][]byte) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+
+	// h keeps the count smallest members after the cursor, the greatest one on the top
+	h := &maxHeap{}
+	for item := range members {
+		if cursor != nil && item <= string(cursor) {
+			continue
 		}
+
+		if h.Len() < count {
+			heap.Push(h, item)
+			continue
+		}
+
+		if item < (*h)[0] {
+			(*h)[0] = item
+			heap.Fix(h, 0)
+		}
+	}
+
+	items := make([]string, h.Len())
+	for i := len(items) - 1; i >= 0; i-- {
+		items[i] = heap.Pop(h).(string)
+	}
+
+	for _, item := range items {
+		if match == "" || globMatch(match, item) {
+			list = append(list, []byte(item))
+		}
+	}
+
+	if len(items) == count {
+		next = []byte(items[count-1])
 	}
 
 	return
-}
\ No newline at end of file
+}
+
+// maxHeap represents a max-heap of members
+type maxHeap []string
+
+func (h maxHeap) Len() int            { return len(h) }
+func (h maxHeap) Less(i, j int) bool  { return h[i] > h[j] }
+func (h maxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x interface{}) { *h = append(*h, x.(string)) }
+func (h *maxHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// globMatch reports whether str matches the glob-style pattern
+// Supported patterns:
+// ? matches any single character
+// * matches any sequence of characters
+// [abc], [^abc] and [a-z] match a character in (or not in) the set
+// \x matches the character x literally
+func globMatch(pattern, str string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+
+			if len(pattern) == 0 {
+				return true
+			}
+
+			for i := 0; i <= len(str); i++ {
+				if globMatch(pattern, str[i:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+
+			pattern, str = pattern[1:], str[1:]
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				// no closing bracket, match '[' literally
+				if str[0] != '[' {
+					return false
+				}
+
+				pattern, str = pattern[1:], str[1:]
+				continue
+			}
+
+			if !classMatch(pattern[1:end+1], str[0]) {
+				return false
+			}
+
+			pattern, str = pattern[end+2:], str[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+
+			fallthrough
+		default:
+			if len(str) == 0 || pattern[0] != str[0] {
+				return false
+			}
+
+			pattern, str = pattern[1:], str[1:]
+		}
+	}
+
+	return len(str) == 0
+}
+
+// classMatch reports whether c is in the character class of a bracket expression
+func classMatch(class string, c byte) bool {
+	negate := false
+	if len(class) > 0 && class[0] == '^' {
+		negate, class = true, class[1:]
+	}
+
+	matched := false
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if class[i] <= c && c <= class[i+2] {
+				matched = true
+			}
+
+			i += 2
+			continue
+		}
+
+		if class[i] == c {
+			matched = true
+		}
+	}
+
+	return matched != negate
+}
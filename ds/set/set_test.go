@@ -0,0 +1,396 @@
+package set
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// members returns the sorted members of the set stored at key
+func members(s *Set, key string) []string {
+	var list []string
+	for item := range s.Members(key) {
+		list = append(list, item)
+	}
+
+	sort.Strings(list)
+
+	return list
+}
+
+func TestSet_SAddSRem(t *testing.T) {
+	s := New()
+	_ = s.SAdd("k", []byte("a"), []byte("b"), []byte("a"))
+
+	if n := s.SCard("k"); n != 2 {
+		t.Errorf("SCard = %d, want 2", n)
+	}
+
+	if !s.SIsMember("k", []byte("a")) || s.SIsMember("k", []byte("c")) {
+		t.Error("SIsMember returned a wrong result")
+	}
+
+	if err := s.SRem("k", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := members(s, "k"); len(got) != 1 || got[0] != "b" {
+		t.Errorf("members after SRem = %v, want [b]", got)
+	}
+
+	if err := s.SRem("missing", []byte("a")); err == nil {
+		t.Error("SRem on a missing set returned no error")
+	}
+}
+
+func TestSet_SMove(t *testing.T) {
+	s := New()
+	_ = s.SAdd("src", []byte("a"), []byte("b"))
+	_ = s.SAdd("dst", []byte("c"))
+
+	if ok, err := s.SMove("src", "dst", []byte("a")); !ok || err != nil {
+		t.Fatalf("SMove returned %v, %v", ok, err)
+	}
+
+	if got := members(s, "dst"); len(got) != 2 || got[0] != "a" || got[1] != "c" {
+		t.Errorf("dst = %v, want [a c]", got)
+	}
+
+	if s.SIsMember("src", []byte("a")) {
+		t.Error("the moved member is still in src")
+	}
+}
+
+func TestSet_Clone(t *testing.T) {
+	s := New()
+	_ = s.SAdd("k", []byte("a"))
+
+	c := s.Clone()
+	_ = c.SAdd("k", []byte("b"))
+
+	if s.SIsMember("k", []byte("b")) {
+		t.Error("modifying the clone modified the original set")
+	}
+
+	// the members are copied for every clone of the same set
+	other := s.Clone()
+	_ = other.SRem("k", []byte("a"))
+
+	if !s.SIsMember("k", []byte("a")) || !c.SIsMember("k", []byte("a")) || other.SHasKey("k") {
+		t.Error("modifying a clone modified the other clones")
+	}
+}
+
+func TestSet_Algebra(t *testing.T) {
+	s := New()
+	_ = s.SAdd("a", []byte("1"), []byte("2"), []byte("3"))
+	_ = s.SAdd("b", []byte("2"), []byte("3"), []byte("4"))
+	_ = s.SAdd("c", []byte("3"), []byte("5"))
+
+	tests := []struct {
+		name	string
+		fn		func(keys ...string) ([][]byte, error)
+		want	[]string
+	}{
+		{"SUnion", s.SUnion, []string{"1", "2", "3", "4", "5"}},
+		{"SInter", s.SInter, []string{"3"}},
+		{"Sdiff", s.Sdiff, []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		list, err := tt.fn("a", "b", "c")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		got := make([]string, 0, len(list))
+		for _, item := range list {
+			got = append(got, string(item))
+		}
+
+		sort.Strings(got)
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+
+		if _, err := tt.fn("a", "missing"); err == nil {
+			t.Errorf("%s with a missing set returned no error", tt.name)
+		}
+
+		if _, err := tt.fn(); err != ErrKeysEmpty {
+			t.Errorf("%s without key returned %v, want ErrKeysEmpty", tt.name, err)
+		}
+	}
+
+	if n, _ := s.SInterCard(0, "a", "b"); n != 2 {
+		t.Errorf("SInterCard = %d, want 2", n)
+	}
+
+	if n, _ := s.SInterCard(1, "a", "b"); n != 1 {
+		t.Errorf("SInterCard with limit 1 = %d, want 1", n)
+	}
+}
+
+func TestSet_SRemLastMember(t *testing.T) {
+	s := New()
+	_ = s.SAdd("a", []byte("1"))
+
+	if err := s.SRem("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.SHasKey("a") {
+		t.Error("the set without member still exists")
+	}
+}
+
+func TestSet_SRandMember(t *testing.T) {
+	s := New()
+	for i := 0; i < 100; i++ {
+		_ = s.SAdd("a", []byte(fmt.Sprintf("%03d", i)))
+	}
+
+	list, err := s.SRandMember("a", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range list {
+		if seen[string(item)] || !s.SIsMember("a", item) {
+			t.Fatalf("SRandMember returned %q twice or not a member", item)
+		}
+
+		seen[string(item)] = true
+	}
+
+	if len(list) != 10 {
+		t.Errorf("SRandMember returned %d members, want 10", len(list))
+	}
+
+	if list, _ = s.SRandMember("a", 1000); len(list) != 100 {
+		t.Errorf("SRandMember with a count greater than the size returned %d members, want 100", len(list))
+	}
+
+	if list, _ = s.SRandMember("a", -300); len(list) != 300 {
+		t.Errorf("SRandMember with a negative count returned %d members, want 300", len(list))
+	}
+
+	if list, _ = s.SRandMember("a", 0); len(list) != 0 {
+		t.Errorf("SRandMember with count 0 returned %d members", len(list))
+	}
+
+	if _, err = s.SRandMember("missing", 1); err == nil {
+		t.Error("SRandMember on a missing set returned no error")
+	}
+}
+
+func TestSet_SRandMemberSeed(t *testing.T) {
+	sample := func() []string {
+		s := New()
+		s.Seed(42)
+		for i := 0; i < 50; i++ {
+			_ = s.SAdd("a", []byte(fmt.Sprintf("%02d", i)))
+		}
+
+		list, _ := s.SRandMember("a", 5)
+		got := make([]string, len(list))
+		for i, item := range list {
+			got[i] = string(item)
+		}
+
+		return got
+	}
+
+	if a, b := sample(), sample(); !reflect.DeepEqual(a, b) {
+		t.Errorf("the same seed selected %v and %v", a, b)
+	}
+}
+
+func TestSet_SRandMemberDistribution(t *testing.T) {
+	s := New()
+	s.Seed(1)
+	_ = s.SAdd("a", []byte("a"), []byte("b"), []byte("c"), []byte("d"))
+
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		list, _ := s.SRandMember("a", 2)
+		for _, item := range list {
+			counts[string(item)]++
+		}
+	}
+
+	// every member is expected 2000 times
+	for item, n := range counts {
+		if n < 1700 || n > 2300 {
+			t.Errorf("member %s selected %d times, want about 2000", item, n)
+		}
+	}
+}
+
+func TestSet_SRandMemberConcurrent(t *testing.T) {
+	s := New()
+	_ = s.SAdd("a", []byte("1"), []byte("2"), []byte("3"))
+
+	// the clones share the random source of the set
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(c *Set) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				_, _ = c.SRandMember("a", 2)
+			}
+		}(s.Clone())
+	}
+
+	wg.Wait()
+}
+
+func TestSet_SScan(t *testing.T) {
+	s := New()
+	for i := 0; i < 25; i++ {
+		_ = s.SAdd("a", []byte(fmt.Sprintf("m%02d", i)))
+	}
+
+	var (
+		cursor	[]byte
+		got		[]string
+		pages	int
+	)
+
+	for {
+		next, list, err := s.SScan("a", cursor, "m1?", 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, item := range list {
+			got = append(got, string(item))
+		}
+
+		pages++
+		if next == nil {
+			break
+		}
+
+		cursor = next
+	}
+
+	want := []string{"m10", "m11", "m12", "m13", "m14", "m15", "m16", "m17", "m18", "m19"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SScan returned %v, want %v", got, want)
+	}
+
+	if pages != 7 {
+		t.Errorf("SScan took %d pages, want 7", pages)
+	}
+
+	if _, _, err := s.SScan("missing", nil, "", 0); err == nil {
+		t.Error("SScan on a missing set returned no error")
+	}
+}
+
+func TestSet_SScanConcurrentWrites(t *testing.T) {
+	s := New()
+	for i := 0; i < 100; i++ {
+		_ = s.SAdd("a", []byte(fmt.Sprintf("k%03d", i)))
+	}
+
+	seen := make(map[string]int)
+	var cursor []byte
+	for i := 0; ; i++ {
+		next, list, err := s.SScan("a", cursor, "", 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, item := range list {
+			seen[string(item)]++
+		}
+
+		if next == nil {
+			break
+		}
+
+		cursor = next
+
+		// members are added and removed on both sides of the cursor between the calls
+		_ = s.SAdd("a", []byte(fmt.Sprintf("k%03d.%d", i, i)), []byte(fmt.Sprintf("x%03d", i)))
+		_ = s.SRem("a", []byte(fmt.Sprintf("k%03d.%d", i, i)))
+	}
+
+	for i := 0; i < 100; i++ {
+		if n := seen[fmt.Sprintf("k%03d", i)]; n != 1 {
+			t.Errorf("member k%03d returned %d times, want 1", i, n)
+		}
+	}
+
+	for item, n := range seen {
+		if n != 1 {
+			t.Errorf("member %s returned %d times", item, n)
+		}
+	}
+}
+
+func TestSet_RandMembers(t *testing.T) {
+	items := make(map[string]struct{})
+	var sorted []string
+	for i := 0; i < 200; i++ {
+		item := fmt.Sprintf("%03d", i)
+		items[item] = struct{}{}
+		sorted = append(sorted, item)
+	}
+
+	// the members are selected by rank, like a shuffle of the sorted members would select them
+	for _, count := range []int{1, 7, 200, 300, -50} {
+		s, ref := New(), rand.New(rand.NewSource(3))
+		s.Seed(3)
+
+		var want []string
+		if count < 0 {
+			for i := 0; i < -count; i++ {
+				want = append(want, sorted[ref.Intn(len(sorted))])
+			}
+		} else {
+			shuffled := append([]string(nil), sorted...)
+			for i := 0; i < count && i < len(shuffled); i++ {
+				j := i + ref.Intn(len(shuffled)-i)
+				shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+				want = append(want, shuffled[i])
+			}
+		}
+
+		var got []string
+		for _, item := range s.RandMembers(items, count) {
+			got = append(got, string(item))
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("RandMembers with count %d returned %v, want %v", count, got, want)
+		}
+	}
+
+	if list := New().RandMembers(nil, 3); list != nil {
+		t.Errorf("RandMembers of no member returned %q", list)
+	}
+}
+
+func TestScan(t *testing.T) {
+	items := map[string]struct{}{"c": {}, "a": {}, "d": {}, "b": {}}
+
+	next, list := Scan(items, nil, "", 3)
+	if string(next) != "c" || !reflect.DeepEqual(list, [][]byte{[]byte("a"), []byte("b"), []byte("c")}) {
+		t.Errorf("Scan returned %q and %q, want [a b c] and c", list, next)
+	}
+
+	next, list = Scan(items, next, "", 3)
+	if next != nil || !reflect.DeepEqual(list, [][]byte{[]byte("d")}) {
+		t.Errorf("Scan after c returned %q and %q, want [d] and no cursor", list, next)
+	}
+}
@@ -0,0 +1,395 @@
+package stream
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+var (
+	// ErrStreamNotFound is returned when the stream at given key not exists
+	ErrStreamNotFound = errors.New("the stream not found")
+
+	// ErrID is returned when the ID is not greater than the last ID of the stream
+	ErrID = errors.New("the ID is equal or smaller than the last ID of the stream")
+
+	// ErrGroupExists is returned when the consumer group already exists
+	ErrGroupExists = errors.New("the consumer group already exists")
+
+	// ErrGroupNotFound is returned when the consumer group not exists
+	ErrGroupNotFound = errors.New("the consumer group not found")
+)
+
+// LastID represents the last ID of the stream when a consumer group is created, like "$" of Redis
+const LastID uint64 = math.MaxUint64
+
+// Stream represents the streams stored at every key
+type Stream struct {
+	M		map[string]*Log
+	owned	map[string]struct{}	// the keys written since Clone, nil if the stream is not a clone
+}
+
+// Log represents an append-only log of entries sorted by ID, with its consumer groups
+type Log struct {
+	Entries	[]*Entry
+	LastID	uint64 // the greatest ID ever added, kept after trimming so the IDs stay monotonic
+	Groups	map[string]*Group
+}
+
+// Entry represents an entry of the stream
+type Entry struct {
+	ID		uint64
+	Value	[]byte
+}
+
+// Group represents a consumer group
+type Group struct {
+	LastDeliveredID	uint64
+	Pending			map[uint64]*PendingEntry // the delivered but not acknowledged entries
+}
+
+// PendingEntry represents an entry delivered to a consumer but not acknowledged yet
+type PendingEntry struct {
+	ID				uint64
+	Consumer		string
+	DeliveryTime	int64 // unix time in milliseconds of the last delivery
+	DeliveryCount	int
+}
+
+// New returns a newly initialized Stream Object that implements the Stream
+func New() *Stream {
+	return &Stream{
+		M: make(map[string]*Log),
+	}
+}
+
+// Clone returns a copy of the stream in O(number of keys)
+// The log of a key is copied on the first write of the copy to the key, so s must not be modified anymore
+func (s *Stream) Clone() *Stream {
+	c := &Stream{
+		M:		make(map[string]*Log, len(s.M)),
+		owned:	make(map[string]struct{}),
+	}
+
+	for key, l := range s.M {
+		c.M[key] = l
+	}
+
+	return c
+}
+
+// own copies the log stored at key if it is still shared with the cloned stream,
+// the entries are shared since they are never modified
+func (s *Stream) own(key string) {
+	if s.owned == nil {
+		return
+	}
+
+	if _, ok := s.owned[key]; ok {
+		return
+	}
+
+	if l, ok := s.M[key]; ok {
+		cl := &Log{
+			// the capacity is cut so that XAdd never appends into the shared array
+			Entries:	l.Entries[:len(l.Entries):len(l.Entries)],
+			LastID:		l.LastID,
+			Groups:		make(map[string]*Group, len(l.Groups)),
+		}
+
+		for name, g := range l.Groups {
+			cg := &Group{LastDeliveredID: g.LastDeliveredID, Pending: make(map[uint64]*PendingEntry, len(g.Pending))}
+			for id, pe := range g.Pending {
+				cpe := *pe
+				cg.Pending[id] = &cpe
+			}
+
+			cl.Groups[name] = cg
+		}
+
+		s.M[key] = cl
+	}
+
+	s.owned[key] = struct{}{}
+}
+
+// XAdd appends the entry with the given ID to the stream stored at key, the stream is created if key not exists
+// The ID must be greater than all the IDs ever added to the stream
+func (s *Stream) XAdd(key string, id uint64, value []byte) error {
+	s.own(key)
+
+	l, ok := s.M[key]
+	if !ok {
+		l = &Log{Groups: make(map[string]*Group)}
+		s.M[key] = l
+	}
+
+	if id <= l.LastID {
+		return ErrID
+	}
+
+	l.Entries = append(l.Entries, &Entry{ID: id, Value: value})
+	l.LastID = id
+
+	return nil
+}
+
+// XLen returns the number of entries of the stream stored at key
+func (s *Stream) XLen(key string) (int, error) {
+	l, err := s.getLog(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(l.Entries), nil
+}
+
+// XRange returns the entries with an ID between start and end inclusive of the stream stored at key
+// If count is greater than 0, at most count entries are returned
+func (s *Stream) XRange(key string, start, end uint64, count int) ([]*Entry, error) {
+	l, err := s.getLog(key)
+	if err != nil {
+		return nil, err
+	}
+
+	first, last := l.search(start), l.search(end)
+	if last < len(l.Entries) && l.Entries[last].ID == end {
+		last++
+	}
+
+	if count > 0 && last-first > count {
+		last = first + count
+	}
+
+	if first >= last {
+		return nil, nil
+	}
+
+	return l.Entries[first:last], nil
+}
+
+// XRevRange returns the entries with an ID between end and start inclusive of the stream stored at key,
+// from the greatest ID to the smallest one
+func (s *Stream) XRevRange(key string, end, start uint64, count int) ([]*Entry, error) {
+	entries, err := s.XRange(key, start, end, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(entries)
+	if count > 0 && n > count {
+		n = count
+	}
+
+	reversed := make([]*Entry, n)
+	for i := range reversed {
+		reversed[i] = entries[len(entries)-1-i]
+	}
+
+	return reversed, nil
+}
+
+// XRead returns the entries with an ID greater than after of the stream stored at key
+func (s *Stream) XRead(key string, after uint64, count int) ([]*Entry, error) {
+	if after == math.MaxUint64 {
+		return nil, nil
+	}
+
+	return s.XRange(key, after+1, math.MaxUint64, count)
+}
+
+// XTrim removes the entries with an ID smaller than minID of the stream stored at key
+// It returns the number of the removed entries
+func (s *Stream) XTrim(key string, minID uint64) (int, error) {
+	s.own(key)
+
+	l, err := s.getLog(key)
+	if err != nil {
+		return 0, err
+	}
+
+	n := l.search(minID)
+	if n == 0 {
+		return 0, nil
+	}
+
+	// copy the kept entries so the removed ones can be garbage collected
+	l.Entries = append([]*Entry(nil), l.Entries[n:]...)
+
+	return n, nil
+}
+
+// MinIDForMaxLen returns the smallest ID to keep so that the stream stored at key has at most maxLen entries
+func (s *Stream) MinIDForMaxLen(key string, maxLen int) (uint64, error) {
+	l, err := s.getLog(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if maxLen < 0 {
+		maxLen = 0
+	}
+
+	if len(l.Entries) <= maxLen {
+		return 0, nil
+	}
+
+	if maxLen == 0 {
+		return l.LastID + 1, nil
+	}
+
+	return l.Entries[len(l.Entries)-maxLen].ID, nil
+}
+
+// XGroupCreate creates the consumer group of the stream stored at key,
+// the entries with an ID greater than startID will be delivered to the group
+func (s *Stream) XGroupCreate(key, group string, startID uint64) error {
+	s.own(key)
+
+	l, err := s.getLog(key)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := l.Groups[group]; ok {
+		return ErrGroupExists
+	}
+
+	l.Groups[group] = &Group{LastDeliveredID: startID, Pending: make(map[uint64]*PendingEntry)}
+
+	return nil
+}
+
+// XGroupDestroy removes the consumer group of the stream stored at key with its pending entries
+func (s *Stream) XGroupDestroy(key, group string) error {
+	s.own(key)
+
+	if _, err := s.GetGroup(key, group); err != nil {
+		return err
+	}
+
+	delete(s.M[key].Groups, group)
+
+	return nil
+}
+
+// XReadGroupNext returns the entries of the stream stored at key that were never delivered to the group
+// The entries are not delivered until Deliver is called
+func (s *Stream) XReadGroupNext(key, group string, count int) ([]*Entry, error) {
+	g, err := s.GetGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.XRead(key, g.LastDeliveredID, count)
+}
+
+// Deliver records that the entries with the given IDs were delivered to the consumer of the group at given time,
+// the entries are pending until they are acknowledged
+func (s *Stream) Deliver(key, group, consumer string, time int64, ids ...uint64) error {
+	s.own(key)
+
+	g, err := s.GetGroup(key, group)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		pe, ok := g.Pending[id]
+		if !ok {
+			pe = &PendingEntry{ID: id}
+			g.Pending[id] = pe
+		}
+
+		pe.Consumer, pe.DeliveryTime = consumer, time
+		pe.DeliveryCount++
+
+		if id > g.LastDeliveredID {
+			g.LastDeliveredID = id
+		}
+	}
+
+	return nil
+}
+
+// XAck removes the entries with the given IDs from the pending entries of the group
+// It returns the number of the acknowledged entries
+func (s *Stream) XAck(key, group string, ids ...uint64) (int, error) {
+	s.own(key)
+
+	g, err := s.GetGroup(key, group)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, id := range ids {
+		if _, ok := g.Pending[id]; ok {
+			delete(g.Pending, id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// XPending returns the pending entries of the group sorted by ID
+// If consumer is not empty, only the entries delivered to the consumer are returned
+func (s *Stream) XPending(key, group, consumer string) ([]*PendingEntry, error) {
+	g, err := s.GetGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*PendingEntry, 0, len(g.Pending))
+	for _, pe := range g.Pending {
+		if consumer == "" || pe.Consumer == consumer {
+			list = append(list, pe)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+
+	return list, nil
+}
+
+// GetGroup returns the consumer group of the stream stored at key
+func (s *Stream) GetGroup(key, group string) (*Group, error) {
+	l, err := s.getLog(key)
+	if err != nil {
+		return nil, err
+	}
+
+	g, ok := l.Groups[group]
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+
+	return g, nil
+}
+
+// HasKey returns if the stream stored at key exists
+func (s *Stream) HasKey(key string) bool {
+	_, ok := s.M[key]
+
+	return ok
+}
+
+// getLog returns the log of the stream stored at key
+func (s *Stream) getLog(key string) (*Log, error) {
+	l, ok := s.M[key]
+	if !ok {
+		return nil, ErrStreamNotFound
+	}
+
+	return l, nil
+}
+
+// search returns the position of the first entry with an ID equal or greater than id
+func (l *Log) search(id uint64) int {
+	return sort.Search(len(l.Entries), func(i int) bool {
+		return l.Entries[i].ID >= id
+	})
+}
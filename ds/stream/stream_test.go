@@ -0,0 +1,173 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+)
+
+// ids returns the IDs of the entries
+func ids(entries []*Entry) []uint64 {
+	list := make([]uint64, len(entries))
+	for i, e := range entries {
+		list[i] = e.ID
+	}
+
+	return list
+}
+
+// newTestStream returns a stream with the entries 10, 20, ..., 50 at key "s"
+func newTestStream(t *testing.T) *Stream {
+	t.Helper()
+
+	s := New()
+	for id := uint64(10); id <= 50; id += 10 {
+		if err := s.XAdd("s", id, []byte{byte(id)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return s
+}
+
+func TestStream_XAdd(t *testing.T) {
+	s := newTestStream(t)
+
+	if err := s.XAdd("s", 50, nil); err != ErrID {
+		t.Errorf("XAdd of the last ID returned %v, want ErrID", err)
+	}
+
+	if n, err := s.XLen("s"); n != 5 || err != nil {
+		t.Errorf("XLen = %d, %v, want 5", n, err)
+	}
+
+	if _, err := s.XLen("missing"); err != ErrStreamNotFound {
+		t.Errorf("XLen of a missing stream returned %v, want ErrStreamNotFound", err)
+	}
+
+	// the IDs stay monotonic after the whole stream is trimmed
+	if n, _ := s.XTrim("s", 51); n != 5 {
+		t.Errorf("XTrim removed %d entries, want 5", n)
+	}
+
+	if err := s.XAdd("s", 40, nil); err != ErrID {
+		t.Errorf("XAdd of a trimmed ID returned %v, want ErrID", err)
+	}
+}
+
+func TestStream_XRange(t *testing.T) {
+	s := newTestStream(t)
+
+	tests := []struct {
+		start, end	uint64
+		count		int
+		want		[]uint64
+	}{
+		{0, 100, 0, []uint64{10, 20, 30, 40, 50}},
+		{20, 40, 0, []uint64{20, 30, 40}},
+		{15, 45, 0, []uint64{20, 30, 40}},
+		{20, 40, 2, []uint64{20, 30}},
+		{50, 50, 0, []uint64{50}},
+		{41, 49, 0, []uint64{}},
+		{40, 20, 0, []uint64{}},
+	}
+
+	for _, tt := range tests {
+		entries, err := s.XRange("s", tt.start, tt.end, tt.count)
+		if err != nil || !reflect.DeepEqual(ids(entries), tt.want) {
+			t.Errorf("XRange(%d, %d, %d) = %v, %v, want %v", tt.start, tt.end, tt.count, ids(entries), err, tt.want)
+		}
+	}
+
+	if entries, _ := s.XRevRange("s", 40, 0, 2); !reflect.DeepEqual(ids(entries), []uint64{40, 30}) {
+		t.Errorf("XRevRange = %v, want [40 30]", ids(entries))
+	}
+
+	if entries, _ := s.XRead("s", 30, 0); !reflect.DeepEqual(ids(entries), []uint64{40, 50}) {
+		t.Errorf("XRead after 30 = %v, want [40 50]", ids(entries))
+	}
+
+	if entries, _ := s.XRead("s", LastID, 0); len(entries) != 0 {
+		t.Errorf("XRead after LastID = %v, want nothing", ids(entries))
+	}
+}
+
+func TestStream_MinIDForMaxLen(t *testing.T) {
+	s := newTestStream(t)
+
+	for maxLen, want := range map[int]uint64{-1: 51, 0: 51, 2: 40, 5: 0, 10: 0} {
+		if id, err := s.MinIDForMaxLen("s", maxLen); id != want || err != nil {
+			t.Errorf("MinIDForMaxLen(%d) = %d, %v, want %d", maxLen, id, err, want)
+		}
+	}
+}
+
+func TestStream_Groups(t *testing.T) {
+	s := newTestStream(t)
+
+	if err := s.XGroupCreate("s", "g", 20); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.XGroupCreate("s", "g", 0); err != ErrGroupExists {
+		t.Errorf("XGroupCreate of an existing group returned %v, want ErrGroupExists", err)
+	}
+
+	entries, _ := s.XReadGroupNext("s", "g", 2)
+	if !reflect.DeepEqual(ids(entries), []uint64{30, 40}) {
+		t.Fatalf("XReadGroupNext = %v, want [30 40]", ids(entries))
+	}
+
+	// the entries are delivered only once Deliver is called
+	if again, _ := s.XReadGroupNext("s", "g", 2); !reflect.DeepEqual(ids(again), ids(entries)) {
+		t.Errorf("XReadGroupNext before Deliver = %v, want %v", ids(again), ids(entries))
+	}
+
+	_ = s.Deliver("s", "g", "alice", 1, 30, 40)
+	_ = s.Deliver("s", "g", "bob", 2, 40)
+
+	if entries, _ = s.XReadGroupNext("s", "g", 0); !reflect.DeepEqual(ids(entries), []uint64{50}) {
+		t.Errorf("XReadGroupNext after Deliver = %v, want [50]", ids(entries))
+	}
+
+	pending, _ := s.XPending("s", "g", "")
+	if len(pending) != 2 || pending[0].ID != 30 || pending[1].Consumer != "bob" || pending[1].DeliveryCount != 2 {
+		t.Errorf("XPending = %+v, %+v", pending[0], pending[1])
+	}
+
+	if pending, _ = s.XPending("s", "g", "alice"); len(pending) != 1 || pending[0].ID != 30 {
+		t.Errorf("XPending of alice = %v", pending)
+	}
+
+	if n, _ := s.XAck("s", "g", 30, 50); n != 1 {
+		t.Errorf("XAck acknowledged %d entries, want 1", n)
+	}
+
+	// the clone does not share the groups
+	c := s.Clone()
+	_, _ = c.XAck("s", "g", 40)
+
+	if pending, _ = s.XPending("s", "g", ""); len(pending) != 1 {
+		t.Errorf("XAck on the clone changed the stream, %d pending entries", len(pending))
+	}
+
+	// s is not modified anymore once cloned
+	if err := c.XGroupDestroy("s", "g"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.XPending("s", "g", ""); err != ErrGroupNotFound {
+		t.Errorf("XPending of a destroyed group returned %v, want ErrGroupNotFound", err)
+	}
+
+	if _, err := s.XPending("s", "g", ""); err != nil {
+		t.Errorf("XGroupDestroy on the clone changed the stream, XPending returned %v", err)
+	}
+
+	if err := c.XAdd("s", 60, []byte("f")); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := s.XLen("s"); n != 5 {
+		t.Errorf("XAdd on the clone changed the stream, XLen = %d", n)
+	}
+}
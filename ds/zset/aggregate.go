@@ -0,0 +1,157 @@
+package zset
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrWeights is returned when the number of the weights does not match the number of the sorted sets
+var ErrWeights = errors.New("the number of the weights must match the number of the sorted sets")
+
+// Aggregate represents how the scores of the same element are aggregated
+type Aggregate int
+
+const (
+	// AggregateSum represents the element gets the sum of its weighted scores
+	AggregateSum Aggregate = iota
+
+	// AggregateMin represents the element gets the minimum of its weighted scores
+	AggregateMin
+
+	// AggregateMax represents the element gets the maximum of its weighted scores
+	AggregateMax
+)
+
+// ZUnion returns a new sorted set of the union of the given sorted sets
+// The score of every element is the aggregation of its scores multiplied by the weights of the sets
+// If weights is nil, every weight is 1. A nil sorted set is treated as an empty one
+// The value of an element is the one in the first set that contains it
+func ZUnion(sets []*SortedSet, weights []float64, aggregate Aggregate) (*SortedSet, error) {
+	if err := checkWeights(sets, weights); err != nil {
+		return nil, err
+	}
+
+	result := New()
+	for i, ss := range sets {
+		if ss == nil {
+			continue
+		}
+
+		for _, node := range ss.GetByRankRange(1, -1, false) {
+			score := weightedScore(node.score, weights, i)
+			if found := result.GetByKey(node.key); found != nil {
+				_ = result.Put(node.key, aggregateScore(aggregate, found.score, score), found.Value)
+				continue
+			}
+
+			_ = result.Put(node.key, score, node.Value)
+		}
+	}
+
+	return result, nil
+}
+
+// ZInter returns a new sorted set of the intersection of the given sorted sets
+// The score of every element is the aggregation of its scores multiplied by the weights of the sets
+// If weights is nil, every weight is 1. A nil sorted set is treated as an empty one
+func ZInter(sets []*SortedSet, weights []float64, aggregate Aggregate) (*SortedSet, error) {
+	if err := checkWeights(sets, weights); err != nil {
+		return nil, err
+	}
+
+	result := New()
+	if len(sets) == 0 {
+		return result, nil
+	}
+
+	// iterate the smallest set
+	smallest := 0
+	for i, ss := range sets {
+		if ss == nil {
+			return result, nil
+		}
+
+		if ss.Size() < sets[smallest].Size() {
+			smallest = i
+		}
+	}
+
+	for _, node := range sets[smallest].GetByRankRange(1, -1, false) {
+		var score SCORE
+
+		key := node.key
+
+		found := true
+		for i, ss := range sets {
+			other := ss.GetByKey(key)
+			if other == nil {
+				found = false
+				break
+			}
+
+			if i == 0 {
+				score = weightedScore(other.score, weights, i)
+				continue
+			}
+
+			score = aggregateScore(aggregate, score, weightedScore(other.score, weights, i))
+		}
+
+		if found {
+			_ = result.Put(key, score, sets[0].GetByKey(key).Value)
+		}
+	}
+
+	return result, nil
+}
+
+// checkWeights returns ErrWeights if the number of the weights does not match the number of the sorted sets
+func checkWeights(sets []*SortedSet, weights []float64) error {
+	if weights != nil && len(weights) != len(sets) {
+		return ErrWeights
+	}
+
+	return nil
+}
+
+// weightedScore returns the score multiplied by the weight of the i-th set
+func weightedScore(score SCORE, weights []float64, i int) SCORE {
+	if weights == nil {
+		return score
+	}
+
+	weighted := float64(score) * weights[i]
+
+	// avoid NaN when multiplying an infinite score by a zero weight
+	if math.IsNaN(weighted) {
+		return 0
+	}
+
+	return SCORE(weighted)
+}
+
+// aggregateScore returns the aggregation of the scores a and b
+func aggregateScore(aggregate Aggregate, a, b SCORE) SCORE {
+	switch aggregate {
+	case AggregateMin:
+		if b < a {
+			return b
+		}
+
+		return a
+	case AggregateMax:
+		if b > a {
+			return b
+		}
+
+		return a
+	}
+
+	sum := a + b
+	// avoid NaN when adding the opposite infinite scores
+	if math.IsNaN(float64(sum)) {
+		return 0
+	}
+
+	return sum
+}
@@ -0,0 +1,104 @@
+package zset
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// scores returns the scores of the nodes by key
+func scores(ss *SortedSet) map[string]SCORE {
+	m := make(map[string]SCORE, ss.Size())
+	for key, node := range ss.Members() {
+		m[key] = node.Score()
+	}
+
+	return m
+}
+
+// newAggregateSets returns the sorted sets {a:1, b:2, c:3} and {b:10, c:20, d:30}
+func newAggregateSets() []*SortedSet {
+	s1, s2 := New(), New()
+	_ = s1.Put("a", 1, []byte("a1"))
+	_ = s1.Put("b", 2, []byte("b1"))
+	_ = s1.Put("c", 3, []byte("c1"))
+	_ = s2.Put("b", 10, []byte("b2"))
+	_ = s2.Put("c", 20, []byte("c2"))
+	_ = s2.Put("d", 30, []byte("d2"))
+
+	return []*SortedSet{s1, s2}
+}
+
+func TestZUnion(t *testing.T) {
+	tests := []struct {
+		name		string
+		weights		[]float64
+		aggregate	Aggregate
+		want		map[string]SCORE
+	}{
+		{"sum", nil, AggregateSum, map[string]SCORE{"a": 1, "b": 12, "c": 23, "d": 30}},
+		{"min", nil, AggregateMin, map[string]SCORE{"a": 1, "b": 2, "c": 3, "d": 30}},
+		{"max", nil, AggregateMax, map[string]SCORE{"a": 1, "b": 10, "c": 20, "d": 30}},
+		{"weights", []float64{2, 0.5}, AggregateSum, map[string]SCORE{"a": 2, "b": 9, "c": 16, "d": 15}},
+	}
+
+	for _, tt := range tests {
+		result, err := ZUnion(newAggregateSets(), tt.weights, tt.aggregate)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if got := scores(result); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ZUnion = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	result, _ := ZUnion(newAggregateSets(), nil, AggregateSum)
+	if string(result.GetByKey("b").Value) != "b1" || string(result.GetByKey("d").Value) != "d2" {
+		t.Error("ZUnion did not keep the value of the first set that contains the element")
+	}
+
+	if result, _ = ZUnion([]*SortedSet{nil, newAggregateSets()[1]}, nil, AggregateSum); result.Size() != 3 {
+		t.Errorf("ZUnion with a nil set has %d elements, want 3", result.Size())
+	}
+}
+
+func TestZInter(t *testing.T) {
+	result, err := ZInter(newAggregateSets(), []float64{1, -1}, AggregateMax)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := scores(result), map[string]SCORE{"b": 2, "c": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ZInter = %v, want %v", got, want)
+	}
+
+	if result, _ = ZInter(newAggregateSets(), nil, AggregateSum); !reflect.DeepEqual(scores(result), map[string]SCORE{"b": 12, "c": 23}) {
+		t.Errorf("ZInter sum = %v", scores(result))
+	}
+
+	if result, _ = ZInter([]*SortedSet{newAggregateSets()[0], nil}, nil, AggregateSum); result.Size() != 0 {
+		t.Errorf("ZInter with a nil set has %d elements, want 0", result.Size())
+	}
+
+	if _, err = ZInter(newAggregateSets(), []float64{1}, AggregateSum); err != ErrWeights {
+		t.Errorf("ZInter with a wrong number of weights returned %v, want ErrWeights", err)
+	}
+}
+
+func TestZUnionInfinite(t *testing.T) {
+	s1, s2 := New(), New()
+	_ = s1.Put("a", SCORE(math.Inf(1)), nil)
+	_ = s2.Put("a", SCORE(math.Inf(-1)), nil)
+
+	// the opposite infinite scores and an infinite score with a zero weight give 0 instead of NaN
+	result, _ := ZUnion([]*SortedSet{s1, s2}, nil, AggregateSum)
+	if node := result.GetByKey("a"); node == nil || node.Score() != 0 {
+		t.Errorf("ZUnion of the opposite infinite scores = %v, want 0", node)
+	}
+
+	result, _ = ZUnion([]*SortedSet{s1}, []float64{0}, AggregateSum)
+	if node := result.GetByKey("a"); node == nil || node.Score() != 0 {
+		t.Errorf("ZUnion of an infinite score with a zero weight = %v, want 0", node)
+	}
+}
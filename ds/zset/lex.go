@@ -0,0 +1,127 @@
+package zset
+
+import "errors"
+
+// ErrLexBound is returned when the lexicographic range bound is malformed
+var ErrLexBound = errors.New("err lex bound")
+
+// LexBound represents a bound of the lexicographic range
+type LexBound struct {
+	Key			string
+	Exclusive	bool
+	Inf			int	// -1 represents the "-" bound, 1 represents the "+" bound
+}
+
+// ParseLexBound parses a lexicographic range bound in the form of "[a", "(a", "-" or "+"
+// "[" means the bound is inclusive and "(" means the bound is exclusive
+// "-" and "+" represent the negative and the positive infinite strings
+func ParseLexBound(bound string) (LexBound, error) {
+	switch {
+	case bound == "-":
+		return LexBound{Inf: -1}, nil
+	case bound == "+":
+		return LexBound{Inf: 1}, nil
+	case len(bound) > 0 && bound[0] == '[':
+		return LexBound{Key: bound[1:]}, nil
+	case len(bound) > 0 && bound[0] == '(':
+		return LexBound{Key: bound[1:], Exclusive: true}, nil
+	}
+
+	return LexBound{}, ErrLexBound
+}
+
+// ZRangeByLex returns the nodes between min and max in lexicographic order of the keys
+// It should only be used when all the elements have the same score, otherwise the result is unspecified
+// If offset is greater than 0, the first offset nodes are skipped
+// If limit is greater than 0, at most limit nodes are returned
+func (ss *SortedSet) ZRangeByLex(min, max LexBound, offset, limit int) []*SortedSetNode {
+	first, last := ss.lexRangeRanks(min, max)
+
+	if offset > 0 {
+		first += offset
+	}
+
+	if limit > 0 && first+limit-1 < last {
+		last = first + limit - 1
+	}
+
+	if first > last {
+		return nil
+	}
+
+	return ss.GetByRankRange(first, last, false)
+}
+
+// ZRevRangeByLex returns the nodes between max and min in reverse lexicographic order of the keys
+// It should only be used when all the elements have the same score, otherwise the result is unspecified
+func (ss *SortedSet) ZRevRangeByLex(max, min LexBound, offset, limit int) []*SortedSetNode {
+	first, last := ss.lexRangeRanks(min, max)
+
+	if offset > 0 {
+		last -= offset
+	}
+
+	if limit > 0 && last-limit+1 > first {
+		first = last - limit + 1
+	}
+
+	if first > last || last < 1 {
+		return nil
+	}
+
+	return ss.GetByRankRange(last, first, false)
+}
+
+// ZLexCount returns the number of the nodes between min and max in O(log n)
+// It should only be used when all the elements have the same score, otherwise the result is unspecified
+func (ss *SortedSet) ZLexCount(min, max LexBound) int {
+	first, last := ss.lexRangeRanks(min, max)
+	if first > last {
+		return 0
+	}
+
+	return last - first + 1
+}
+
+// ZRemRangeByLex removes and returns the nodes between min and max
+// It should only be used when all the elements have the same score, otherwise the result is unspecified
+func (ss *SortedSet) ZRemRangeByLex(min, max LexBound) []*SortedSetNode {
+	first, last := ss.lexRangeRanks(min, max)
+	if first > last {
+		return nil
+	}
+
+	return ss.GetByRankRange(first, last, true)
+}
+
+// lexRangeRanks returns the 1-based ranks of the first and the last node within the lexicographic range
+// If no node is in the range, first is greater than last
+func (ss *SortedSet) lexRangeRanks(min, max LexBound) (first, last int) {
+	if min.Inf == 1 || max.Inf == -1 {
+		return 1, 0
+	}
+
+	if min.Inf == 0 && max.Inf == 0 && (min.Key > max.Key || min.Key == max.Key && (min.Exclusive || max.Exclusive)) {
+		return 1, 0
+	}
+
+	first = 1
+	if min.Inf == 0 {
+		first = ss.rankBeforeKey(min.Key, min.Exclusive) + 1
+	}
+
+	last = ss.Size()
+	if max.Inf == 0 {
+		last = ss.rankBeforeKey(max.Key, !max.Exclusive)
+	}
+
+	return
+}
+
+// rankBeforeKey returns the number of the nodes with a key lower than the given key
+// If inclusive is true, the node with a key equal to the given key is counted too
+func (ss *SortedSet) rankBeforeKey(key string, inclusive bool) int {
+	return treeCount(ss.byScore, func(node *SortedSetNode) bool {
+		return node.key < key || inclusive && node.key == key
+	})
+}
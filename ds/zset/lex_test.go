@@ -0,0 +1,105 @@
+package zset
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newLexSet returns a sorted set of the keys "a" to "e" with the same score
+func newLexSet() *SortedSet {
+	ss := New()
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		_ = ss.Put(key, 0, nil)
+	}
+
+	return ss
+}
+
+func TestParseLexBound(t *testing.T) {
+	tests := []struct {
+		bound	string
+		want	LexBound
+	}{
+		{"-", LexBound{Inf: -1}},
+		{"+", LexBound{Inf: 1}},
+		{"[a", LexBound{Key: "a"}},
+		{"(a", LexBound{Key: "a", Exclusive: true}},
+		{"[", LexBound{Key: ""}},
+	}
+
+	for _, tt := range tests {
+		if got, err := ParseLexBound(tt.bound); err != nil || got != tt.want {
+			t.Errorf("ParseLexBound(%q) = %v, %v, want %v", tt.bound, got, err, tt.want)
+		}
+	}
+
+	for _, bound := range []string{"", "a", "{a"} {
+		if _, err := ParseLexBound(bound); err != ErrLexBound {
+			t.Errorf("ParseLexBound(%q) returned %v, want ErrLexBound", bound, err)
+		}
+	}
+}
+
+func TestSortedSet_ZRangeByLex(t *testing.T) {
+	ss := newLexSet()
+
+	tests := []struct {
+		min, max		string
+		offset, limit	int
+		want			[]string
+	}{
+		{"-", "+", 0, 0, []string{"a", "b", "c", "d", "e"}},
+		{"[b", "[d", 0, 0, []string{"b", "c", "d"}},
+		{"(b", "(d", 0, 0, []string{"c"}},
+		{"[bb", "+", 0, 0, []string{"c", "d", "e"}},
+		{"-", "[c", 1, 1, []string{"b"}},
+		{"[c", "[b", 0, 0, []string{}},
+		{"(c", "[c", 0, 0, []string{}},
+		{"+", "-", 0, 0, []string{}},
+		{"-", "+", 5, 0, []string{}},
+	}
+
+	for _, tt := range tests {
+		min, _ := ParseLexBound(tt.min)
+		max, _ := ParseLexBound(tt.max)
+
+		if got := keys(ss.ZRangeByLex(min, max, tt.offset, tt.limit)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ZRangeByLex(%s, %s, %d, %d) = %v, want %v", tt.min, tt.max, tt.offset, tt.limit, got, tt.want)
+		}
+
+		if tt.offset == 0 && tt.limit == 0 {
+			if n := ss.ZLexCount(min, max); n != len(tt.want) {
+				t.Errorf("ZLexCount(%s, %s) = %d, want %d", tt.min, tt.max, n, len(tt.want))
+			}
+		}
+	}
+}
+
+func TestSortedSet_ZRevRangeByLex(t *testing.T) {
+	ss := newLexSet()
+
+	if got := keys(ss.ZRevRangeByLex(LexBound{Key: "d"}, LexBound{Key: "a", Exclusive: true}, 1, 2)); !reflect.DeepEqual(got, []string{"c", "b"}) {
+		t.Errorf("ZRevRangeByLex = %v, want [c b]", got)
+	}
+
+	if got := keys(ss.ZRevRangeByLex(LexBound{Inf: 1}, LexBound{Inf: -1}, 0, 0)); !reflect.DeepEqual(got, []string{"e", "d", "c", "b", "a"}) {
+		t.Errorf("ZRevRangeByLex(+, -) = %v", got)
+	}
+}
+
+func TestSortedSet_ZRemRangeByLex(t *testing.T) {
+	ss := newLexSet()
+
+	removed := ss.ZRemRangeByLex(LexBound{Key: "b"}, LexBound{Key: "d", Exclusive: true})
+	if got := keys(removed); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("ZRemRangeByLex = %v, want [b c]", got)
+	}
+
+	if got := keys(ss.GetByRankRange(1, -1, false)); !reflect.DeepEqual(got, []string{"a", "d", "e"}) {
+		t.Errorf("members after ZRemRangeByLex = %v, want [a d e]", got)
+	}
+
+	if ss.GetByKey("b") != nil || ss.FindRank("d") != 2 {
+		t.Error("ZRemRangeByLex did not remove the nodes from the dict or the ranks")
+	}
+}
@@ -1,18 +1,15 @@
 package zset
 
-// SortedSetLevel records forward and span
-type SortedSetLevel struct {
-	forward *SortedSetNode
-	span int64
-}
-
-// SortedSetNode represents a node in the SortedSet
+// SortedSetNode represents a node in the SortedSet, it is shared by the clones of the set so it is never modified
 type SortedSetNode struct {
 	key 		string		//unique key of this node
 	Value 		[]byte		// associated data
 	score 		SCORE		// score to determine the order of this node in the set
-	backward 	*SortedSetNode
-	level		[]SortedSetLevel
+}
+
+// NewNode returns a node with the given key, score and value that is not in any sorted set
+func NewNode(key string, score SCORE, value []byte) *SortedSetNode {
+	return &SortedSetNode{key: key, score: score, Value: value}
 }
 
 // Key returns the key of the node
@@ -23,4 +20,15 @@ func (ssn *SortedSetNode) Key() string {
 // Score returns the score of the node
 func (ssn *SortedSetNode) Score() SCORE {
 	return ssn.score
-}
\ No newline at end of file
+}
+
+// Less returns if the node is ordered before the other node
+func (ssn *SortedSetNode) Less(other *SortedSetNode) bool {
+	return ssn.less(other.score, other.key)
+}
+
+// less returns if the node is ordered before the given score and key
+// Nodes are ordered by score, and by key when the scores are the same
+func (ssn *SortedSetNode) less(score SCORE, key string) bool {
+	return ssn.score < score || ssn.score == score && ssn.key < key
+}
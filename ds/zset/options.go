@@ -0,0 +1,99 @@
+package zset
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	// ErrZAddOptions is returned when the incompatible ZAdd options are set at the same time
+	ErrZAddOptions = errors.New("GT, LT, and/or NX options at the same time are not compatible")
+
+	// ErrScoreNaN is returned when a score or an increment is NaN, or an increment makes the score NaN
+	ErrScoreNaN = errors.New("score is not a number")
+)
+
+// ZAddOptions represents the options of the ZAdd
+type ZAddOptions struct {
+	NX bool	// only add new elements, don't update already existing elements
+	XX bool	// only update elements that already exist, don't add new elements
+	GT bool	// only update existing elements if the new score is greater than the current score
+	LT bool	// only update existing elements if the new score is less than the current score
+	CH bool	// count the elements whose score changed besides the added ones
+}
+
+// Validate returns ErrZAddOptions if the options are not compatible
+func (opts *ZAddOptions) Validate() error {
+	if opts == nil {
+		return nil
+	}
+
+	if opts.NX && (opts.XX || opts.GT || opts.LT) || opts.GT && opts.LT {
+		return ErrZAddOptions
+	}
+
+	return nil
+}
+
+// Check returns if the element should be written with the given score,
+// and the number it contributes to the result of the ZAdd,
+// exists and current represent if the element exists and its current score
+func (opts *ZAddOptions) Check(exists bool, current, score SCORE) (write bool, count int) {
+	if !exists {
+		if opts != nil && opts.XX {
+			return false, 0
+		}
+
+		return true, 1
+	}
+
+	if opts != nil && (opts.NX || opts.GT && score <= current || opts.LT && score >= current) {
+		return false, 0
+	}
+
+	if opts != nil && opts.CH && score != current {
+		return true, 1
+	}
+
+	return true, 0
+}
+
+// ZAddWithOptions adds an element with specific key, score and value under the given options
+// It returns the number of the added elements, or the changed elements if CH is set
+func (ss *SortedSet) ZAddWithOptions(key string, score SCORE, value []byte, opts *ZAddOptions) (int, error) {
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+
+	var current SCORE
+
+	node := ss.GetByKey(key)
+	if node != nil {
+		current = node.score
+	}
+
+	write, count := opts.Check(node != nil, current, score)
+	if !write {
+		return 0, nil
+	}
+
+	return count, ss.Put(key, score, value)
+}
+
+// ZIncrBy increments the score of the element at given key by increment and returns the new score
+// If the element does not exist, it is added with increment as its score
+func (ss *SortedSet) ZIncrBy(key string, increment SCORE) (SCORE, error) {
+	var value []byte
+
+	score := increment
+	if node := ss.GetByKey(key); node != nil {
+		score += node.score
+		value = node.Value
+	}
+
+	if math.IsNaN(float64(score)) {
+		return 0, ErrScoreNaN
+	}
+
+	return score, ss.Put(key, score, value)
+}
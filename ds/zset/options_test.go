@@ -0,0 +1,96 @@
+package zset
+
+import (
+	"math"
+	"testing"
+)
+
+func TestZAddOptions_Validate(t *testing.T) {
+	valid := []*ZAddOptions{nil, {}, {NX: true}, {XX: true, GT: true}, {XX: true, LT: true, CH: true}}
+	for _, opts := range valid {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("Validate(%+v) returned %v", opts, err)
+		}
+	}
+
+	invalid := []*ZAddOptions{{NX: true, XX: true}, {NX: true, GT: true}, {NX: true, LT: true}, {GT: true, LT: true}}
+	for _, opts := range invalid {
+		if err := opts.Validate(); err != ErrZAddOptions {
+			t.Errorf("Validate(%+v) returned %v, want ErrZAddOptions", opts, err)
+		}
+	}
+}
+
+func TestSortedSet_ZAddWithOptions(t *testing.T) {
+	tests := []struct {
+		name	string
+		key		string
+		score	SCORE
+		opts	*ZAddOptions
+		count	int
+		want	SCORE // the score of the key after the ZAdd, 0 if not exists
+	}{
+		{"add", "new", 5, nil, 1, 5},
+		{"update", "a", 5, nil, 0, 5},
+		{"update CH", "a", 5, &ZAddOptions{CH: true}, 1, 5},
+		{"same score CH", "a", 1, &ZAddOptions{CH: true}, 0, 1},
+		{"NX new", "new", 5, &ZAddOptions{NX: true}, 1, 5},
+		{"NX existing", "a", 5, &ZAddOptions{NX: true}, 0, 1},
+		{"XX new", "new", 5, &ZAddOptions{XX: true}, 0, 0},
+		{"XX existing", "a", 5, &ZAddOptions{XX: true, CH: true}, 1, 5},
+		{"GT greater", "a", 5, &ZAddOptions{GT: true, CH: true}, 1, 5},
+		{"GT lower", "a", 0, &ZAddOptions{GT: true, CH: true}, 0, 1},
+		{"GT new", "new", 5, &ZAddOptions{GT: true}, 1, 5},
+		{"LT lower", "a", 0.5, &ZAddOptions{LT: true, CH: true}, 1, 0.5},
+		{"LT greater", "a", 5, &ZAddOptions{LT: true}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		ss := New()
+		_ = ss.Put("a", 1, nil)
+
+		count, err := ss.ZAddWithOptions(tt.key, tt.score, nil, tt.opts)
+		if err != nil || count != tt.count {
+			t.Errorf("%s: ZAddWithOptions = %d, %v, want %d", tt.name, count, err, tt.count)
+		}
+
+		var score SCORE
+		if node := ss.GetByKey(tt.key); node != nil {
+			score = node.Score()
+		}
+
+		if score != tt.want {
+			t.Errorf("%s: score = %v, want %v", tt.name, score, tt.want)
+		}
+	}
+
+	if _, err := New().ZAddWithOptions("a", 1, nil, &ZAddOptions{GT: true, LT: true}); err != ErrZAddOptions {
+		t.Errorf("ZAddWithOptions with GT and LT returned %v, want ErrZAddOptions", err)
+	}
+}
+
+func TestSortedSet_ZIncrBy(t *testing.T) {
+	ss := New()
+	_ = ss.Put("a", 1, []byte("va"))
+
+	if score, err := ss.ZIncrBy("a", 2.5); err != nil || score != 3.5 || string(ss.GetByKey("a").Value) != "va" {
+		t.Errorf("ZIncrBy(a) = %v, %v, want 3.5", score, err)
+	}
+
+	if score, err := ss.ZIncrBy("b", -1); err != nil || score != -1 {
+		t.Errorf("ZIncrBy(b) = %v, %v, want -1", score, err)
+	}
+
+	_ = ss.Put("inf", SCORE(math.Inf(1)), nil)
+	if _, err := ss.ZIncrBy("inf", SCORE(math.Inf(-1))); err != ErrScoreNaN {
+		t.Errorf("ZIncrBy to NaN returned %v, want ErrScoreNaN", err)
+	}
+
+	if ss.GetByKey("inf").Score() != SCORE(math.Inf(1)) {
+		t.Error("the failed ZIncrBy changed the score")
+	}
+
+	if err := ss.Put("nan", SCORE(math.NaN()), nil); err != ErrScoreNaN || ss.GetByKey("nan") != nil {
+		t.Errorf("Put with a NaN score returned %v, want ErrScoreNaN", err)
+	}
+}
@@ -1,40 +1,480 @@
 package zset
 
-import "math/rand"
-
-conset (
-	// SkipListMaxLevel represents the skipList max level number
-	SkipListMaxLevel = 32
-
-	// SkipListP represents the p parameter of the skipList
-	SkipListP = 0.25
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
 )
 
 // SCORE represents the score type
 type SCORE float64
 
-// SortedSet represents the sorted set
+// SortedSet represents the sorted set, made of two persistent treaps of the same nodes:
+// byScore ordered by score and key, whose subtree sizes give the ranks in O(log n), and byKey ordered by key
+// A write copies only the tree nodes on its path, so Clone takes O(1) and the copies share all the other nodes
 type SortedSet struct {
-	header 	*SortedSetNode
-	tail 	*SortedSetNode
-	length 	int64
-	level	int
-	Dict 	map[string]*SortedSetNode
+	byScore	*treeNode
+	byKey	*treeNode
+}
+
+// treeNode represents a node of a persistent treap, it is never modified once built
+type treeNode struct {
+	node		*SortedSetNode
+	priority	uint32
+	size		int // the number of the nodes of the subtree
+	left		*treeNode
+	right		*treeNode
+}
+
+// New returns a newly initialized SortedSet Object that implements the SortedSet
+func New() *SortedSet {
+	return &SortedSet{}
+}
+
+// Clone returns a copy of the sorted set in O(1), the copies share their nodes until they are written
+func (ss *SortedSet) Clone() *SortedSet {
+	c := *ss
+
+	return &c
+}
+
+// newTreeNode returns a tree node with the given children
+func newTreeNode(node *SortedSetNode, priority uint32, left, right *treeNode) *treeNode {
+	return &treeNode{
+		node:		node,
+		priority:	priority,
+		size:		treeSize(left) + treeSize(right) + 1,
+		left:		left,
+		right:		right,
+	}
+}
+
+// treeSize returns the number of the nodes of the tree, 0 for a nil tree
+func treeSize(t *treeNode) int {
+	if t == nil {
+		return 0
+	}
+
+	return t.size
+}
+
+// keyLess returns if the node a is ordered before the node b by key
+func keyLess(a, b *SortedSetNode) bool {
+	return a.key < b.key
+}
+
+// scoreLess returns if the node a is ordered before the node b by score and key
+func scoreLess(a, b *SortedSetNode) bool {
+	return a.Less(b)
+}
+
+// treeInsert returns the tree with the node inserted, the tree must not contain a node equal to it
+func treeInsert(t *treeNode, node *SortedSetNode, priority uint32, less func(a, b *SortedSetNode) bool) *treeNode {
+	if t == nil {
+		return newTreeNode(node, priority, nil, nil)
+	}
+
+	if priority > t.priority {
+		left, right := treeSplit(t, node, less)
+		return newTreeNode(node, priority, left, right)
+	}
+
+	if less(node, t.node) {
+		return newTreeNode(t.node, t.priority, treeInsert(t.left, node, priority, less), t.right)
+	}
+
+	return newTreeNode(t.node, t.priority, t.left, treeInsert(t.right, node, priority, less))
+}
+
+// treeSplit returns the trees of the nodes ordered before the node and of the ones ordered after it
+func treeSplit(t *treeNode, node *SortedSetNode, less func(a, b *SortedSetNode) bool) (left, right *treeNode) {
+	if t == nil {
+		return nil, nil
+	}
+
+	if less(t.node, node) {
+		l, r := treeSplit(t.right, node, less)
+		return newTreeNode(t.node, t.priority, t.left, l), r
+	}
+
+	l, r := treeSplit(t.left, node, less)
+
+	return l, newTreeNode(t.node, t.priority, r, t.right)
+}
+
+// treeRemove returns the tree without the node, the tree must contain a node equal to it
+func treeRemove(t *treeNode, node *SortedSetNode, less func(a, b *SortedSetNode) bool) *treeNode {
+	if t == nil {
+		return nil
+	}
+
+	if less(node, t.node) {
+		return newTreeNode(t.node, t.priority, treeRemove(t.left, node, less), t.right)
+	}
+
+	if less(t.node, node) {
+		return newTreeNode(t.node, t.priority, t.left, treeRemove(t.right, node, less))
+	}
+
+	return treeMerge(t.left, t.right)
 }
 
-// randomLevel returns a random level to the new skiplist node we are going to create
-// The return value of this function is between 1 and SkipListMaxLevel
-// (both inclusive), with a powerlaw-alike distribution where higher
-// levels are less likely to be returned
-func randomLevel() int {
-	level := 1
-	for float64(rand.Int31()&0xFFFF) < float64(SkipListP*0xFFFF) {
-		level += 1
+// treeMerge returns the tree of the nodes of left followed by the nodes of right
+func treeMerge(left, right *treeNode) *treeNode {
+	if left == nil {
+		return right
 	}
 
-	if level < SkipListMaxLevel {
-		return level
+	if right == nil {
+		return left
 	}
 
-	return SkipListMaxLevel
-}
\ No newline at end of file
+	if left.priority > right.priority {
+		return newTreeNode(left.node, left.priority, left.left, treeMerge(left.right, right))
+	}
+
+	return newTreeNode(right.node, right.priority, treeMerge(left, right.left), right.right)
+}
+
+// treeCount returns the number of the first nodes in order for which before returns true,
+// before must return true for a prefix of the nodes
+func treeCount(t *treeNode, before func(node *SortedSetNode) bool) int {
+	n := 0
+	for t != nil {
+		if before(t.node) {
+			n += treeSize(t.left) + 1
+			t = t.right
+		} else {
+			t = t.left
+		}
+	}
+
+	return n
+}
+
+// treeAppendRange appends the nodes of the tree at the 1-based ranks between start and end to nodes
+func treeAppendRange(nodes []*SortedSetNode, t *treeNode, start, end int) []*SortedSetNode {
+	if t == nil || start > end || end < 1 || start > t.size {
+		return nodes
+	}
+
+	rank := treeSize(t.left) + 1
+
+	nodes = treeAppendRange(nodes, t.left, start, end)
+	if start <= rank && rank <= end {
+		nodes = append(nodes, t.node)
+	}
+
+	return treeAppendRange(nodes, t.right, start-rank, end-rank)
+}
+
+// insertNode inserts the node, the caller should make sure that its key is not already inside
+func (ss *SortedSet) insertNode(node *SortedSetNode) {
+	ss.byScore = treeInsert(ss.byScore, node, rand.Uint32(), scoreLess)
+	ss.byKey = treeInsert(ss.byKey, node, rand.Uint32(), keyLess)
+}
+
+// deleteNode deletes the node, the caller should make sure that it is inside
+func (ss *SortedSet) deleteNode(node *SortedSetNode) {
+	ss.byScore = treeRemove(ss.byScore, node, scoreLess)
+	ss.byKey = treeRemove(ss.byKey, node, keyLess)
+}
+
+// Size returns the number of elements in the SortedSet
+func (ss *SortedSet) Size() int {
+	return treeSize(ss.byScore)
+}
+
+// PeekMin returns the element with minimum score, nil if the set is empty
+func (ss *SortedSet) PeekMin() *SortedSetNode {
+	return ss.GetByRank(1, false)
+}
+
+// PopMin returns and removes the element with minimum score, nil if the set is empty
+func (ss *SortedSet) PopMin() *SortedSetNode {
+	return ss.GetByRank(1, true)
+}
+
+// PeekMax returns the element with maximum score, nil if the set is empty
+func (ss *SortedSet) PeekMax() *SortedSetNode {
+	return ss.GetByRank(-1, false)
+}
+
+// PopMax returns and removes the element with maximum score, nil if the set is empty
+func (ss *SortedSet) PopMax() *SortedSetNode {
+	return ss.GetByRank(-1, true)
+}
+
+// Put puts an element into the sorted set with specific key, score and value
+// If the key exists, the score and the value are updated, ErrScoreNaN is returned if score is NaN
+// The nodes are never modified since they may be shared with the clones, the updated element is a new node
+func (ss *SortedSet) Put(key string, score SCORE, value []byte) error {
+	if math.IsNaN(float64(score)) {
+		return ErrScoreNaN
+	}
+
+	if found := ss.GetByKey(key); found != nil {
+		ss.deleteNode(found)
+	}
+
+	ss.insertNode(NewNode(key, score, value))
+
+	return nil
+}
+
+// ZAdd adds an element into the sorted set with specific key, score and value, it is an alias of Put
+func (ss *SortedSet) ZAdd(key string, score SCORE, value []byte) error {
+	return ss.Put(key, score, value)
+}
+
+// Remove removes the element at given key, returns the removed node or nil if not found
+func (ss *SortedSet) Remove(key string) *SortedSetNode {
+	found := ss.GetByKey(key)
+	if found != nil {
+		ss.deleteNode(found)
+	}
+
+	return found
+}
+
+// GetByScoreRangeOptions represents the options of the score range functions
+type GetByScoreRangeOptions struct {
+	Limit			int	// limit the max nodes to return
+	Offset			int	// skip the first offset nodes of the range
+	ExcludeStart	bool	// exclude start value, so it search in interval (start, end] or (start, end)
+	ExcludeEnd		bool	// exclude end value, so it search in interval [start, end) or (start, end)
+}
+
+// GetByScoreRange returns the nodes whose score within the specific range
+// If start is greater than end, the returned nodes are in reverse order
+// If options is nil, it searches in interval [start, end] without any limit by default
+func (ss *SortedSet) GetByScoreRange(start SCORE, end SCORE, options *GetByScoreRangeOptions) []*SortedSetNode {
+	if start > end {
+		return ss.ZRevRangeByScore(start, end, options)
+	}
+
+	return ss.ZRangeByScore(start, end, options)
+}
+
+// ZRangeByScore returns the nodes with a score between min and max ordered from low to high scores
+// Use math.Inf to get an unbounded range, ExcludeStart and ExcludeEnd of options to exclude min and max
+// The returned nodes carry their scores
+func (ss *SortedSet) ZRangeByScore(min, max SCORE, options *GetByScoreRangeOptions) []*SortedSetNode {
+	first, last := ss.scoreRangeRanks(min, max, options)
+
+	offset, limit := rangeOffsetAndLimit(options)
+
+	start, end := first+offset, last
+	if limit > 0 && start+limit-1 < end {
+		end = start + limit - 1
+	}
+
+	if start > end {
+		return nil
+	}
+
+	return ss.GetByRankRange(start, end, false)
+}
+
+// ZRevRangeByScore returns the nodes with a score between max and min ordered from high to low scores
+// Use math.Inf to get an unbounded range, ExcludeStart and ExcludeEnd of options to exclude max and min
+// The returned nodes carry their scores
+func (ss *SortedSet) ZRevRangeByScore(max, min SCORE, options *GetByScoreRangeOptions) []*SortedSetNode {
+	var revOptions *GetByScoreRangeOptions
+	if options != nil {
+		revOptions = &GetByScoreRangeOptions{
+			ExcludeStart:	options.ExcludeEnd,
+			ExcludeEnd:		options.ExcludeStart,
+		}
+	}
+
+	first, last := ss.scoreRangeRanks(min, max, revOptions)
+
+	offset, limit := rangeOffsetAndLimit(options)
+
+	start, end := last-offset, first
+	if limit > 0 && start-limit+1 > end {
+		end = start - limit + 1
+	}
+
+	if start < end || start < 1 {
+		return nil
+	}
+
+	return ss.GetByRankRange(start, end, false)
+}
+
+// ZCount returns the number of the nodes with a score between min and max in O(log n)
+func (ss *SortedSet) ZCount(min, max SCORE, options *GetByScoreRangeOptions) int {
+	first, last := ss.scoreRangeRanks(min, max, options)
+	if first > last {
+		return 0
+	}
+
+	return last - first + 1
+}
+
+// scoreRangeRanks returns the 1-based ranks of the first and the last node within the score range
+// If no node is in the range, first is greater than last
+func (ss *SortedSet) scoreRangeRanks(min, max SCORE, options *GetByScoreRangeOptions) (first, last int) {
+	excludeMin := options != nil && options.ExcludeStart
+	excludeMax := options != nil && options.ExcludeEnd
+
+	if min > max || min == max && (excludeMin || excludeMax) {
+		return 1, 0
+	}
+
+	return ss.rankBeforeScore(min, excludeMin) + 1, ss.rankBeforeScore(max, !excludeMax)
+}
+
+// rankBeforeScore returns the number of the nodes with a score lower than the given score
+// If inclusive is true, the nodes with a score equal to the given score are counted too
+// It walks the treap by the subtree sizes, so it takes O(log n)
+func (ss *SortedSet) rankBeforeScore(score SCORE, inclusive bool) int {
+	return treeCount(ss.byScore, func(node *SortedSetNode) bool {
+		return node.score < score || inclusive && node.score == score
+	})
+}
+
+// rangeOffsetAndLimit returns the offset and the limit of the options
+func rangeOffsetAndLimit(options *GetByScoreRangeOptions) (offset, limit int) {
+	if options == nil {
+		return 0, 0
+	}
+
+	if options.Offset > 0 {
+		offset = options.Offset
+	}
+
+	if options.Limit > 0 {
+		limit = options.Limit
+	}
+
+	return
+}
+
+// ParseScoreBound parses a score bound in the form of "1.5", "(1.5", "-inf" or "+inf"
+// The leading "(" means the bound is exclusive
+func ParseScoreBound(bound string) (score SCORE, exclusive bool, err error) {
+	if strings.HasPrefix(bound, "(") {
+		exclusive, bound = true, bound[1:]
+	}
+
+	switch strings.ToLower(bound) {
+	case "-inf":
+		return SCORE(math.Inf(-1)), exclusive, nil
+	case "+inf", "inf":
+		return SCORE(math.Inf(1)), exclusive, nil
+	}
+
+	f, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return SCORE(f), exclusive, nil
+}
+
+// sanitizeIndexes returns the 1-based start, end and the reverse flag
+func (ss *SortedSet) sanitizeIndexes(start int, end int) (int, int, bool) {
+	if start < 0 {
+		start = ss.Size() + start + 1
+	}
+
+	if end < 0 {
+		end = ss.Size() + end + 1
+	}
+
+	if start <= 0 {
+		start = 1
+	}
+
+	if end <= 0 {
+		end = 1
+	}
+
+	reverse := start > end
+	if reverse {
+		start, end = end, start
+	}
+
+	return start, end, reverse
+}
+
+// GetByRankRange returns nodes within specific rank range [start, end]
+// Note that the rank is 1-based integer. Rank 1 means the first node; Rank -1 means the last node
+// If start is greater than end, the returned nodes are in reverse order
+// If remove is true, the returned nodes are removed
+func (ss *SortedSet) GetByRankRange(start int, end int, remove bool) []*SortedSetNode {
+	start, end, reverse := ss.sanitizeIndexes(start, end)
+
+	nodes := treeAppendRange(nil, ss.byScore, start, end)
+
+	if remove {
+		for _, node := range nodes {
+			ss.deleteNode(node)
+		}
+	}
+
+	if reverse {
+		for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+			nodes[i], nodes[j] = nodes[j], nodes[i]
+		}
+	}
+
+	return nodes
+}
+
+// GetByRank returns the node at given rank, nil if not found
+// Note that the rank is 1-based integer. Rank 1 means the first node; Rank -1 means the last node
+// If remove is true, the returned node is removed
+func (ss *SortedSet) GetByRank(rank int, remove bool) *SortedSetNode {
+	nodes := ss.GetByRankRange(rank, rank, remove)
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+
+	return nil
+}
+
+// GetByKey returns the node at given key, nil if not found
+func (ss *SortedSet) GetByKey(key string) *SortedSetNode {
+	t := ss.byKey
+	for t != nil {
+		switch {
+		case key < t.node.key:
+			t = t.left
+		case key > t.node.key:
+			t = t.right
+		default:
+			return t.node
+		}
+	}
+
+	return nil
+}
+
+// FindRank returns the rank of the node at given key, with the scores ordered from low to high
+// Note that the rank is 1-based integer. Rank 1 means the first node
+// If the node is not found, 0 is returned
+func (ss *SortedSet) FindRank(key string) int {
+	node := ss.GetByKey(key)
+	if node == nil {
+		return 0
+	}
+
+	return treeCount(ss.byScore, func(other *SortedSetNode) bool {
+		return !node.Less(other)
+	})
+}
+
+// Members returns the nodes of the sorted set by key, the map is built at every call
+func (ss *SortedSet) Members() map[string]*SortedSetNode {
+	members := make(map[string]*SortedSetNode, ss.Size())
+	for _, node := range ss.GetByRankRange(1, -1, false) {
+		members[node.key] = node
+	}
+
+	return members
+}
@@ -0,0 +1,302 @@
+package zset
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// keys returns the keys of the nodes
+func keys(nodes []*SortedSetNode) []string {
+	list := make([]string, len(nodes))
+	for i, node := range nodes {
+		list[i] = node.Key()
+	}
+
+	return list
+}
+
+// newTestSet returns a sorted set of the keys "a" to "e" with the scores 1 to 5
+func newTestSet() *SortedSet {
+	ss := New()
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		_ = ss.Put(key, SCORE(i+1), []byte("v"+key))
+	}
+
+	return ss
+}
+
+func TestSortedSet_PutRemove(t *testing.T) {
+	ss := newTestSet()
+
+	if ss.Size() != 5 {
+		t.Fatalf("Size = %d, want 5", ss.Size())
+	}
+
+	// updating the score moves the node
+	_ = ss.Put("a", 10, []byte("va2"))
+	if got := keys(ss.GetByRankRange(1, -1, false)); !reflect.DeepEqual(got, []string{"b", "c", "d", "e", "a"}) {
+		t.Errorf("GetByRankRange = %v", got)
+	}
+
+	if node := ss.GetByKey("a"); node == nil || node.Score() != 10 || string(node.Value) != "va2" {
+		t.Errorf("GetByKey(a) = %v", node)
+	}
+
+	if node := ss.Remove("c"); node == nil || node.Key() != "c" {
+		t.Errorf("Remove(c) = %v", node)
+	}
+
+	if ss.Remove("c") != nil || ss.GetByKey("c") != nil || ss.Size() != 4 {
+		t.Error("the removed node is still in the set")
+	}
+}
+
+func TestSortedSet_Rank(t *testing.T) {
+	ss := newTestSet()
+
+	if node := ss.GetByRank(2, false); node == nil || node.Key() != "b" {
+		t.Errorf("GetByRank(2) = %v", node)
+	}
+
+	if node := ss.GetByRank(-1, false); node == nil || node.Key() != "e" {
+		t.Errorf("GetByRank(-1) = %v", node)
+	}
+
+	if ss.GetByRank(6, false) != nil {
+		t.Error("GetByRank out of range returned a node")
+	}
+
+	if got := keys(ss.GetByRankRange(4, 2, false)); !reflect.DeepEqual(got, []string{"d", "c", "b"}) {
+		t.Errorf("GetByRankRange(4, 2) = %v, want [d c b]", got)
+	}
+
+	if rank := ss.FindRank("d"); rank != 4 {
+		t.Errorf("FindRank(d) = %d, want 4", rank)
+	}
+
+	if rank := ss.FindRank("missing"); rank != 0 {
+		t.Errorf("FindRank(missing) = %d, want 0", rank)
+	}
+
+	if got := keys(ss.GetByRankRange(2, 3, true)); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("GetByRankRange(2, 3, remove) = %v", got)
+	}
+
+	if ss.Size() != 3 || ss.GetByKey("b") != nil || ss.FindRank("d") != 2 {
+		t.Error("GetByRankRange did not remove the nodes")
+	}
+}
+
+func TestSortedSet_PeekPop(t *testing.T) {
+	ss := newTestSet()
+
+	if ss.PeekMin().Key() != "a" || ss.PeekMax().Key() != "e" {
+		t.Errorf("PeekMin, PeekMax = %s, %s", ss.PeekMin().Key(), ss.PeekMax().Key())
+	}
+
+	if ss.PopMin().Key() != "a" || ss.PopMax().Key() != "e" || ss.Size() != 3 {
+		t.Error("PopMin or PopMax returned the wrong node")
+	}
+
+	if ss.PeekMin().Key() != "b" || ss.PeekMax().Key() != "d" {
+		t.Errorf("PeekMin, PeekMax after pop = %s, %s", ss.PeekMin().Key(), ss.PeekMax().Key())
+	}
+
+	empty := New()
+	if empty.PeekMin() != nil || empty.PeekMax() != nil || empty.PopMin() != nil || empty.PopMax() != nil {
+		t.Error("an empty set returned a node")
+	}
+}
+
+func TestSortedSet_RandomOps(t *testing.T) {
+	ss := New()
+	scores := make(map[string]SCORE)
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("k%d", r.Intn(300))
+		if r.Intn(3) == 0 {
+			ss.Remove(key)
+			delete(scores, key)
+			continue
+		}
+
+		score := SCORE(r.Intn(50))
+		_ = ss.Put(key, score, nil)
+		scores[key] = score
+	}
+
+	want := make([]string, 0, len(scores))
+	for key := range scores {
+		want = append(want, key)
+	}
+
+	// the nodes are ordered by score, then by key
+	sort.Slice(want, func(i, j int) bool {
+		if scores[want[i]] != scores[want[j]] {
+			return scores[want[i]] < scores[want[j]]
+		}
+
+		return want[i] < want[j]
+	})
+
+	if got := keys(ss.GetByRankRange(1, -1, false)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetByRankRange = %v, want %v", got, want)
+	}
+
+	for i, key := range want {
+		if rank := ss.FindRank(key); rank != i+1 {
+			t.Errorf("FindRank(%s) = %d, want %d", key, rank, i+1)
+		}
+
+		if node := ss.GetByRank(i+1, false); node.Key() != key {
+			t.Errorf("GetByRank(%d) = %s, want %s", i+1, node.Key(), key)
+		}
+	}
+}
+
+func TestSortedSet_Clone(t *testing.T) {
+	ss := newTestSet()
+	c := ss.Clone()
+
+	_ = c.Put("f", 6, nil)
+	c.Remove("a")
+
+	if ss.Size() != 5 || ss.GetByKey("f") != nil || ss.GetByKey("a") == nil {
+		t.Error("writing the clone changed the set")
+	}
+
+	if got := keys(c.GetByRankRange(1, -1, false)); !reflect.DeepEqual(got, []string{"b", "c", "d", "e", "f"}) {
+		t.Errorf("clone = %v", got)
+	}
+}
+
+func TestSortedSet_ClonePersistent(t *testing.T) {
+	ss := New()
+	r := rand.New(rand.NewSource(1))
+
+	var (
+		clones	[]*SortedSet
+		wants	[][]string
+	)
+
+	// every clone keeps its members while the later clones are written
+	for i := 0; i < 2000; i++ {
+		if i%100 == 0 {
+			clones = append(clones, ss)
+			wants = append(wants, keys(ss.GetByRankRange(1, -1, false)))
+			ss = ss.Clone()
+		}
+
+		key := fmt.Sprintf("k%d", r.Intn(100))
+		if r.Intn(3) == 0 {
+			ss.Remove(key)
+		} else {
+			_ = ss.Put(key, SCORE(r.Intn(20)), nil)
+		}
+	}
+
+	for i, c := range clones {
+		if got := keys(c.GetByRankRange(1, -1, false)); !reflect.DeepEqual(got, wants[i]) {
+			t.Errorf("clone %d = %v, want %v", i, got, wants[i])
+		}
+	}
+}
+
+func TestSortedSet_ZRangeByScore(t *testing.T) {
+	ss := newTestSet()
+	inf := SCORE(math.Inf(1))
+
+	tests := []struct {
+		name		string
+		min, max	SCORE
+		options		*GetByScoreRangeOptions
+		want		[]string
+	}{
+		{"inclusive", 2, 4, nil, []string{"b", "c", "d"}},
+		{"exclude start", 2, 4, &GetByScoreRangeOptions{ExcludeStart: true}, []string{"c", "d"}},
+		{"exclude end", 2, 4, &GetByScoreRangeOptions{ExcludeEnd: true}, []string{"b", "c"}},
+		{"exclude both", 2, 3, &GetByScoreRangeOptions{ExcludeStart: true, ExcludeEnd: true}, []string{}},
+		{"infinite", -inf, inf, nil, []string{"a", "b", "c", "d", "e"}},
+		{"limit offset", -inf, inf, &GetByScoreRangeOptions{Offset: 1, Limit: 2}, []string{"b", "c"}},
+		{"offset past the end", 1, 5, &GetByScoreRangeOptions{Offset: 5}, []string{}},
+		{"empty", 6, 7, nil, []string{}},
+		{"min greater than max", 4, 2, nil, []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := keys(ss.ZRangeByScore(tt.min, tt.max, tt.options)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ZRangeByScore = %v, want %v", tt.name, got, tt.want)
+		}
+
+		// ZCount ignores the limit and the offset
+		paged := tt.options != nil && (tt.options.Limit > 0 || tt.options.Offset > 0)
+		if n := ss.ZCount(tt.min, tt.max, tt.options); !paged && n != len(tt.want) {
+			t.Errorf("%s: ZCount = %d, want %d", tt.name, n, len(tt.want))
+		}
+	}
+
+	nodes := ss.ZRangeByScore(3, 3, nil)
+	if len(nodes) != 1 || nodes[0].Score() != 3 {
+		t.Errorf("ZRangeByScore(3, 3) did not return the score")
+	}
+}
+
+func TestSortedSet_ZRevRangeByScore(t *testing.T) {
+	ss := newTestSet()
+	inf := SCORE(math.Inf(1))
+
+	tests := []struct {
+		name		string
+		max, min	SCORE
+		options		*GetByScoreRangeOptions
+		want		[]string
+	}{
+		{"inclusive", 4, 2, nil, []string{"d", "c", "b"}},
+		{"exclude start", 4, 2, &GetByScoreRangeOptions{ExcludeStart: true}, []string{"c", "b"}},
+		{"exclude end", 4, 2, &GetByScoreRangeOptions{ExcludeEnd: true}, []string{"d", "c"}},
+		{"limit offset", inf, -inf, &GetByScoreRangeOptions{Offset: 1, Limit: 3}, []string{"d", "c", "b"}},
+		{"offset past the end", inf, -inf, &GetByScoreRangeOptions{Offset: 5}, []string{}},
+		{"empty", 0, -1, nil, []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := keys(ss.ZRevRangeByScore(tt.max, tt.min, tt.options)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ZRevRangeByScore = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// GetByScoreRange returns the reverse order when start is greater than end
+	if got := keys(ss.GetByScoreRange(3, 1, nil)); !reflect.DeepEqual(got, []string{"c", "b", "a"}) {
+		t.Errorf("GetByScoreRange(3, 1) = %v", got)
+	}
+}
+
+func TestParseScoreBound(t *testing.T) {
+	tests := []struct {
+		bound		string
+		score		SCORE
+		exclusive	bool
+	}{
+		{"1.5", 1.5, false},
+		{"(2", 2, true},
+		{"-inf", SCORE(math.Inf(-1)), false},
+		{"+inf", SCORE(math.Inf(1)), false},
+		{"(+inf", SCORE(math.Inf(1)), true},
+	}
+
+	for _, tt := range tests {
+		score, exclusive, err := ParseScoreBound(tt.bound)
+		if err != nil || score != tt.score || exclusive != tt.exclusive {
+			t.Errorf("ParseScoreBound(%q) = %v, %v, %v", tt.bound, score, exclusive, err)
+		}
+	}
+
+	if _, _, err := ParseScoreBound("abc"); err == nil {
+		t.Error("ParseScoreBound of a malformed bound returned no error")
+	}
+}
@@ -0,0 +1,9 @@
+module github.com/HelloChenHZ/nutsdb
+
+go 1.16
+
+require (
+	github.com/bwmarrin/snowflake v0.3.0
+	github.com/xujiajun/mmap-go v1.0.1
+	github.com/xujiajun/utils v0.0.0-20190123093513-8bf096c4f53b
+)
@@ -1,5 +1,7 @@
 package nutsdb
 
+import "time"
+
 // EntryIdxMode represents entry index mode
 type EntryIdxMode int
 
@@ -35,8 +37,38 @@ type Options struct {
 	// is SyncEnable is true, slower but persistent
 	SyncEnable bool
 
+	// GroupCommitMaxBatch represents the max number of txs committed together with a single Sync()
+	// the txs committing at the same time are queued and committed by groups
+	// if GroupCommitMaxBatch <= 1, every tx is committed on its own, the default
+	// Set it to 128 or so to raise the throughput of the concurrent writers when SyncEnable is true
+	GroupCommitMaxBatch int
+
+	// GroupCommitMaxWait represents how long the first tx of a group waits for other txs to join it
+	// if GroupCommitMaxWait is 0, a group only holds the txs queued while the previous group was committed
+	GroupCommitMaxWait time.Duration
+
+	// MaxTxPendingCount represents the max number of entries written by a tx
+	// a write over the limit returns ErrTxnTooBig, if MaxTxPendingCount is 0 the number is not limited, the default
+	// Set it, like 100000, to bound the memory a single tx can hold
+	MaxTxPendingCount int
+
+	// MaxTxPendingSize represents the max size in bytes of the entries written by a tx, they are kept in memory until the commit
+	// a write over the limit returns ErrTxnTooBig, if MaxTxPendingSize is 0 the size is not limited, the default
+	// Set it, like 64 * 1024 * 1024, to bound the memory a single tx can hold
+	// A tx larger than SegmentSize is written across several segments
+	MaxTxPendingSize int64
+
 	// StartFileLoadingMode represents when open a database which RWMode to load files
 	StartFileLoadingMode RWMode
+
+	// CloseTimeout represents how long Close waits for the txs in progress, every tx must be closed
+	// by Commit or Rollback, if CloseTimeout is 0 Close waits until they are closed, the default
+	// Set it to make Close return ErrTxInProgress instead of blocking on a tx that is never closed
+	CloseTimeout time.Duration
+
+	// RandSeed represents the seed of the random source used by SPop and SRandMember
+	// if RandSeed is 0, the random source is seeded with the current time
+	RandSeed int64
 }
 
 var defaultSegmenSize int64 = 8 * 1024 * 1024
@@ -4,8 +4,11 @@ import "time"
 
 // Record records entry and hint
 type Record struct {
-	H *Hint
-	E *Entry
+	H		*Hint
+	E		*Entry
+	seq			uint64	// the commit sequence of the version
+	prev		*Record	// the previous version, kept while a snapshot may read it
+	keyVersion	uint64	// the number of writes of the key, deletions included, see Tx.GetWithVersion
 }
 
 // IsExpired returns the record if expired or not
@@ -29,4 +32,20 @@ func (r *Record) UpdateRecord(h *Hint, e *Entry) error {
 	r.H = h
 
 	return nil
-}
\ No newline at end of file
+}
+
+// version returns the version of the record visible by the snapshot of seq, nil if the key did not exist yet
+func (r *Record) version(seq uint64) *Record {
+	for r != nil && r.seq > seq {
+		r = r.prev
+	}
+
+	return r
+}
+
+// prune drops the versions older than the one visible by the snapshot of seq
+func (r *Record) prune(seq uint64) {
+	if v := r.version(seq); v != nil {
+		v.prev = nil
+	}
+}
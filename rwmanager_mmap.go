@@ -49,6 +49,18 @@ func (mm *MMapRWManager) WriteAt(b []byte, off int64) (n int, err error) {
 		return 0, ErrIndexOutOfBound
 	}
 
+	return copy(mm.m[off:], b), nil
+}
+
+// ReadAt copies data to b slice from mapped region starting at
+// given off and returns number of bytes copied to the b slice
+func (mm *MMapRWManager) ReadAt(b []byte, off int64) (n int, err error) {
+	if mm.m == nil {
+		return 0, ErrUnmappedMemory
+	} else if off >= int64(len(mm.m)) || off < 0 {
+		return 0, ErrIndexOutOfBound
+	}
+
 	return copy(b, mm.m[off:]), nil
 }
 
@@ -0,0 +1,331 @@
+package nutsdb
+
+import (
+	"time"
+
+	"github.com/HelloChenHZ/nutsdb/ds/hash"
+	"github.com/HelloChenHZ/nutsdb/ds/hll"
+	"github.com/HelloChenHZ/nutsdb/ds/list"
+	"github.com/HelloChenHZ/nutsdb/ds/stream"
+	"github.com/HelloChenHZ/nutsdb/ds/zset"
+)
+
+type (
+	// snapshot represents the indexes as of a commit sequence, it is shared by the txs that began after that commit
+	// The maps are copies of the maps of the DB while the index objects are shared with the DB:
+	// the b+ trees keep the versions of their records, and the other index objects are copied
+	// by the commits before being modified while a snapshot may read them
+	snapshot struct {
+		indexes
+		seq	uint64
+	}
+
+	// bucketKey represents the bucket of a data structure
+	bucketKey struct {
+		ds		uint16
+		bucket	string
+	}
+
+	// commitState represents the snapshots in use while a commit is applied to the indexes
+	commitState struct {
+		seq		uint64	// the commit sequence of the commit
+		readers	bool	// whether some snapshots are in use
+		oldest	uint64	// the smallest seq of the snapshots in use
+		newest	uint64	// the greatest seq of the snapshots in use
+	}
+
+	// version represents a record that kept its previous versions at the commit seq
+	version struct {
+		r	*Record
+		seq	uint64
+	}
+)
+
+// acquireSnapshot returns the snapshot of the last commit and registers the tx reading it,
+// so that its versions are kept and Close waits for the tx
+func (db *DB) acquireSnapshot(writable bool) (*snapshot, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrDBClosed
+	}
+
+	db.snapMu.Lock()
+	defer db.snapMu.Unlock()
+
+	if db.snap == nil {
+		db.snap = &snapshot{indexes: db.indexes.copy(), seq: db.seq}
+	}
+
+	db.readers[db.seq]++
+	if writable {
+		db.writers[db.seq]++
+	}
+
+	db.txCount++
+
+	return db.snap, nil
+}
+
+// endTx unregisters a closed tx, Close is notified when the last tx in progress is closed
+func (db *DB) endTx() {
+	db.snapMu.Lock()
+	defer db.snapMu.Unlock()
+
+	if db.txCount--; db.txCount == 0 && db.txsDone != nil {
+		close(db.txsDone)
+		db.txsDone = nil
+	}
+}
+
+// waitTxs waits for the txs in progress up to timeout, 0 waits until they are closed
+// It returns ErrTxInProgress on timeout
+func (db *DB) waitTxs(timeout time.Duration) error {
+	db.snapMu.Lock()
+	if db.txCount == 0 {
+		db.snapMu.Unlock()
+		return nil
+	}
+
+	if db.txsDone == nil {
+		db.txsDone = make(chan struct{})
+	}
+
+	done := db.txsDone
+	db.snapMu.Unlock()
+
+	if timeout <= 0 {
+		<-done
+		return nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return ErrTxInProgress
+	}
+}
+
+// releaseSnapshot unregisters a tx reading the snapshot, its old versions are reclaimed by the next commit
+func (db *DB) releaseSnapshot(snap *snapshot, writable bool) {
+	db.snapMu.Lock()
+	defer db.snapMu.Unlock()
+
+	if db.readers[snap.seq]--; db.readers[snap.seq] == 0 {
+		delete(db.readers, snap.seq)
+	}
+
+	if !writable {
+		return
+	}
+
+	if db.writers[snap.seq]--; db.writers[snap.seq] == 0 {
+		delete(db.writers, snap.seq)
+	}
+}
+
+// beginCommit prepares the indexes for the commit of the next seq, db.mu must be locked
+func (db *DB) beginCommit() {
+	db.snapMu.Lock()
+	defer db.snapMu.Unlock()
+
+	db.commit = commitState{seq: db.seq + 1}
+	for seq := range db.readers {
+		if !db.commit.readers || seq < db.commit.oldest {
+			db.commit.oldest = seq
+		}
+
+		if !db.commit.readers || seq > db.commit.newest {
+			db.commit.newest = seq
+		}
+
+		db.commit.readers = true
+	}
+}
+
+// endCommit publishes the commit to the next snapshots and prunes the versions that no snapshot in use can read,
+// db.mu must be locked
+func (db *DB) endCommit() {
+	db.seq = db.commit.seq
+	db.snap = nil
+
+	oldest := db.seq
+	if db.commit.readers {
+		oldest = db.commit.oldest
+	}
+
+	n := 0
+	for n < len(db.versions) && db.versions[n].seq <= oldest {
+		db.versions[n].r.prune(oldest)
+		n++
+	}
+
+	if db.versions = db.versions[n:]; len(db.versions) == 0 {
+		db.versions = nil
+	}
+
+	db.commit = commitState{}
+}
+
+// isShared returns if a snapshot in use may read the version or the index object of the commit seq
+func (c *commitState) isShared(seq uint64) bool {
+	return c.readers && seq <= c.newest
+}
+
+// insertRecord inserts the record in the b+ tree of the bucket as the version of the commit
+func (db *DB) insertRecord(bucket string, e *Entry, h *Hint, countFlag bool) error {
+	r, err := db.BPTreeIdx[bucket].InsertVersion(h.key, e, h, countFlag, db.commit.seq, db.commit.isShared)
+	if err != nil {
+		return err
+	}
+
+	if r.prev != nil {
+		db.versions = append(db.versions, version{r: r, seq: r.seq})
+	}
+
+	return nil
+}
+
+// ownBucket makes the index object of the bucket of the data structure modifiable by the commit,
+// it is created if the bucket not exists and copied if a snapshot in use may read it
+// The copies are cheap, they share the members of the keys the commit does not write
+// The b+ trees are never copied since they keep the versions of their records
+func (db *DB) ownBucket(ds uint16, bucket string) {
+	if !db.hasBucket(ds, bucket) {
+		_ = db.newBucket(ds, bucket)
+		return
+	}
+
+	key := bucketKey{ds: ds, bucket: bucket}
+	if ds == DataStrucctureBPTree || !db.commit.isShared(db.bucketSeqs[key]) {
+		return
+	}
+
+	switch ds {
+	case DataStructureSet:
+		db.SetIdx[bucket] = db.SetIdx[bucket].Clone()
+	case DataStructureSortedSet:
+		db.SortedSetIdx[bucket] = db.SortedSetIdx[bucket].Clone()
+	case DataStructureHash:
+		db.HashIdx[bucket] = db.HashIdx[bucket].Clone()
+	case DataStructureHLL:
+		db.HLLIdx[bucket] = db.HLLIdx[bucket].Clone()
+	case DataStructureStream:
+		db.StreamIdx[bucket] = db.StreamIdx[bucket].Clone()
+	default:
+		// the lists are not modified by the commits
+		return
+	}
+
+	db.bucketSeqs[key] = db.commit.seq
+}
+
+// newBucket creates the empty index object of the bucket of the data structure
+func (db *DB) newBucket(ds uint16, bucket string) error {
+	switch ds {
+	case DataStrucctureBPTree:
+		t := NewTree()
+		t.baseKeyVersion = db.keyVersions[bucket]
+		t.maxKeyVersion = t.baseKeyVersion
+		db.BPTreeIdx[bucket] = t
+	case DataStructureSet:
+		db.SetIdx[bucket] = db.newSet()
+	case DataStructureSortedSet:
+		db.SortedSetIdx[bucket] = zset.New()
+	case DataStructureList:
+		db.ListIdx[bucket] = list.New()
+	case DataStructureHash:
+		db.HashIdx[bucket] = hash.New()
+	case DataStructureHLL:
+		db.HLLIdx[bucket] = hll.New()
+	case DataStructureStream:
+		db.StreamIdx[bucket] = stream.New()
+	default:
+		return ErrDataStructure
+	}
+
+	db.bucketSeqs[bucketKey{ds: ds, bucket: bucket}] = db.commit.seq
+
+	return nil
+}
+
+// copy returns a copy of the maps of the indexes, the index objects are shared
+func (idx *indexes) copy() indexes {
+	c := indexes{
+		BPTreeIdx:		make(BPTreeIdx, len(idx.BPTreeIdx)),
+		SetIdx:			make(SetIdx, len(idx.SetIdx)),
+		SortedSetIdx:	make(SortedSetIdx, len(idx.SortedSetIdx)),
+		ListIdx:		make(ListIdx, len(idx.ListIdx)),
+		HashIdx:		make(HashIdx, len(idx.HashIdx)),
+		HLLIdx:			make(HLLIdx, len(idx.HLLIdx)),
+		StreamIdx:		make(StreamIdx, len(idx.StreamIdx)),
+	}
+
+	for bucket, t := range idx.BPTreeIdx {
+		c.BPTreeIdx[bucket] = t
+	}
+
+	for bucket, s := range idx.SetIdx {
+		c.SetIdx[bucket] = s
+	}
+
+	for bucket, ss := range idx.SortedSetIdx {
+		c.SortedSetIdx[bucket] = ss
+	}
+
+	for bucket, l := range idx.ListIdx {
+		c.ListIdx[bucket] = l
+	}
+
+	for bucket, h := range idx.HashIdx {
+		c.HashIdx[bucket] = h
+	}
+
+	for bucket, h := range idx.HLLIdx {
+		c.HLLIdx[bucket] = h
+	}
+
+	for bucket, s := range idx.StreamIdx {
+		c.StreamIdx[bucket] = s
+	}
+
+	return c
+}
+
+// findRecord returns the version of the record at given key visible by the snapshot of the tx
+// The returned record is a copy, so it is not affected by the next commits
+func (tx *Tx) findRecord(t *BPTree, key []byte) (*Record, error) {
+	tx.db.mu.RLock()
+	defer tx.db.mu.RUnlock()
+
+	r, err := t.Find(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if r = r.version(tx.snap.seq); r == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	return &Record{H: r.H, E: r.E, seq: r.seq, keyVersion: r.keyVersion}, nil
+}
+
+// allRecords returns the versions of the records of the tree visible by the snapshot of the tx in the order of the keys
+func (tx *Tx) allRecords(t *BPTree) []*Record {
+	tx.db.mu.RLock()
+	defer tx.db.mu.RUnlock()
+
+	var records []*Record
+	for _, r := range t.All() {
+		if r = r.version(tx.snap.seq); r != nil {
+			records = append(records, &Record{H: r.H, E: r.E, seq: r.seq, keyVersion: r.keyVersion})
+		}
+	}
+
+	return records
+}
@@ -0,0 +1,160 @@
+package nutsdb
+
+import (
+	"testing"
+)
+
+// getValue returns the value of the key in the tx, or the error message
+func getValue(tx *Tx, bucket, key string) string {
+	e, err := tx.Get(bucket, []byte(key))
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(e.Value)
+}
+
+func TestSnapshot_Isolation(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.Put("bucket", []byte("a"), []byte("1"), Persistent); err != nil {
+			return err
+		}
+
+		if err := tx.Put("bucket", []byte("b"), []byte("1"), Persistent); err != nil {
+			return err
+		}
+
+		if err := tx.SAdd("set", []byte("s"), []byte("x")); err != nil {
+			return err
+		}
+
+		if err := tx.HSet("hash", []byte("h"), []byte("f"), []byte("1")); err != nil {
+			return err
+		}
+
+		return tx.ZAdd("zset", []byte("m"), 1, nil)
+	})
+
+	reader, err := db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = reader.Rollback()
+	}()
+
+	for i := 2; i <= 3; i++ {
+		update(t, db, func(tx *Tx) error {
+			if err := tx.Put("bucket", []byte("a"), []byte{byte('0' + i)}, Persistent); err != nil {
+				return err
+			}
+
+			if err := tx.Delete("bucket", []byte("b")); err != nil && err != ErrNotFoundKey {
+				return err
+			}
+
+			if err := tx.Put("bucket", []byte("c"), []byte("new"), Persistent); err != nil {
+				return err
+			}
+
+			if err := tx.SAdd("set", []byte("s"), []byte("y")); err != nil {
+				return err
+			}
+
+			if err := tx.HSet("hash", []byte("h"), []byte("f"), []byte{byte('0' + i)}); err != nil {
+				return err
+			}
+
+			return tx.ZAdd("zset", []byte("n"), 2, nil)
+		})
+	}
+
+	update(t, db, func(tx *Tx) error {
+		return tx.DeleteBucket(DataStructureSet, "set")
+	})
+
+	// the reader sees the state as of its beginning
+	for key, want := range map[string]string{"a": "1", "b": "1", "c": ErrNotFoundKey.Error()} {
+		if got := getValue(reader, "bucket", key); got != want {
+			t.Errorf("Get(%s) in the old snapshot = %q, want %q", key, got, want)
+		}
+	}
+
+	if list, err := reader.SMembers("set", []byte("s")); err != nil || len(list) != 1 {
+		t.Errorf("SMembers in the old snapshot = %q, %v, want [x]", list, err)
+	}
+
+	if v, err := reader.HGet("hash", []byte("h"), []byte("f")); err != nil || string(v) != "1" {
+		t.Errorf("HGet in the old snapshot = %q, %v, want 1", v, err)
+	}
+
+	if n, err := reader.ZCard("zset"); err != nil || n != 1 {
+		t.Errorf("ZCard in the old snapshot = %d, %v, want 1", n, err)
+	}
+
+	view(t, db, func(tx *Tx) error {
+		for key, want := range map[string]string{"a": "3", "b": ErrNotFoundKey.Error(), "c": "new"} {
+			if got := getValue(tx, "bucket", key); got != want {
+				t.Errorf("Get(%s) in a new snapshot = %q, want %q", key, got, want)
+			}
+		}
+
+		if _, err := tx.SMembers("set", []byte("s")); err != ErrBucket {
+			t.Errorf("SMembers of a deleted bucket returned %v, want ErrBucket", err)
+		}
+
+		return nil
+	})
+}
+
+func TestSnapshot_PruneVersions(t *testing.T) {
+	db := openTestDB(t)
+
+	put := func(value string) {
+		update(t, db, func(tx *Tx) error {
+			return tx.Put("bucket", []byte("k"), []byte(value), Persistent)
+		})
+	}
+
+	put("1")
+
+	reader, err := db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put("2")
+	put("3")
+
+	db.mu.RLock()
+	kept := len(db.versions)
+	db.mu.RUnlock()
+
+	if kept == 0 {
+		t.Fatal("no version kept for the snapshot in use")
+	}
+
+	if got := getValue(reader, "bucket", "k"); got != "1" {
+		t.Errorf("Get in the old snapshot = %q, want 1", got)
+	}
+
+	_ = reader.Rollback()
+
+	// the next commit prunes the versions nobody can read anymore
+	put("4")
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if len(db.versions) != 0 {
+		t.Errorf("%d versions kept after the snapshot was released", len(db.versions))
+	}
+
+	r, err := db.BPTreeIdx["bucket"].Find([]byte("k"))
+	if err != nil || r.prev != nil || string(r.E.Value) != "4" {
+		t.Errorf("the record of k keeps its previous versions: %v", err)
+	}
+}
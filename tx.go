@@ -2,7 +2,7 @@ package nutsdb
 
 import (
 	"errors"
-	"github.com/bwmarrin/snowflake"
+	"github.com/HelloChenHZ/nutsdb/ds/hll"
 )
 
 var (
@@ -25,6 +25,14 @@ var (
 
 	// ErrNotFoundKey is returned when key not found in the bucket on an view function
 	ErrNotFoundKey = errors.New("key not found in the bucket")
+
+	// ErrTxnTooBig is returned when a write would make the pending writes of the tx exceed
+	// Options.MaxTxPendingCount or Options.MaxTxPendingSize
+	ErrTxnTooBig = errors.New("tx is too big")
+
+	// ErrWriteFailed is returned by Commit once a sync of the data files failed, the data written since the last
+	// successful sync may be lost, so the db accepts no write anymore and must be reopened
+	ErrWriteFailed = errors.New("a previous sync failed, the db is read-only")
 )
 
 // Tx represents a transaction
@@ -33,25 +41,29 @@ type Tx struct {
 	db				*DB
 	writable		bool
 	pendingWrites	[]*Entry
+	pendingSize		int64 // the size of the entries of pendingWrites
+	sketches		map[string]*hll.HLL // the HyperLogLogs modified or read by the tx, see getSketch
+	sortedSets		map[string]*txView // the sorted sets written by the tx as seen inside it, see sortedSetView
+	streams			map[string]*txView // the streams written by the tx as seen inside it, see streamView
+	snap			*snapshot // the committed state read by the tx
+	reads			accessSet // the keys read by the writable tx, see validate
 }
 
 // Begin opens a new transaction
-// Mutiple read-only transactions can be opened at the same time but there can
-// only be one read/write transaction at a time. Attempting to open a read/write
-// transactions while another one is in progress will result in blocking until
-// the current read/write transaction is completed
+// Mutiple read-only and read/write transactions can be opened at the same time
+// Every transaction reads a consistent snapshot of the data committed before it began,
+// so the transactions never block each other until a read/write transaction commits
+// A read/write transaction fails to commit with ErrConflict if a key it read or wrote
+// was written by another transaction committed after it began, see UpdateWithRetry
 // All transactions must be closed by calling Commit() or Rollback() when done
-func (db *DB) Begin(writable bool) (tx *Tx. err error) {
+func (db *DB) Begin(writable bool) (tx *Tx, err error) {
 	tx, err = newTx(db, writable)
 	if err != nil {
 		return nil, err
 	}
 
-	tx.lock()
-
-	if db.closed {
-		tx.unlock()
-		return nil, ErrDBClosed
+	if tx.snap, err = db.acquireSnapshot(writable); err != nil {
+		return nil, err
 	}
 
 	return
@@ -78,20 +90,343 @@ func newTx(db *DB, writable bool) (tx *Tx, err error) {
 
 // getTxID returns the tx id
 func (tx *Tx) getTxID() (id uint64, err error) {
-	node, err := snowflake.NewNode(tx.db.opt.NodeNum)
-	if err != nil {
-		return 0, err
+	id = uint64(tx.db.idNode.Generate().Int64())
+	return
+}
+
+// close releases the snapshot of the tx and clears its db field
+func (tx *Tx) close() {
+	if tx.snap != nil {
+		tx.db.releaseSnapshot(tx.snap, tx.writable)
 	}
 
-	id = uint64(node.Generate().Int64())
-	return
+	tx.db.endTx()
+
+	tx.db = nil
+	tx.snap = nil
+	tx.pendingWrites = nil
+	tx.pendingSize = 0
 }
 
-// lock locks the database based on the transaction type
-func (tx *Tx) lock() {
-	if tx.writable {
-		tx.db.mu.Lock()
+// Commit commits the transaction, following these steps:
+//
+// 1. check the length of pendingWrites.If there are no writes, return immediately.
+//
+// 2. queue the tx if the group commit is enabled, the queued txs are committed by groups, see Options.GroupCommitMaxBatch.
+//
+// 3. check that no tx committed before the tx, after it began, wrote the keys read or written by the tx, if not, return ErrConflict.
+//
+// 4. write pendingWrites to disk, calling rotateActiveFile when the ActiveFile has not enough space to store an entry,
+// so a tx larger than a segment spans several segments.
+//
+// 5. sync the ActiveFile once for the whole group, if a non-nil error, return the error, the next commits return ErrWriteFailed.
+//
+// 6. build indexes for the entries that were written, as the version seen by the next snapshots.
+//
+// 7. clear the db field, the tx is closed even if it fails to commit.
+func (tx *Tx) Commit() error {
+	if tx.db == nil {
+		return ErrDBClosed
+	}
+
+	if len(tx.pendingWrites) == 0 {
+		tx.close()
+		return nil
+	}
+
+	var err error
+	if tx.db.groupCommits != nil {
+		err = tx.db.queueCommit(tx)
 	} else {
-		tx.db.mu.RLock()
+		err = tx.db.commitGroup([]*Tx{tx})[0]
+	}
+
+	tx.close()
+
+	return err
+}
+
+// checkEntrySize returns ErrKeyAndValSize if an entry of the tx cannot be stored in a segment
+func (tx *Tx) checkEntrySize() error {
+	for _, entry := range tx.pendingWrites {
+		if entry.Size() > tx.db.opt.SegmentSize {
+			return ErrKeyAndValSize
+		}
+	}
+
+	return nil
+}
+
+// writeEntries writes pendingWrites to the ActiveFile without syncing it, and returns the position of every entry
+// The entries may span several segments, the recovery replays them as a whole since the last one marks the tx committed
+func (tx *Tx) writeEntries() (fileIDs, offs []int64, err error) {
+	writesLen := len(tx.pendingWrites)
+	offs = make([]int64, writesLen)
+	fileIDs = make([]int64, writesLen)
+
+	lastIndex := writesLen - 1
+	for i := 0; i < writesLen; i++ {
+		entry := tx.pendingWrites[i]
+		entrySize := entry.Size()
+
+		if tx.db.ActiveFile.ActualSize+entrySize > tx.db.opt.SegmentSize {
+			if err := tx.rotateActiveFile(); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		// the last entry marks the whole tx as committed
+		if i == lastIndex {
+			entry.Meta.status = Committed
+		}
+
+		off := tx.db.ActiveFile.writeOff
+
+		if _, err := tx.db.ActiveFile.WriteAt(entry.Encode(), off); err != nil {
+			return nil, nil, err
+		}
+
+		tx.db.ActiveFile.ActualSize += entrySize
+		tx.db.ActiveFile.writeOff += entrySize
+
+		offs[i], fileIDs[i] = off, tx.db.ActiveFile.fileID
+	}
+
+	return fileIDs, offs, nil
+}
+
+// apply builds the indexes for the entries written at the given positions, as the commit of the next seq
+func (tx *Tx) apply(writes *accessSet, fileIDs, offs []int64) {
+	countFlag := CountFlagEnabled
+	if tx.db.isMergeing {
+		countFlag = CountFlagDisabled
 	}
-}
\ No newline at end of file
+
+	// the tx reads nothing anymore, so the commit does not need to keep its snapshot
+	tx.db.releaseSnapshot(tx.snap, tx.writable)
+	tx.snap = nil
+
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	tx.db.beginCommit()
+
+	for i, entry := range tx.pendingWrites {
+		var e *Entry
+		if tx.db.opt.EntryIdxMode == HintKeyValAndRAMIdxMode {
+			e = entry
+		}
+
+		if entry.Meta.ds == DataStrucctureBPTree {
+			tx.buildTreeIdx(entry, e, fileIDs[i], offs[i], countFlag)
+		}
+	}
+
+	tx.buildIdxes()
+
+	tx.db.committedTxIds[tx.id] = struct{}{}
+
+	tx.db.recordWrites(writes)
+	tx.db.endCommit()
+}
+
+// buildTreeIdx builds the BPTree index at the given entry
+func (tx *Tx) buildTreeIdx(entry, e *Entry, fileID, off int64, countFlag bool) {
+	bucket := string(entry.Meta.bucket)
+
+	if entry.Meta.Flag == DataCreateBucketFlag || entry.Meta.Flag == DataDeleteBucketFlag {
+		_ = tx.db.buildBucketIdx(entry.Meta.ds, bucket, entry.Meta.Flag)
+		return
+	}
+
+	tx.db.ownBucket(DataStrucctureBPTree, bucket)
+
+	h := &Hint{
+		key:		entry.Key,
+		fileID:		fileID,
+		meta:		entry.Meta,
+		dataPos:	uint64(off),
+	}
+
+	if entry.Meta.Flag == DataSetBitFlag {
+		_ = tx.db.buildSetBitIdx(bucket, entry.Value, h, countFlag)
+		return
+	}
+
+	_ = tx.db.insertRecord(bucket, e, h, countFlag)
+}
+
+// buildIdxes builds the indexes of the other data structures after the entries were written
+func (tx *Tx) buildIdxes() {
+	for _, entry := range tx.pendingWrites {
+		bucket := string(entry.Meta.bucket)
+		tx.db.KeyCount++
+
+		// the BPTree buckets were already built along with the tree
+		if entry.Meta.Flag == DataCreateBucketFlag || entry.Meta.Flag == DataDeleteBucketFlag {
+			if entry.Meta.ds != DataStrucctureBPTree {
+				_ = tx.db.buildBucketIdx(entry.Meta.ds, bucket, entry.Meta.Flag)
+			}
+
+			continue
+		}
+
+		if entry.Meta.ds == DataStructureSet {
+			tx.buildSetIdx(bucket, entry)
+		}
+
+		if entry.Meta.ds == DataStructureSortedSet {
+			_ = tx.db.buildSortedSetIdx(bucket, entry)
+		}
+
+		if entry.Meta.ds == DataStructureHash {
+			_ = tx.db.buildHashIdx(bucket, entry)
+		}
+
+		if entry.Meta.ds == DataStructureHLL {
+			_ = tx.db.buildHLLIdx(bucket, entry)
+		}
+
+		if entry.Meta.ds == DataStructureStream {
+			_ = tx.db.buildStreamIdx(bucket, entry)
+		}
+	}
+}
+
+// buildSetIdx applies the set entry to the SetIdx
+func (tx *Tx) buildSetIdx(bucket string, entry *Entry) {
+	tx.db.ownBucket(DataStructureSet, bucket)
+
+	if entry.Meta.Flag == DataDeleteFlag {
+		_ = tx.db.SetIdx[bucket].SRem(string(entry.Key), entry.Value)
+	}
+
+	if entry.Meta.Flag == DataSetFlag {
+		_ = tx.db.SetIdx[bucket].SAdd(string(entry.Key), entry.Value)
+	}
+}
+
+// rotateActiveFile rotates log file when active file is not enough space to store the entry
+func (tx *Tx) rotateActiveFile() error {
+	var err error
+
+	tx.db.MaxFileID++
+
+	// the entries are synced once per commit, so the ActiveFile may hold entries not synced yet
+	if tx.db.opt.SyncEnable || tx.db.opt.RWMode == MMap {
+		if err := tx.db.ActiveFile.Sync(); err != nil {
+			tx.db.writeErr = err
+			return err
+		}
+	}
+
+	tx.db.fileMu.Lock()
+	defer tx.db.fileMu.Unlock()
+
+	if err := tx.db.ActiveFile.Close(); err != nil {
+		return err
+	}
+
+	// reset ActiveFile
+	path := tx.db.getDataPath(tx.db.MaxFileID)
+	tx.db.ActiveFile, err = NewDataFile(path, tx.db.opt.SegmentSize, tx.db.opt.RWMode)
+	if err != nil {
+		return err
+	}
+
+	tx.db.ActiveFile.fileID = tx.db.MaxFileID
+
+	return nil
+}
+
+// Rollback closes the transaction
+func (tx *Tx) Rollback() error {
+	if tx.db == nil {
+		return ErrDBClosed
+	}
+
+	tx.close()
+
+	return nil
+}
+
+// checkTxIsClosed returns ErrTxClosed if the tx is closed
+func (tx *Tx) checkTxIsClosed() error {
+	if tx.db == nil {
+		return ErrTxClosed
+	}
+
+	return nil
+}
+
+// put appends the entry to the pendingWrites of the tx
+func (tx *Tx) put(bucket string, key, value []byte, ttl uint32, flag uint16, timestamp uint64, ds uint16) error {
+	if err := tx.checkTxIsClosed(); err != nil {
+		return err
+	}
+
+	if !tx.writable {
+		return ErrTxNoWritable
+	}
+
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+
+	size := int64(DataEntryHeaderSize + len(key) + len(value) + len(bucket))
+	if err := tx.checkPendingSize(size); err != nil {
+		return err
+	}
+
+	tx.pendingSize += size
+	tx.pendingWrites = append(tx.pendingWrites, &Entry{
+		Key:	key,
+		Value:	value,
+		Meta:	&MetaData{
+			keySize:	uint32(len(key)),
+			valueSize:	uint32(len(value)),
+			timestamp:	timestamp,
+			Flag:		flag,
+			TTL:		ttl,
+			bucket:		[]byte(bucket),
+			bucketSize:	uint32(len(bucket)),
+			status:		UnCommitted,
+			ds:			ds,
+			txID:		tx.id,
+		},
+	})
+
+	return nil
+}
+
+// checkPendingSize returns ErrTxnTooBig if an entry of the given size cannot be added to the pending writes of the tx
+func (tx *Tx) checkPendingSize(size int64) error {
+	if max := tx.db.opt.MaxTxPendingCount; max > 0 && len(tx.pendingWrites) >= max {
+		return ErrTxnTooBig
+	}
+
+	if max := tx.db.opt.MaxTxPendingSize; max > 0 && tx.pendingSize+size > max {
+		return ErrTxnTooBig
+	}
+
+	return nil
+}
+
+// txView represents the index of a bucket as seen inside the tx, a copy of the committed index
+// with the pending writes of the tx applied, see Tx.sortedSetView and Tx.streamView
+type txView struct {
+	idx		interface{}
+	applied	int // the number of the pending writes looked at, the ones of the other buckets included
+}
+
+// bucketWrites returns the pending writes of the tx on the bucket of the data structure from the index from
+func (tx *Tx) bucketWrites(ds uint16, bucket string, from int) []*Entry {
+	var writes []*Entry
+	for _, e := range tx.pendingWrites[from:] {
+		if e.Meta.ds == ds && string(e.Meta.bucket) == bucket {
+			writes = append(writes, e)
+		}
+	}
+
+	return writes
+}
@@ -0,0 +1,86 @@
+package nutsdb
+
+import (
+	"time"
+
+	"github.com/HelloChenHZ/nutsdb/ds/bitmap"
+)
+
+// SetBit sets or clears the bit at offset in the value of a key in the bucket and returns the original bit
+// If the key does not exist, a new value is created, the value is grown with zero bytes as needed
+// Only the offset and the bit are logged, so a bit flip does not rewrite the whole value on disk
+func (tx *Tx) SetBit(bucket string, key []byte, offset uint64, bit int) (int, error) {
+	value, meta, err := tx.currentValue(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	// validate the offset and the bit, value is a private copy when modified by the pending writes
+	if _, _, err = bitmap.SetBit(nil, offset, bit); err != nil {
+		return 0, err
+	}
+
+	ttl := Persistent
+	if meta != nil {
+		ttl = remainingTTL(meta)
+	}
+
+	orig := bitmap.GetBit(value, offset)
+
+	return orig, tx.put(bucket, key, bitmap.EncodePatch(offset, bit), ttl, DataSetBitFlag, uint64(time.Now().Unix()), DataStrucctureBPTree)
+}
+
+// GetBit returns the bit value at offset in the value of a key in the bucket
+// When the key does not exist or offset is beyond the value length, the bit is assumed to be 0
+func (tx *Tx) GetBit(bucket string, key []byte, offset uint64) (int, error) {
+	value, _, err := tx.currentValue(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	return bitmap.GetBit(value, offset), nil
+}
+
+// BitCount returns the number of the set bits in the value of a key in the bucket
+// between the bytes start and end (both inclusive), negative indexes count from the end of the value
+func (tx *Tx) BitCount(bucket string, key []byte, start, end int) (int, error) {
+	value, _, err := tx.currentValue(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	return bitmap.BitCount(value, start, end), nil
+}
+
+// BitPos returns the position of the first bit set to bit in the value of a key in the bucket
+// between the bytes start and end (both inclusive), see bitmap.BitPos for the details
+func (tx *Tx) BitPos(bucket string, key []byte, bit int, start, end int) (int64, error) {
+	value, _, err := tx.currentValue(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	return bitmap.BitPos(value, bit, start, end)
+}
+
+// BitOp performs the bit operation between the values of the source keys in the bucket
+// and stores the result to the destination key, it returns the length of the result
+// A missing key is treated as an empty value
+func (tx *Tx) BitOp(op bitmap.Op, bucket string, destKey []byte, srcKeys ...[]byte) (int, error) {
+	values := make([][]byte, len(srcKeys))
+	for i, srcKey := range srcKeys {
+		value, _, err := tx.currentValue(bucket, srcKey)
+		if err != nil {
+			return 0, err
+		}
+
+		values[i] = value
+	}
+
+	result, err := bitmap.BitOp(op, values...)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(result), tx.Put(bucket, destKey, result, Persistent)
+}
@@ -0,0 +1,127 @@
+package nutsdb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/HelloChenHZ/nutsdb/ds/bitmap"
+)
+
+func TestTx_SetBit(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			return tx.Put("bucket", []byte("b"), []byte{0x0f}, Persistent)
+		})
+
+		update(t, db, func(tx *Tx) error {
+			if orig, err := tx.SetBit("bucket", []byte("b"), 0, 1); err != nil || orig != 0 {
+				t.Errorf("SetBit(0) = %d, %v, want 0", orig, err)
+			}
+
+			// the pending patch is seen by the tx
+			if orig, err := tx.SetBit("bucket", []byte("b"), 0, 0); err != nil || orig != 1 {
+				t.Errorf("SetBit(0) again = %d, %v, want 1", orig, err)
+			}
+
+			if orig, err := tx.SetBit("bucket", []byte("b"), 15, 1); err != nil || orig != 0 {
+				t.Errorf("SetBit(15) = %d, %v, want 0", orig, err)
+			}
+
+			if bit, _ := tx.GetBit("bucket", []byte("b"), 15); bit != 1 {
+				t.Errorf("GetBit(15) = %d, want 1", bit)
+			}
+
+			if _, err := tx.SetBit("bucket", []byte("b"), 0, 2); err != bitmap.ErrBitValue {
+				t.Errorf("SetBit of 2 returned %v, want ErrBitValue", err)
+			}
+
+			return nil
+		})
+
+		update(t, db, func(tx *Tx) error {
+			_, err := tx.SetBit("bucket", []byte("new"), 9, 1)
+			return err
+		})
+
+		check := func(db *DB) {
+			if value := getString(t, db, "bucket", "b"); value != string([]byte{0x0f, 0x01}) {
+				t.Errorf("b = %x, want 0f01", value)
+			}
+
+			if value := getString(t, db, "bucket", "new"); value != string([]byte{0x00, 0x40}) {
+				t.Errorf("new = %x, want 0040", value)
+			}
+		}
+
+		check(db)
+		db = reopenDB(t, db)
+		check(db)
+	})
+}
+
+func TestTx_SetBitSnapshot(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		_, err := tx.SetBit("bucket", []byte("b"), 0, 1)
+		return err
+	})
+
+	// the value patched before is modified in place by the next patches unless a snapshot reads it
+	reader, err := db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = reader.Rollback()
+	}()
+
+	update(t, db, func(tx *Tx) error {
+		_, err := tx.SetBit("bucket", []byte("b"), 1, 1)
+		return err
+	})
+
+	if bit, _ := reader.GetBit("bucket", []byte("b"), 1); bit != 0 {
+		t.Error("the patch committed after the snapshot is seen by the snapshot")
+	}
+
+	if value := getString(t, db, "bucket", "b"); value != string([]byte{0xc0}) {
+		t.Errorf("b = %x, want c0", value)
+	}
+}
+
+func TestTx_BitCommands(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.Put("bucket", []byte("a"), []byte{0xf0, 0x0f}, Persistent); err != nil {
+			return err
+		}
+
+		return tx.Put("bucket", []byte("b"), []byte{0xff}, Persistent)
+	})
+
+	update(t, db, func(tx *Tx) error {
+		if n, _ := tx.BitCount("bucket", []byte("a"), 0, -1); n != 8 {
+			t.Errorf("BitCount = %d, want 8", n)
+		}
+
+		if pos, _ := tx.BitPos("bucket", []byte("a"), 0, 0, -1); pos != 4 {
+			t.Errorf("BitPos = %d, want 4", pos)
+		}
+
+		n, err := tx.BitOp(bitmap.OpXor, "bucket", []byte("dst"), []byte("a"), []byte("b"), []byte("missing"))
+		if err != nil || n != 2 {
+			t.Errorf("BitOp = %d, %v, want 2", n, err)
+		}
+
+		return err
+	})
+
+	if value := getString(t, db, "bucket", "dst"); !bytes.Equal([]byte(value), []byte{0x0f, 0x0f}) {
+		t.Errorf("dst = %x, want 0f0f", value)
+	}
+}
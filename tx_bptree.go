@@ -0,0 +1,200 @@
+package nutsdb
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/HelloChenHZ/nutsdb/ds/bitmap"
+	"github.com/HelloChenHZ/nutsdb/ds/hash"
+)
+
+var (
+	// ErrNotInteger is returned when the value is not an integer or out of the int64 range, by the counters and HIncrBy
+	ErrNotInteger = hash.ErrNotInteger
+
+	// ErrNotFloat is returned when the value is not a valid float
+	ErrNotFloat = errors.New("value is not a valid float")
+
+	// ErrIncrOverflow is returned when the increment or decrement would overflow, by the counters and HIncrBy
+	ErrIncrOverflow = hash.ErrIncrOverflow
+)
+
+// Put sets the value for a key in the bucket
+// a wrapper of the function put
+func (tx *Tx) Put(bucket string, key, value []byte, ttl uint32) error {
+	return tx.put(bucket, key, value, ttl, DataSetFlag, uint64(time.Now().Unix()), DataStrucctureBPTree)
+}
+
+// Get retrieves the value for a key in the bucket
+// The returned value is only valid for the life of the transaction
+func (tx *Tx) Get(bucket string, key []byte) (e *Entry, err error) {
+	r, err := tx.getRecord(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.E != nil {
+		return r.E, nil
+	}
+
+	return tx.db.readEntry(r.H)
+}
+
+// Delete removes a key from the bucket at given bucket and key
+func (tx *Tx) Delete(bucket string, key []byte) error {
+	return tx.put(bucket, key, nil, Persistent, DataDeleteFlag, uint64(time.Now().Unix()), DataStrucctureBPTree)
+}
+
+// Incr increments the integer value of a key in the bucket by one
+func (tx *Tx) Incr(bucket string, key []byte) (int64, error) {
+	return tx.IncrBy(bucket, key, 1)
+}
+
+// Decr decrements the integer value of a key in the bucket by one
+func (tx *Tx) Decr(bucket string, key []byte) (int64, error) {
+	return tx.IncrBy(bucket, key, -1)
+}
+
+// IncrBy increments the integer value of a key in the bucket by delta
+// If the key does not exist, it is set to 0 before performing the operation
+// An error is returned if the value is not an integer or the result would overflow
+// The result is logged as the new value of the key, so the recovery reproduces the exact result
+func (tx *Tx) IncrBy(bucket string, key []byte, delta int64) (int64, error) {
+	value, ttl, err := tx.getCounter(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := hash.IncrBy(value, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Put(bucket, key, []byte(strconv.FormatInt(n, 10)), ttl)
+}
+
+// IncrByFloat increments the float value of a key in the bucket by delta
+// If the key does not exist, it is set to 0 before performing the operation
+// An error is returned if the value is not a valid float or the result is NaN or Infinity
+func (tx *Tx) IncrByFloat(bucket string, key []byte, delta float64) (float64, error) {
+	value, ttl, err := tx.getCounter(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var f float64
+	if value != nil {
+		if f, err = strconv.ParseFloat(string(value), 64); err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, ErrNotFloat
+		}
+	}
+
+	f += delta
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrIncrOverflow
+	}
+
+	return f, tx.Put(bucket, key, []byte(strconv.FormatFloat(f, 'g', -1, 64)), ttl)
+}
+
+// getCounter returns the value of a key in the bucket and its remaining ttl,
+// the pending writes of the tx are taken into account, nil is returned if the key not exists
+func (tx *Tx) getCounter(bucket string, key []byte) (value []byte, ttl uint32, err error) {
+	value, meta, err := tx.currentValue(bucket, key)
+	if err != nil || meta == nil {
+		return nil, Persistent, err
+	}
+
+	return value, remainingTTL(meta), nil
+}
+
+// currentValue returns the value of a key in the bucket and its meta as seen inside the tx,
+// the pending writes of the tx are applied on the committed value
+// A nil meta is returned if the key not exists
+func (tx *Tx) currentValue(bucket string, key []byte) (value []byte, meta *MetaData, err error) {
+	if err = tx.checkTxIsClosed(); err != nil {
+		return nil, nil, err
+	}
+
+	e, err := tx.Get(bucket, key)
+	if err != nil && err != ErrNotFoundKey && err != ErrBucket {
+		return nil, nil, err
+	}
+
+	if err == nil {
+		value, meta = e.Value, e.Meta
+	}
+
+	copied := false
+	for _, pe := range tx.pendingWrites {
+		if pe.Meta.ds != DataStrucctureBPTree || string(pe.Meta.bucket) != bucket || string(pe.Key) != string(key) {
+			continue
+		}
+
+		switch pe.Meta.Flag {
+		case DataSetFlag:
+			value, meta, copied = pe.Value, pe.Meta, false
+		case DataDeleteFlag:
+			value, meta, copied = nil, nil, false
+		case DataSetBitFlag:
+			offset, bit, err := bitmap.DecodePatch(pe.Value)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// never modify the committed value or the value given by the caller
+			if !copied {
+				value, copied = append([]byte(nil), value...), true
+			}
+
+			if value, _, err = bitmap.SetBit(value, offset, bit); err != nil {
+				return nil, nil, err
+			}
+
+			meta = pe.Meta
+		}
+	}
+
+	return value, meta, nil
+}
+
+// getRecord returns the valid record at given bucket and key as of the snapshot of the tx
+func (tx *Tx) getRecord(bucket string, key []byte) (*Record, error) {
+	if err := tx.checkTxIsClosed(); err != nil {
+		return nil, err
+	}
+
+	tx.trackKey(DataStrucctureBPTree, bucket, key)
+
+	idx, ok := tx.snap.BPTreeIdx[bucket]
+	if !ok {
+		return nil, ErrBucket
+	}
+
+	r, err := tx.findRecord(idx, key)
+	if err != nil {
+		return nil, ErrNotFoundKey
+	}
+
+	if r.H.meta.Flag == DataDeleteFlag || r.IsExpired() {
+		return nil, ErrNotFoundKey
+	}
+
+	return r, nil
+}
+
+// remainingTTL returns the ttl left at given meta, so that rewriting a key does not extend its life
+func remainingTTL(meta *MetaData) uint32 {
+	if meta.TTL == Persistent {
+		return Persistent
+	}
+
+	elapsed := uint64(time.Now().Unix()) - meta.timestamp
+	if elapsed >= uint64(meta.TTL) {
+		return 1
+	}
+
+	return meta.TTL - uint32(elapsed)
+}
@@ -0,0 +1,179 @@
+package nutsdb
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// getString returns the value of the key in the bucket, or "" if the key not exists
+func getString(t *testing.T, db *DB, bucket, key string) (value string) {
+	t.Helper()
+
+	view(t, db, func(tx *Tx) error {
+		e, err := tx.Get(bucket, []byte(key))
+		if err == ErrNotFoundKey {
+			return nil
+		}
+
+		if err == nil {
+			value = string(e.Value)
+		}
+
+		return err
+	})
+
+	return
+}
+
+func TestTx_PutGetDelete(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.Put("bucket", []byte("a"), []byte("1"), Persistent); err != nil {
+				return err
+			}
+
+			return tx.Put("bucket", []byte("b"), []byte("2"), Persistent)
+		})
+
+		update(t, db, func(tx *Tx) error {
+			return tx.Delete("bucket", []byte("b"))
+		})
+
+		db = reopenDB(t, db)
+
+		if value := getString(t, db, "bucket", "a"); value != "1" {
+			t.Errorf("Get(a) = %q, want 1", value)
+		}
+
+		view(t, db, func(tx *Tx) error {
+			if _, err := tx.Get("bucket", []byte("b")); err != ErrNotFoundKey {
+				t.Errorf("Get of a deleted key returned %v, want ErrNotFoundKey", err)
+			}
+
+			if _, err := tx.Get("missing", []byte("a")); err != ErrBucket {
+				t.Errorf("Get of a missing bucket returned %v, want ErrBucket", err)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestTx_Counters(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			if n, err := tx.Incr("bucket", []byte("n")); err != nil || n != 1 {
+				t.Errorf("Incr of a missing key = %d, %v, want 1", n, err)
+			}
+
+			// the pending writes are taken into account
+			if n, err := tx.IncrBy("bucket", []byte("n"), 10); err != nil || n != 11 {
+				t.Errorf("IncrBy = %d, %v, want 11", n, err)
+			}
+
+			if n, err := tx.Decr("bucket", []byte("n")); err != nil || n != 10 {
+				t.Errorf("Decr = %d, %v, want 10", n, err)
+			}
+
+			if f, err := tx.IncrByFloat("bucket", []byte("f"), 1.5); err != nil || f != 1.5 {
+				t.Errorf("IncrByFloat of a missing key = %v, %v, want 1.5", f, err)
+			}
+
+			if f, err := tx.IncrByFloat("bucket", []byte("f"), -0.25); err != nil || f != 1.25 {
+				t.Errorf("IncrByFloat = %v, %v, want 1.25", f, err)
+			}
+
+			return nil
+		})
+
+		update(t, db, func(tx *Tx) error {
+			_, err := tx.IncrBy("bucket", []byte("n"), -20)
+			return err
+		})
+
+		db = reopenDB(t, db)
+
+		if value := getString(t, db, "bucket", "n"); value != "-10" {
+			t.Errorf("n after reopen = %q, want -10", value)
+		}
+
+		if value := getString(t, db, "bucket", "f"); value != "1.25" {
+			t.Errorf("f after reopen = %q, want 1.25", value)
+		}
+	})
+}
+
+func TestTx_CounterErrors(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.Put("bucket", []byte("s"), []byte("abc"), Persistent); err != nil {
+			return err
+		}
+
+		if err := tx.Put("bucket", []byte("max"), []byte(strconv.FormatInt(math.MaxInt64, 10)), Persistent); err != nil {
+			return err
+		}
+
+		return tx.Put("bucket", []byte("big"), []byte(strconv.FormatFloat(math.MaxFloat64, 'g', -1, 64)), Persistent)
+	})
+
+	update(t, db, func(tx *Tx) error {
+		if _, err := tx.Incr("bucket", []byte("s")); err != ErrNotInteger {
+			t.Errorf("Incr of a string returned %v, want ErrNotInteger", err)
+		}
+
+		if _, err := tx.IncrByFloat("bucket", []byte("s"), 1); err != ErrNotFloat {
+			t.Errorf("IncrByFloat of a string returned %v, want ErrNotFloat", err)
+		}
+
+		if _, err := tx.Incr("bucket", []byte("max")); err != ErrIncrOverflow {
+			t.Errorf("Incr overflow returned %v, want ErrIncrOverflow", err)
+		}
+
+		if _, err := tx.IncrByFloat("bucket", []byte("big"), math.MaxFloat64); err != ErrIncrOverflow {
+			t.Errorf("IncrByFloat to infinity returned %v, want ErrIncrOverflow", err)
+		}
+
+		if _, err := tx.IncrByFloat("bucket", []byte("f"), math.NaN()); err != ErrIncrOverflow {
+			t.Errorf("IncrByFloat by NaN returned %v, want ErrIncrOverflow", err)
+		}
+
+		return nil
+	})
+
+	if value := getString(t, db, "bucket", "max"); value != strconv.FormatInt(math.MaxInt64, 10) {
+		t.Errorf("the failed Incr changed the value to %q", value)
+	}
+}
+
+func TestTx_CounterKeepsTTL(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		return tx.Put("bucket", []byte("n"), []byte("1"), 3600)
+	})
+
+	update(t, db, func(tx *Tx) error {
+		_, err := tx.Incr("bucket", []byte("n"))
+		return err
+	})
+
+	view(t, db, func(tx *Tx) error {
+		e, err := tx.Get("bucket", []byte("n"))
+		if err != nil {
+			return err
+		}
+
+		if string(e.Value) != "2" || e.Meta.TTL == Persistent || e.Meta.TTL > 3600 {
+			t.Errorf("Incr wrote %s with the ttl %d, want 2 with the remaining ttl", e.Value, e.Meta.TTL)
+		}
+
+		return nil
+	})
+}
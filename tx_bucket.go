@@ -0,0 +1,323 @@
+package nutsdb
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrBucketPath is returned when the path of the nested bucket is empty or has an empty or invalid name
+var ErrBucketPath = errors.New("err bucket path")
+
+// BucketStats represents the statistics of a bucket
+type BucketStats struct {
+	KeyCount	int		// the number of the keys, the expired keys of the BPTree are counted until deleted
+	Bytes		int64	// the approximate size of the keys and values
+}
+
+// Buckets returns the sorted names of the buckets of the data structure
+func (tx *Tx) Buckets(ds uint16) ([]string, error) {
+	if err := tx.checkTxIsClosed(); err != nil {
+		return nil, err
+	}
+
+	return tx.snap.bucketNames(ds)
+}
+
+// CreateBucket creates the empty bucket of the data structure, so that it is listed by Buckets
+// The buckets are also created implicitly by the first write
+func (tx *Tx) CreateBucket(ds uint16, bucket string) error {
+	exists, err := tx.bucketExists(ds, bucket)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrBucketExists
+	}
+
+	return tx.put(bucket, []byte(bucket), nil, Persistent, DataCreateBucketFlag, uint64(time.Now().Unix()), ds)
+}
+
+// DeleteBucket removes the bucket of the data structure with all its keys
+// The whole bucket is tombstoned by a single record, whatever the number of its keys
+func (tx *Tx) DeleteBucket(ds uint16, bucket string) error {
+	exists, err := tx.bucketExists(ds, bucket)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrBucket
+	}
+
+	if err := tx.put(bucket, []byte(bucket), nil, Persistent, DataDeleteBucketFlag, uint64(time.Now().Unix()), ds); err != nil {
+		return err
+	}
+
+	if ds == DataStructureHLL {
+		delete(tx.sketches, bucket)
+	}
+
+	return nil
+}
+
+// BucketStats returns the statistics of the bucket of the data structure
+// The statistics of a BPTree are kept by the tree, so they are read in constant time but reflect the last commit
+func (tx *Tx) BucketStats(ds uint16, bucket string) (*BucketStats, error) {
+	if err := tx.checkTxIsClosed(); err != nil {
+		return nil, err
+	}
+
+	if !isDataStructure(ds) {
+		return nil, ErrDataStructure
+	}
+
+	tx.trackBucket(ds, bucket)
+
+	if !tx.snap.hasBucket(ds, bucket) {
+		return nil, ErrBucket
+	}
+
+	stats := &BucketStats{}
+
+	switch ds {
+	case DataStrucctureBPTree:
+		t := tx.snap.BPTreeIdx[bucket]
+
+		tx.db.mu.RLock()
+		stats.KeyCount, stats.Bytes = t.ValidKeyCount, t.ValidSize()
+		tx.db.mu.RUnlock()
+	case DataStructureSet:
+		s := tx.snap.SetIdx[bucket]
+		stats.KeyCount = len(s.M)
+		for key, members := range s.M {
+			stats.Bytes += int64(len(key))
+			for member := range members {
+				stats.Bytes += int64(len(member))
+			}
+		}
+	case DataStructureSortedSet:
+		ss := tx.snap.SortedSetIdx[bucket]
+		stats.KeyCount = ss.Size()
+		for _, node := range ss.GetByRankRange(1, -1, false) {
+			stats.Bytes += int64(len(node.Key()) + len(node.Value) + 8)
+		}
+	case DataStructureList:
+		l := tx.snap.ListIdx[bucket]
+		stats.KeyCount = len(l.Items)
+		for key, items := range l.Items {
+			stats.Bytes += int64(len(key))
+			for _, item := range items {
+				stats.Bytes += int64(len(item))
+			}
+		}
+	case DataStructureHash:
+		h := tx.snap.HashIdx[bucket]
+		stats.KeyCount = len(h.M)
+		for key, fields := range h.M {
+			stats.Bytes += int64(len(key))
+			for field, value := range fields {
+				stats.Bytes += int64(len(field) + len(value))
+			}
+		}
+	case DataStructureHLL:
+		h := tx.snap.HLLIdx[bucket]
+		stats.KeyCount = len(h.M)
+		for key, s := range h.M {
+			stats.Bytes += int64(len(key) + s.Size())
+		}
+	case DataStructureStream:
+		s := tx.snap.StreamIdx[bucket]
+		stats.KeyCount = len(s.M)
+		for key, l := range s.M {
+			stats.Bytes += int64(len(key))
+			for _, entry := range l.Entries {
+				stats.Bytes += int64(8 + len(entry.Value))
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// bucketExists returns if the bucket of the data structure exists as seen inside the tx, the bucket is read as a whole
+func (tx *Tx) bucketExists(ds uint16, bucket string) (bool, error) {
+	if err := tx.checkTxIsClosed(); err != nil {
+		return false, err
+	}
+
+	if !isDataStructure(ds) {
+		return false, ErrDataStructure
+	}
+
+	tx.trackBucket(ds, bucket)
+
+	return tx.hasBucket(ds, bucket), nil
+}
+
+// keyBucketExists returns if the bucket of the data structure exists as seen inside the tx, only the key is read,
+// since the read of a key already conflicts with the creation and the deletion of its bucket
+func (tx *Tx) keyBucketExists(ds uint16, bucket string, key []byte) (bool, error) {
+	if err := tx.checkTxIsClosed(); err != nil {
+		return false, err
+	}
+
+	tx.trackKey(ds, bucket, key)
+
+	return tx.hasBucket(ds, bucket), nil
+}
+
+// hasBucket returns if the bucket of the data structure exists as seen inside the tx
+func (tx *Tx) hasBucket(ds uint16, bucket string) bool {
+	exists := tx.snap.hasBucket(ds, bucket)
+	for _, e := range tx.pendingWrites {
+		if e.Meta.ds == ds && string(e.Meta.bucket) == bucket {
+			exists = e.Meta.Flag != DataDeleteBucketFlag
+		}
+	}
+
+	return exists
+}
+
+// Bucket represents a handle of a nested bucket, the path of the bucket is stored
+// in the bucket field of the entries joined and prefixed by SeparatorForBucketPath, so the nested buckets
+// are regular buckets and every method of the tx can be used with the Name of the handle
+type Bucket struct {
+	tx		*Tx
+	path	[]string
+}
+
+// Bucket returns the handle of the nested bucket at given path, like tenant, app, table
+// The bucket is created by the first write, or by CreateBucket with the Name of the handle
+func (tx *Tx) Bucket(path ...string) (*Bucket, error) {
+	if err := tx.checkTxIsClosed(); err != nil {
+		return nil, err
+	}
+
+	if len(path) == 0 {
+		return nil, ErrBucketPath
+	}
+
+	for _, name := range path {
+		if name == "" || strings.Contains(name, SeparatorForBucketPath) {
+			return nil, ErrBucketPath
+		}
+	}
+
+	return &Bucket{tx: tx, path: append([]string(nil), path...)}, nil
+}
+
+// Bucket returns the handle of the child bucket at given path relative to the bucket
+func (b *Bucket) Bucket(path ...string) (*Bucket, error) {
+	if len(path) == 0 {
+		return nil, ErrBucketPath
+	}
+
+	return b.tx.Bucket(append(append([]string(nil), b.path...), path...)...)
+}
+
+// Name returns the name of the bucket as stored in the bucket field of the entries
+func (b *Bucket) Name() string {
+	return SeparatorForBucketPath + strings.Join(b.path, SeparatorForBucketPath)
+}
+
+// Path returns the path of the bucket
+func (b *Bucket) Path() []string {
+	return append([]string(nil), b.path...)
+}
+
+// Put sets the value for a key in the bucket
+func (b *Bucket) Put(key, value []byte, ttl uint32) error {
+	return b.tx.Put(b.Name(), key, value, ttl)
+}
+
+// Get retrieves the value for a key in the bucket
+func (b *Bucket) Get(key []byte) (*Entry, error) {
+	return b.tx.Get(b.Name(), key)
+}
+
+// Delete removes a key from the bucket
+func (b *Bucket) Delete(key []byte) error {
+	return b.tx.Delete(b.Name(), key)
+}
+
+// Children returns the handles of the direct child buckets of the data structure sorted by name
+// A child is listed as soon as one of its descendants exists, even if it has no keys itself
+func (b *Bucket) Children(ds uint16) ([]*Bucket, error) {
+	names, err := b.tx.Buckets(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := b.Name() + SeparatorForBucketPath
+
+	var childNames []string
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		child := strings.SplitN(name[len(prefix):], SeparatorForBucketPath, 2)[0]
+		if _, ok := seen[child]; !ok {
+			seen[child] = struct{}{}
+			childNames = append(childNames, child)
+		}
+	}
+
+	sort.Strings(childNames)
+
+	children := make([]*Bucket, len(childNames))
+	for i, child := range childNames {
+		children[i] = &Bucket{tx: b.tx, path: append(b.Path(), child)}
+	}
+
+	return children, nil
+}
+
+// DeleteRecursive removes the bucket of the data structure with all its descendants,
+// every bucket is tombstoned by a single record
+func (b *Bucket) DeleteRecursive(ds uint16) error {
+	names, err := b.tx.Buckets(ds)
+	if err != nil {
+		return err
+	}
+
+	name := b.Name()
+	prefix := name + SeparatorForBucketPath
+
+	// the buckets created by the tx are not in the index yet
+	seen := make(map[string]struct{})
+	for _, e := range b.tx.pendingWrites {
+		bucket := string(e.Meta.bucket)
+		if _, ok := seen[bucket]; !ok && e.Meta.ds == ds {
+			seen[bucket] = struct{}{}
+			names = append(names, bucket)
+		}
+	}
+
+	deleted := 0
+	for _, bucket := range names {
+		if bucket != name && !strings.HasPrefix(bucket, prefix) {
+			continue
+		}
+
+		if exists, _ := b.tx.bucketExists(ds, bucket); !exists {
+			continue
+		}
+
+		if err := b.tx.DeleteBucket(ds, bucket); err != nil {
+			return err
+		}
+
+		deleted++
+	}
+
+	if deleted == 0 {
+		return ErrBucket
+	}
+
+	return nil
+}
@@ -0,0 +1,315 @@
+package nutsdb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTx_CreateBucket(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.CreateBucket(DataStrucctureBPTree, "empty"); err != nil {
+				return err
+			}
+
+			if err := tx.CreateBucket(DataStrucctureBPTree, "empty"); err != ErrBucketExists {
+				t.Errorf("CreateBucket of a pending bucket returned %v, want ErrBucketExists", err)
+			}
+
+			if err := tx.CreateBucket(100, "empty"); err != ErrDataStructure {
+				t.Errorf("CreateBucket of an unknown data structure returned %v, want ErrDataStructure", err)
+			}
+
+			if err := tx.CreateBucket(DataStructureSet, "set"); err != nil {
+				return err
+			}
+
+			if err := tx.Put("a", []byte("k"), []byte("v"), Persistent); err != nil {
+				return err
+			}
+
+			return tx.Put("b", []byte("k"), []byte("v"), Persistent)
+		})
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.DeleteBucket(DataStrucctureBPTree, "b"); err != nil {
+				return err
+			}
+
+			if err := tx.DeleteBucket(DataStrucctureBPTree, "b"); err != ErrBucket {
+				t.Errorf("DeleteBucket of a pending deletion returned %v, want ErrBucket", err)
+			}
+
+			return nil
+		})
+
+		check := func(db *DB) {
+			view(t, db, func(tx *Tx) error {
+				names, err := tx.Buckets(DataStrucctureBPTree)
+				if err != nil || !reflect.DeepEqual(names, []string{"a", "empty"}) {
+					t.Errorf("Buckets = %v, %v, want [a empty]", names, err)
+				}
+
+				if names, _ = tx.Buckets(DataStructureSet); !reflect.DeepEqual(names, []string{"set"}) {
+					t.Errorf("Buckets of the sets = %v, want [set]", names)
+				}
+
+				if _, err = tx.Get("b", []byte("k")); err != ErrBucket {
+					t.Errorf("Get in a deleted bucket returned %v, want ErrBucket", err)
+				}
+
+				return nil
+			})
+		}
+
+		check(db)
+		db = reopenDB(t, db)
+		check(db)
+
+		// a deleted bucket starts empty when it is written again
+		update(t, db, func(tx *Tx) error {
+			return tx.Put("b", []byte("other"), []byte("v"), Persistent)
+		})
+
+		db = reopenDB(t, db)
+
+		view(t, db, func(tx *Tx) error {
+			if _, err := tx.Get("b", []byte("k")); err != ErrNotFoundKey {
+				t.Errorf("Get of a key of the deleted bucket returned %v, want ErrNotFoundKey", err)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestTx_BucketStats(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			for _, key := range []string{"a", "b", "c"} {
+				if err := tx.Put("bucket", []byte(key), []byte("value"), Persistent); err != nil {
+					return err
+				}
+			}
+
+			// an expired key is counted until it is deleted
+			if err := tx.Put("bucket", []byte("d"), []byte("value"), 1); err != nil {
+				return err
+			}
+
+			return tx.SAdd("set", []byte("s"), []byte("x"), []byte("yy"))
+		})
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.Delete("bucket", []byte("c")); err != nil {
+				return err
+			}
+
+			return tx.Put("bucket", []byte("a"), []byte("longer value"), Persistent)
+		})
+
+		want := &BucketStats{KeyCount: 3, Bytes: int64(3*(DataEntryHeaderSize+len("bucket")+1) + len("longer value") + 2*len("value"))}
+
+		check := func(db *DB) {
+			view(t, db, func(tx *Tx) error {
+				stats, err := tx.BucketStats(DataStrucctureBPTree, "bucket")
+				if err != nil || !reflect.DeepEqual(stats, want) {
+					t.Errorf("BucketStats = %+v, %v, want %+v", stats, err, want)
+				}
+
+				if stats, _ = tx.BucketStats(DataStructureSet, "set"); stats.KeyCount != 1 || stats.Bytes != 4 {
+					t.Errorf("BucketStats of the set = %+v, want 1 key and 4 bytes", stats)
+				}
+
+				if _, err = tx.BucketStats(DataStrucctureBPTree, "missing"); err != ErrBucket {
+					t.Errorf("BucketStats of a missing bucket returned %v, want ErrBucket", err)
+				}
+
+				return nil
+			})
+		}
+
+		check(db)
+		db = reopenDB(t, db)
+		check(db)
+	})
+}
+
+func TestTx_BucketStatsSetBit(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.Put("bucket", []byte("a"), []byte("v"), Persistent); err != nil {
+				return err
+			}
+
+			_, err := tx.SetBit("bucket", []byte("b"), 799, 1)
+			return err
+		})
+
+		update(t, db, func(tx *Tx) error {
+			_, err := tx.SetBit("bucket", []byte("a"), 15, 1)
+			return err
+		})
+
+		// the sizes of the patched values, not of the patches
+		want := &BucketStats{KeyCount: 2, Bytes: int64(2*(DataEntryHeaderSize+len("bucket")+1) + 2 + 100)}
+
+		check := func(db *DB) {
+			view(t, db, func(tx *Tx) error {
+				if stats, err := tx.BucketStats(DataStrucctureBPTree, "bucket"); err != nil || !reflect.DeepEqual(stats, want) {
+					t.Errorf("BucketStats = %+v, %v, want %+v", stats, err, want)
+				}
+
+				return nil
+			})
+		}
+
+		check(db)
+		db = reopenDB(t, db)
+		check(db)
+	})
+}
+
+// childNames returns the paths joined by "/" of the children of the bucket at given path
+func childNames(t *testing.T, tx *Tx, ds uint16, path ...string) []string {
+	t.Helper()
+
+	b, err := tx.Bucket(path...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	children, err := b.Children(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, child := range children {
+		names = append(names, strings.Join(child.Path(), "/"))
+	}
+
+	return names
+}
+
+func TestTx_Bucket(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			for _, path := range [][]string{{}, {"a", ""}, {"a" + SeparatorForBucketPath + "b"}} {
+				if _, err := tx.Bucket(path...); err != ErrBucketPath {
+					t.Errorf("Bucket(%q) returned %v, want ErrBucketPath", path, err)
+				}
+			}
+
+			tenant, err := tx.Bucket("tenant")
+			if err != nil {
+				return err
+			}
+
+			users, err := tenant.Bucket("app", "users")
+			if err != nil {
+				return err
+			}
+
+			if users.Name() != "\x00tenant\x00app\x00users" || !reflect.DeepEqual(users.Path(), []string{"tenant", "app", "users"}) {
+				t.Errorf("Name = %q, Path = %q", users.Name(), users.Path())
+			}
+
+			if err = users.Put([]byte("alice"), []byte("1"), Persistent); err != nil {
+				return err
+			}
+
+			// every method of the tx can be used with the name of a nested bucket
+			orders, _ := tx.Bucket("tenant", "app", "orders")
+			if err = tx.Put(orders.Name(), []byte("o1"), []byte("1"), Persistent); err != nil {
+				return err
+			}
+
+			jobs, _ := tx.Bucket("tenant", "jobs")
+			if err = jobs.Put([]byte("j1"), []byte("1"), Persistent); err != nil {
+				return err
+			}
+
+			// the buckets named by the callers never collide with the nested buckets
+			for _, name := range []string{"tenant", "tenant/app", "tenant/app/users", "tenants"} {
+				if err = tx.Put(name, []byte("k"), []byte("1"), Persistent); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		check := func(db *DB) {
+			view(t, db, func(tx *Tx) error {
+				if names := childNames(t, tx, DataStrucctureBPTree, "tenant"); !reflect.DeepEqual(names, []string{"tenant/app", "tenant/jobs"}) {
+					t.Errorf("Children of tenant = %v", names)
+				}
+
+				if names := childNames(t, tx, DataStrucctureBPTree, "tenant", "app"); !reflect.DeepEqual(names, []string{"tenant/app/orders", "tenant/app/users"}) {
+					t.Errorf("Children of tenant/app = %v", names)
+				}
+
+				users, _ := tx.Bucket("tenant", "app", "users")
+				if e, err := users.Get([]byte("alice")); err != nil || string(e.Value) != "1" {
+					t.Errorf("Get(alice) = %v, %v", e, err)
+				}
+
+				if _, err := users.Get([]byte("k")); err == nil {
+					t.Error("Get of the key of the bucket named tenant/app/users returned no error")
+				}
+
+				if _, err := tx.Get("tenant/app/users", []byte("alice")); err == nil {
+					t.Error("Get of the key of the nested bucket from the bucket named tenant/app/users returned no error")
+				}
+
+				return nil
+			})
+		}
+
+		check(db)
+		db = reopenDB(t, db)
+		check(db)
+
+		update(t, db, func(tx *Tx) error {
+			app, _ := tx.Bucket("tenant", "app")
+
+			// the buckets created by the tx are deleted too
+			archive, _ := app.Bucket("users", "archive")
+			if err := archive.Put([]byte("k"), []byte("1"), Persistent); err != nil {
+				return err
+			}
+
+			if err := app.DeleteRecursive(DataStrucctureBPTree); err != nil {
+				return err
+			}
+
+			if err := app.DeleteRecursive(DataStrucctureBPTree); err != ErrBucket {
+				t.Errorf("DeleteRecursive of a deleted bucket returned %v, want ErrBucket", err)
+			}
+
+			return nil
+		})
+
+		db = reopenDB(t, db)
+
+		view(t, db, func(tx *Tx) error {
+			jobs, _ := tx.Bucket("tenant", "jobs")
+			names, _ := tx.Buckets(DataStrucctureBPTree)
+			if want := []string{jobs.Name(), "tenant", "tenant/app", "tenant/app/users", "tenants"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("Buckets after DeleteRecursive = %q, want %q", names, want)
+			}
+
+			return nil
+		})
+	})
+}
@@ -0,0 +1,142 @@
+package nutsdb
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	// ErrKeyExists is returned by PutIfAbsent when the key already exists in the bucket
+	ErrKeyExists = errors.New("key already exists in the bucket")
+
+	// ErrValueMismatch is returned by a conditional write when the value of the key is not the expected value
+	ErrValueMismatch = errors.New("value does not match the expected value")
+
+	// ErrVersionMismatch is returned by PutIfVersion when the version of the key is not the expected version
+	ErrVersionMismatch = errors.New("version does not match the current version")
+)
+
+// The conditional writes compare the value of the key as seen inside the tx, the pending writes included
+// The key is read by the tx, so the tx fails to commit with ErrConflict if another tx writes the key
+// before it commits, that is the comparison still holds when the tx is committed
+
+// PutIfAbsent sets the value for a key in the bucket if the key not exists, otherwise ErrKeyExists is returned
+func (tx *Tx) PutIfAbsent(bucket string, key, value []byte, ttl uint32) error {
+	_, meta, err := tx.currentValue(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	if meta != nil {
+		return ErrKeyExists
+	}
+
+	return tx.Put(bucket, key, value, ttl)
+}
+
+// CompareAndSwap sets the value for a key in the bucket to value if its current value is expected,
+// the remaining ttl of the key is kept
+// ErrNotFoundKey is returned if the key not exists, and ErrValueMismatch if its value is not expected
+func (tx *Tx) CompareAndSwap(bucket string, key, expected, value []byte) error {
+	meta, err := tx.compareValue(bucket, key, expected)
+	if err != nil {
+		return err
+	}
+
+	return tx.Put(bucket, key, value, remainingTTL(meta))
+}
+
+// DeleteIfEquals removes a key from the bucket if its current value is expected
+// ErrNotFoundKey is returned if the key not exists, and ErrValueMismatch if its value is not expected
+func (tx *Tx) DeleteIfEquals(bucket string, key, expected []byte) error {
+	if _, err := tx.compareValue(bucket, key, expected); err != nil {
+		return err
+	}
+
+	return tx.Delete(bucket, key)
+}
+
+// compareValue returns the meta of a key in the bucket if its current value is expected
+func (tx *Tx) compareValue(bucket string, key, expected []byte) (*MetaData, error) {
+	value, meta, err := tx.currentValue(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if meta == nil {
+		return nil, ErrNotFoundKey
+	}
+
+	if !bytes.Equal(value, expected) {
+		return nil, ErrValueMismatch
+	}
+
+	return meta, nil
+}
+
+// GetWithVersion retrieves the value for a key in the bucket and its version, as seen inside the tx
+// The version of a key increases with every write of the key, deletions included, and never goes back,
+// even across restarts or when the bucket is deleted and created again, so it can be used as an ETag, see PutIfVersion
+func (tx *Tx) GetWithVersion(bucket string, key []byte) (e *Entry, version uint64, err error) {
+	value, meta, version, err := tx.currentVersion(bucket, key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if meta == nil {
+		return nil, 0, ErrNotFoundKey
+	}
+
+	return &Entry{Key: key, Value: value, Meta: meta}, version, nil
+}
+
+// PutIfVersion sets the value for a key in the bucket if its current version is version, the remaining ttl of the key is kept
+// The version 0 stands for a key that not exists, ErrVersionMismatch is returned if the version is not the current one
+func (tx *Tx) PutIfVersion(bucket string, key, value []byte, version uint64) error {
+	_, meta, current, err := tx.currentVersion(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	ttl := Persistent
+	if meta == nil {
+		current = 0
+	} else {
+		ttl = remainingTTL(meta)
+	}
+
+	if current != version {
+		return ErrVersionMismatch
+	}
+
+	return tx.Put(bucket, key, value, ttl)
+}
+
+// currentVersion returns the value, the meta and the version of a key in the bucket as seen inside the tx,
+// every pending write of the key counts as a new version
+func (tx *Tx) currentVersion(bucket string, key []byte) (value []byte, meta *MetaData, version uint64, err error) {
+	if value, meta, err = tx.currentValue(bucket, key); err != nil {
+		return nil, nil, 0, err
+	}
+
+	// the version of a deleted or expired key is still counted, so that the version never goes back,
+	// and the versions of a new key start above the versions of the deleted buckets of the same name
+	if idx, ok := tx.snap.BPTreeIdx[bucket]; ok {
+		version = idx.baseKeyVersion
+		if r, err := tx.findRecord(idx, key); err == nil {
+			version = r.keyVersion
+		}
+	} else {
+		tx.db.mu.RLock()
+		version = tx.db.keyVersions[bucket]
+		tx.db.mu.RUnlock()
+	}
+
+	for _, pe := range tx.pendingWrites {
+		if pe.Meta.ds == DataStrucctureBPTree && string(pe.Meta.bucket) == bucket && string(pe.Key) == string(key) {
+			version++
+		}
+	}
+
+	return value, meta, version, nil
+}
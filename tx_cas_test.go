@@ -0,0 +1,308 @@
+package nutsdb
+
+import (
+	"testing"
+)
+
+func TestTx_PutIfAbsent(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.PutIfAbsent("bucket", []byte("a"), []byte("1"), Persistent); err != nil {
+			return err
+		}
+
+		// the pending write is seen by the tx
+		if err := tx.PutIfAbsent("bucket", []byte("a"), []byte("2"), Persistent); err != ErrKeyExists {
+			t.Errorf("PutIfAbsent of a pending key returned %v, want ErrKeyExists", err)
+		}
+
+		return tx.Put("bucket", []byte("deleted"), []byte("1"), Persistent)
+	})
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.PutIfAbsent("bucket", []byte("a"), []byte("2"), Persistent); err != ErrKeyExists {
+			t.Errorf("PutIfAbsent of a committed key returned %v, want ErrKeyExists", err)
+		}
+
+		if err := tx.Delete("bucket", []byte("deleted")); err != nil {
+			return err
+		}
+
+		return tx.PutIfAbsent("bucket", []byte("deleted"), []byte("2"), Persistent)
+	})
+
+	view(t, db, func(tx *Tx) error {
+		if got := getValue(tx, "bucket", "a") + getValue(tx, "bucket", "deleted"); got != "12" {
+			t.Errorf("values = %q, want 1 and 2", got)
+		}
+
+		return nil
+	})
+}
+
+func TestTx_CompareAndSwap(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			return tx.Put("bucket", []byte("a"), []byte("1"), 100)
+		})
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.CompareAndSwap("bucket", []byte("missing"), nil, []byte("1")); err != ErrNotFoundKey {
+				t.Errorf("CompareAndSwap of a missing key returned %v, want ErrNotFoundKey", err)
+			}
+
+			if err := tx.CompareAndSwap("bucket", []byte("a"), []byte("2"), []byte("3")); err != ErrValueMismatch {
+				t.Errorf("CompareAndSwap of another value returned %v, want ErrValueMismatch", err)
+			}
+
+			if err := tx.CompareAndSwap("bucket", []byte("a"), []byte("1"), []byte("2")); err != nil {
+				return err
+			}
+
+			// the swaps of the tx are chained
+			return tx.CompareAndSwap("bucket", []byte("a"), []byte("2"), []byte("3"))
+		})
+
+		db = reopenDB(t, db)
+
+		view(t, db, func(tx *Tx) error {
+			e, err := tx.Get("bucket", []byte("a"))
+			if err != nil || string(e.Value) != "3" {
+				t.Errorf("Get(a) = %v, %v, want 3", e, err)
+			}
+
+			// the ttl is kept rather than extended
+			if err == nil && (e.Meta.TTL == Persistent || e.Meta.TTL > 100) {
+				t.Errorf("the ttl after CompareAndSwap = %d, want at most 100", e.Meta.TTL)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestTx_DeleteIfEquals(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		return tx.Put("bucket", []byte("lock"), []byte("owner1"), Persistent)
+	})
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.DeleteIfEquals("bucket", []byte("lock"), []byte("owner2")); err != ErrValueMismatch {
+			t.Errorf("DeleteIfEquals of another value returned %v, want ErrValueMismatch", err)
+		}
+
+		if err := tx.DeleteIfEquals("bucket", []byte("missing"), []byte("owner1")); err != ErrNotFoundKey {
+			t.Errorf("DeleteIfEquals of a missing key returned %v, want ErrNotFoundKey", err)
+		}
+
+		if err := tx.DeleteIfEquals("bucket", []byte("lock"), []byte("owner1")); err != nil {
+			return err
+		}
+
+		if err := tx.DeleteIfEquals("bucket", []byte("lock"), []byte("owner1")); err != ErrNotFoundKey {
+			t.Errorf("DeleteIfEquals of a deleted key returned %v, want ErrNotFoundKey", err)
+		}
+
+		return nil
+	})
+}
+
+func TestTx_CompareAndSwapConflict(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		return tx.Put("bucket", []byte("lock"), []byte("free"), Persistent)
+	})
+
+	// two txs acquire the lock at the same time, only the first one to commit gets it
+	first, second := beginWritable(t, db), beginWritable(t, db)
+
+	if err := first.CompareAndSwap("bucket", []byte("lock"), []byte("free"), []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := second.CompareAndSwap("bucket", []byte("lock"), []byte("free"), []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := second.Commit(); err != ErrConflict {
+		t.Errorf("Commit of the second swap returned %v, want ErrConflict", err)
+	}
+
+	// the retry sees the new value
+	err := db.UpdateWithRetry(3, func(tx *Tx) error {
+		return tx.CompareAndSwap("bucket", []byte("lock"), []byte("free"), []byte("second"))
+	})
+	if err != ErrValueMismatch {
+		t.Errorf("CompareAndSwap after the first commit returned %v, want ErrValueMismatch", err)
+	}
+}
+
+// getVersion returns the version of the key, 0 if it not exists
+func getVersion(t *testing.T, db *DB, bucket, key string) (version uint64) {
+	t.Helper()
+
+	view(t, db, func(tx *Tx) (err error) {
+		if _, version, err = tx.GetWithVersion(bucket, []byte(key)); err == ErrNotFoundKey || err == ErrBucket {
+			err = nil
+		}
+
+		return
+	})
+
+	return
+}
+
+func TestTx_GetWithVersion(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		for i := 0; i < 2; i++ {
+			update(t, db, func(tx *Tx) error {
+				return tx.Put("bucket", []byte("a"), []byte{byte('0' + i)}, Persistent)
+			})
+		}
+
+		update(t, db, func(tx *Tx) error {
+			e, version, err := tx.GetWithVersion("bucket", []byte("a"))
+			if err != nil || version != 2 || string(e.Value) != "1" {
+				t.Errorf("GetWithVersion = %v, %d, %v, want 1 at version 2", e, version, err)
+			}
+
+			// every pending write is a new version
+			if err = tx.Put("bucket", []byte("a"), []byte("2"), Persistent); err != nil {
+				return err
+			}
+
+			if _, version, _ = tx.GetWithVersion("bucket", []byte("a")); version != 3 {
+				t.Errorf("GetWithVersion after a pending write = %d, want 3", version)
+			}
+
+			return tx.Delete("bucket", []byte("a"))
+		})
+
+		// the deletion is a version too, so a put after it never reuses an old version
+		update(t, db, func(tx *Tx) error {
+			if err := tx.PutIfVersion("bucket", []byte("a"), []byte("3"), 4); err != ErrVersionMismatch {
+				t.Errorf("PutIfVersion of a deleted key at its last version returned %v, want ErrVersionMismatch", err)
+			}
+
+			return tx.PutIfVersion("bucket", []byte("a"), []byte("3"), 0)
+		})
+
+		if v := getVersion(t, db, "bucket", "a"); v != 5 {
+			t.Errorf("version after the put of a deleted key = %d, want 5", v)
+		}
+
+		db = reopenDB(t, db)
+
+		if v := getVersion(t, db, "bucket", "a"); v != 5 {
+			t.Errorf("version after reopen = %d, want 5", v)
+		}
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.PutIfVersion("bucket", []byte("a"), []byte("4"), 4); err != ErrVersionMismatch {
+				t.Errorf("PutIfVersion at an old version returned %v, want ErrVersionMismatch", err)
+			}
+
+			return tx.PutIfVersion("bucket", []byte("a"), []byte("4"), 5)
+		})
+	})
+}
+
+func TestTx_VersionAfterDeleteBucket(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		for i := 0; i < 3; i++ {
+			update(t, db, func(tx *Tx) error {
+				return tx.Put("bucket", []byte("a"), []byte("old"), Persistent)
+			})
+		}
+
+		update(t, db, func(tx *Tx) error {
+			return tx.Put("bucket", []byte("b"), []byte("old"), Persistent)
+		})
+
+		update(t, db, func(tx *Tx) error {
+			return tx.DeleteBucket(DataStrucctureBPTree, "bucket")
+		})
+
+		// the versions of the recreated bucket start above every version of the deleted one
+		update(t, db, func(tx *Tx) error {
+			if err := tx.PutIfVersion("bucket", []byte("b"), []byte("new"), 0); err != nil {
+				return err
+			}
+
+			_, version, err := tx.GetWithVersion("bucket", []byte("b"))
+			if version != 4 {
+				t.Errorf("GetWithVersion of a pending key of a recreated bucket = %d, %v, want 4", version, err)
+			}
+
+			return err
+		})
+
+		update(t, db, func(tx *Tx) error {
+			_, version, err := tx.GetWithVersion("bucket", []byte("b"))
+			if version != 4 {
+				t.Errorf("GetWithVersion of a key of a recreated bucket = %d, %v, want 4", version, err)
+			}
+
+			return tx.Put("bucket", []byte("a"), []byte("new"), Persistent)
+		})
+
+		check := func(db *DB) {
+			if a, b := getVersion(t, db, "bucket", "a"), getVersion(t, db, "bucket", "b"); a != 4 || b != 4 {
+				t.Errorf("versions of a and b = %d and %d, want 4 and 4", a, b)
+			}
+		}
+
+		check(db)
+		db = reopenDB(t, db)
+		check(db)
+
+		// an ETag of the deleted bucket never matches again
+		update(t, db, func(tx *Tx) error {
+			for version := uint64(1); version < 4; version++ {
+				if err := tx.PutIfVersion("bucket", []byte("a"), []byte("stale"), version); err != ErrVersionMismatch {
+					t.Errorf("PutIfVersion at the version %d of the deleted bucket returned %v, want ErrVersionMismatch", version, err)
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestTx_PutIfVersionConflict(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		return tx.Put("bucket", []byte("a"), []byte("1"), Persistent)
+	})
+
+	first, second := beginWritable(t, db), beginWritable(t, db)
+
+	for _, tx := range []*Tx{first, second} {
+		if err := tx.PutIfVersion("bucket", []byte("a"), []byte("2"), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := first.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := second.Commit(); err != ErrConflict {
+		t.Errorf("Commit of the second PutIfVersion returned %v, want ErrConflict", err)
+	}
+}
@@ -0,0 +1,202 @@
+package nutsdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+)
+
+// ErrConflict is returned by Commit when a key read or written by the tx was written by another tx
+// committed after the tx began, the tx is closed and can be retried
+var ErrConflict = errors.New("tx conflicts with a concurrent tx")
+
+type (
+	// accessSet represents the fingerprints of the keys and the buckets accessed by a tx
+	// A fingerprint is a hash, so a collision can only cause a spurious conflict
+	accessSet struct {
+		keys	map[uint64]struct{} // the keys
+		buckets	map[uint64]struct{} // the buckets of the keys, and the buckets accessed as a whole
+		wholes	map[uint64]struct{} // the buckets accessed as a whole, like a sorted set or a bucket deletion
+	}
+
+	// committedWrites represents the writes of the tx committed at seq
+	committedWrites struct {
+		seq		uint64
+		writes	*accessSet
+	}
+)
+
+// UpdateWithRetry runs fn in a read/write tx and commits it, the tx is run again while it fails with ErrConflict,
+// up to attempts times in total, so fn must have no effect outside the tx
+// The error of fn or of Commit is returned
+func (db *DB) UpdateWithRetry(attempts int, fn func(tx *Tx) error) error {
+	if fn == nil {
+		return ErrFn
+	}
+
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for i := 0; i < attempts; i++ {
+		tx, err := db.Begin(true)
+		if err != nil {
+			return err
+		}
+
+		if err = fn(tx); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if err = tx.Commit(); err == nil {
+			return nil
+		}
+
+		if err != ErrConflict {
+			return err
+		}
+	}
+
+	return ErrConflict
+}
+
+// trackKey records that the writable tx read the key of the bucket of the data structure
+func (tx *Tx) trackKey(ds uint16, bucket string, key []byte) {
+	if tx.writable {
+		tx.reads.addKey(ds, bucket, key)
+	}
+}
+
+// trackBucket records that the writable tx read the bucket of the data structure as a whole
+func (tx *Tx) trackBucket(ds uint16, bucket string) {
+	if tx.writable {
+		tx.reads.addBucket(ds, bucket)
+	}
+}
+
+// writeSet returns the keys and the buckets written by the pending writes of the tx
+// The sorted sets are written as a whole since the bucket is the sorted set
+func (tx *Tx) writeSet() *accessSet {
+	writes := &accessSet{}
+	for _, e := range tx.pendingWrites {
+		bucket := string(e.Meta.bucket)
+		if e.Meta.ds == DataStructureSortedSet || e.Meta.Flag == DataCreateBucketFlag || e.Meta.Flag == DataDeleteBucketFlag {
+			writes.addBucket(e.Meta.ds, bucket)
+		} else {
+			writes.addKey(e.Meta.ds, bucket, e.Key)
+		}
+	}
+
+	return writes
+}
+
+// validate returns ErrConflict if one of the access sets overlaps the writes committed after seq,
+// db.commitMu must be locked
+func (db *DB) validate(seq uint64, sets ...*accessSet) error {
+	for _, c := range db.committed {
+		if c.seq <= seq {
+			continue
+		}
+
+		for _, s := range sets {
+			if s.conflicts(c.writes) {
+				return ErrConflict
+			}
+		}
+	}
+
+	return nil
+}
+
+// recordWrites keeps the writes of the commit for the writable txs in progress, and drops the writes
+// that no writable tx can conflict with anymore, db.commitMu and db.mu must be locked
+func (db *DB) recordWrites(writes *accessSet) {
+	db.snapMu.Lock()
+	defer db.snapMu.Unlock()
+
+	if len(db.writers) == 0 {
+		db.committed = nil
+		return
+	}
+
+	oldest := db.commit.seq
+	for seq := range db.writers {
+		if seq < oldest {
+			oldest = seq
+		}
+	}
+
+	n := 0
+	for n < len(db.committed) && db.committed[n].seq <= oldest {
+		n++
+	}
+
+	db.committed = append(db.committed[n:], committedWrites{seq: db.commit.seq, writes: writes})
+}
+
+// addKey adds the key of the bucket of the data structure
+func (a *accessSet) addKey(ds uint16, bucket string, key []byte) {
+	if a.keys == nil {
+		a.keys = make(map[uint64]struct{})
+		a.buckets = make(map[uint64]struct{})
+	}
+
+	a.keys[fingerprint(ds, bucket, key)] = struct{}{}
+	a.buckets[fingerprint(ds, bucket, nil)] = struct{}{}
+}
+
+// addBucket adds the bucket of the data structure as a whole
+func (a *accessSet) addBucket(ds uint16, bucket string) {
+	if a.wholes == nil {
+		a.wholes = make(map[uint64]struct{})
+	}
+
+	if a.buckets == nil {
+		a.buckets = make(map[uint64]struct{})
+	}
+
+	fp := fingerprint(ds, bucket, nil)
+	a.wholes[fp] = struct{}{}
+	a.buckets[fp] = struct{}{}
+}
+
+// conflicts returns if the access set overlaps other: a same key, or a bucket accessed as a whole by one
+// and accessed in any way by the other
+func (a *accessSet) conflicts(other *accessSet) bool {
+	return overlaps(a.keys, other.keys) || overlaps(a.wholes, other.buckets) || overlaps(a.buckets, other.wholes)
+}
+
+// overlaps returns if the two sets of fingerprints have a common element
+func overlaps(a, b map[uint64]struct{}) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+
+	for fp := range a {
+		if _, ok := b[fp]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fingerprint returns the fingerprint of the key of the bucket of the data structure,
+// the nil key represents the bucket itself since the keys cannot be empty
+func fingerprint(ds uint16, bucket string, key []byte) uint64 {
+	var buf [6]byte
+	binary.LittleEndian.PutUint16(buf[0:2], ds)
+	binary.LittleEndian.PutUint32(buf[2:6], uint32(len(bucket)))
+
+	h := fnv.New64a()
+	_, _ = h.Write(buf[:])
+	_, _ = h.Write([]byte(bucket))
+
+	if key != nil {
+		_, _ = h.Write([]byte{1})
+		_, _ = h.Write(key)
+	}
+
+	return h.Sum64()
+}
@@ -0,0 +1,273 @@
+package nutsdb
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// beginWritable begins a read/write tx
+func beginWritable(t *testing.T, db *DB) *Tx {
+	t.Helper()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func TestTx_Conflict(t *testing.T) {
+	tests := []struct {
+		name		string
+		first		func(tx *Tx) error
+		second		func(tx *Tx) error
+		conflict	bool
+	}{
+		{
+			"same key written",
+			func(tx *Tx) error { return tx.Put("bucket", []byte("a"), []byte("1"), Persistent) },
+			func(tx *Tx) error { return tx.Put("bucket", []byte("a"), []byte("2"), Persistent) },
+			true,
+		},
+		{
+			"disjoint keys",
+			func(tx *Tx) error { return tx.Put("bucket", []byte("a"), []byte("1"), Persistent) },
+			func(tx *Tx) error { return tx.Put("bucket", []byte("b"), []byte("2"), Persistent) },
+			false,
+		},
+		{
+			"same key in another bucket",
+			func(tx *Tx) error { return tx.Put("bucket", []byte("a"), []byte("1"), Persistent) },
+			func(tx *Tx) error { return tx.Put("other", []byte("a"), []byte("2"), Persistent) },
+			false,
+		},
+		{
+			"key read by the second",
+			func(tx *Tx) error { return tx.Put("bucket", []byte("a"), []byte("1"), Persistent) },
+			func(tx *Tx) error {
+				_, _ = tx.Get("bucket", []byte("a"))
+				return tx.Put("bucket", []byte("b"), []byte("2"), Persistent)
+			},
+			true,
+		},
+		{
+			"same sorted set",
+			func(tx *Tx) error { return tx.ZAdd("zset", []byte("a"), 1, nil) },
+			func(tx *Tx) error { return tx.ZAdd("zset", []byte("b"), 2, nil) },
+			true,
+		},
+		{
+			"set of another key read",
+			func(tx *Tx) error { return tx.SAdd("bucket", []byte("a"), []byte("1")) },
+			func(tx *Tx) error {
+				_, _ = tx.SMembers("bucket", []byte("s"))
+				return tx.SAdd("bucket", []byte("b"), []byte("2"))
+			},
+			false,
+		},
+		{
+			"set read in a deleted bucket",
+			func(tx *Tx) error { return tx.DeleteBucket(DataStructureSet, "bucket") },
+			func(tx *Tx) error {
+				_, _ = tx.SMembers("bucket", []byte("s"))
+				return tx.Put("bucket", []byte("b"), []byte("2"), Persistent)
+			},
+			true,
+		},
+		{
+			"hash of another key read",
+			func(tx *Tx) error { return tx.HSet("hash", []byte("a"), []byte("f"), []byte("1")) },
+			func(tx *Tx) error {
+				_, _ = tx.HGetAll("hash", []byte("h"))
+				return tx.HSet("hash", []byte("b"), []byte("f"), []byte("2"))
+			},
+			false,
+		},
+		{
+			"bucket deleted",
+			func(tx *Tx) error { return tx.DeleteBucket(DataStrucctureBPTree, "bucket") },
+			func(tx *Tx) error { return tx.Put("bucket", []byte("b"), []byte("2"), Persistent) },
+			true,
+		},
+		{
+			"bucket of another data structure deleted",
+			func(tx *Tx) error { return tx.DeleteBucket(DataStructureSet, "bucket") },
+			func(tx *Tx) error { return tx.Put("bucket", []byte("b"), []byte("2"), Persistent) },
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+
+			update(t, db, func(tx *Tx) error {
+				if err := tx.Put("bucket", []byte("z"), []byte("0"), Persistent); err != nil {
+					return err
+				}
+
+				if err := tx.HSet("hash", []byte("h"), []byte("f"), []byte("0")); err != nil {
+					return err
+				}
+
+				return tx.SAdd("bucket", []byte("s"), []byte("x"))
+			})
+
+			first, second := beginWritable(t, db), beginWritable(t, db)
+
+			if err := tt.first(first); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.second(second); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := first.Commit(); err != nil {
+				t.Fatal(err)
+			}
+
+			err := second.Commit()
+			if tt.conflict && err != ErrConflict || !tt.conflict && err != nil {
+				t.Errorf("Commit of the second tx returned %v, want conflict %v", err, tt.conflict)
+			}
+
+			// the tx is closed even if it conflicts
+			if err = second.Put("bucket", []byte("c"), nil, Persistent); err != ErrTxClosed {
+				t.Errorf("Put after Commit returned %v, want ErrTxClosed", err)
+			}
+		})
+	}
+}
+
+func TestTx_ConflictAfterBegin(t *testing.T) {
+	db := openTestDB(t)
+
+	// a tx only conflicts with the commits made after it began
+	update(t, db, func(tx *Tx) error {
+		return tx.Put("bucket", []byte("a"), []byte("1"), Persistent)
+	})
+
+	tx := beginWritable(t, db)
+	if _, err := tx.Get("bucket", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Put("bucket", []byte("a"), []byte("2"), Persistent); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Commit returned %v, want no conflict", err)
+	}
+}
+
+func TestDB_UpdateWithRetry(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.UpdateWithRetry(3, nil); err != ErrFn {
+		t.Errorf("UpdateWithRetry without fn returned %v, want ErrFn", err)
+	}
+
+	// a concurrent commit makes the first attempts conflict
+	interfere := func(attempts, conflicting int) (int, error) {
+		n := 0
+		err := db.UpdateWithRetry(attempts, func(tx *Tx) error {
+			n++
+			if _, err := tx.Get("bucket", []byte("k")); err != nil && err != ErrNotFoundKey && err != ErrBucket {
+				return err
+			}
+
+			if n <= conflicting {
+				update(t, db, func(other *Tx) error {
+					return other.Put("bucket", []byte("k"), []byte(strconv.Itoa(n)), Persistent)
+				})
+			}
+
+			return tx.Put("bucket", []byte("k"), []byte("retried"), Persistent)
+		})
+
+		return n, err
+	}
+
+	if n, err := interfere(3, 2); n != 3 || err != nil {
+		t.Errorf("UpdateWithRetry ran fn %d times and returned %v, want 3 and nil", n, err)
+	}
+
+	if n, err := interfere(2, 2); n != 2 || err != ErrConflict {
+		t.Errorf("UpdateWithRetry ran fn %d times and returned %v, want 2 and ErrConflict", n, err)
+	}
+
+	errFn := errors.New("fn failed")
+	n := 0
+	if err := db.UpdateWithRetry(3, func(tx *Tx) error { n++; return errFn }); err != errFn || n != 1 {
+		t.Errorf("UpdateWithRetry ran a failing fn %d times and returned %v, want 1 and its error", n, err)
+	}
+}
+
+func TestDB_UpdateWithRetryConcurrent(t *testing.T) {
+	db := openTestDB(t)
+
+	const writers, incrs = 8, 20
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < incrs; i++ {
+				// the shared counter conflicts, the own key never does
+				err := db.UpdateWithRetry(1000, func(tx *Tx) error {
+					if _, err := tx.Incr("bucket", []byte("shared")); err != nil {
+						return err
+					}
+
+					_, err := tx.Incr("bucket", []byte("own"+strconv.Itoa(w)))
+					return err
+				})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	view(t, db, func(tx *Tx) error {
+		if got := getValue(tx, "bucket", "shared"); got != strconv.Itoa(writers*incrs) {
+			t.Errorf("shared counter = %s, want %d", got, writers*incrs)
+		}
+
+		for w := 0; w < writers; w++ {
+			if got := getValue(tx, "bucket", "own"+strconv.Itoa(w)); got != strconv.Itoa(incrs) {
+				t.Errorf("counter of the writer %d = %s, want %d", w, got, incrs)
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestTx_IDsNeverRepeat(t *testing.T) {
+	db := openTestDB(t)
+
+	// the txs begin within the same millisecond
+	seen := make(map[uint64]bool)
+	for i := 0; i < 100; i++ {
+		tx := beginWritable(t, db)
+		if seen[tx.id] {
+			t.Errorf("tx %d got the ID %d of a previous tx", i, tx.id)
+		}
+		seen[tx.id] = true
+
+		if err := tx.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
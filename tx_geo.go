@@ -0,0 +1,89 @@
+package nutsdb
+
+import (
+	"github.com/HelloChenHZ/nutsdb/ds/geo"
+)
+
+// GeoAdd adds the specified member key at the given longitude and latitude with the specified val
+// to the sorted set stored at bucket, the score of the member is the 52 bits geohash of its position
+func (tx *Tx) GeoAdd(bucket string, key []byte, longitude, latitude float64, val []byte) error {
+	hash, err := geo.Encode(geo.Point{Longitude: longitude, Latitude: latitude})
+	if err != nil {
+		return err
+	}
+
+	return tx.ZAdd(bucket, key, float64(hash), val)
+}
+
+// GeoPos returns the positions of the specified members of the sorted set stored at bucket
+// For every member that does not exist, a nil position is returned
+// The positions are the centers of the geohash areas, so they may differ slightly from the added ones
+func (tx *Tx) GeoPos(bucket string, keys ...[]byte) ([]*geo.Point, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]*geo.Point, len(keys))
+	for i, key := range keys {
+		if node := ss.GetByKey(string(key)); node != nil {
+			p := geo.Decode(uint64(node.Score()))
+			points[i] = &p
+		}
+	}
+
+	return points, nil
+}
+
+// GeoDist returns the distance between two members of the sorted set stored at bucket in the given unit
+func (tx *Tx) GeoDist(bucket string, key1, key2 []byte, unit geo.Unit) (float64, error) {
+	if unit <= 0 {
+		return 0, geo.ErrUnit
+	}
+
+	p1, err := tx.geoPoint(bucket, key1)
+	if err != nil {
+		return 0, err
+	}
+
+	p2, err := tx.geoPoint(bucket, key2)
+	if err != nil {
+		return 0, err
+	}
+
+	return geo.Distance(p1, p2) / float64(unit), nil
+}
+
+// GeoSearch returns the members of the sorted set stored at bucket within the circle or the box of the query
+// around query.Center, see geo.Query for the sort and count options
+func (tx *Tx) GeoSearch(bucket string, query *geo.Query) ([]*geo.Location, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return geo.Search(ss, query)
+}
+
+// GeoSearchByMember is like GeoSearch, but the center of the search is the position of the member key
+func (tx *Tx) GeoSearchByMember(bucket string, key []byte, query *geo.Query) ([]*geo.Location, error) {
+	center, err := tx.geoPoint(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	q := *query
+	q.Center = center
+
+	return tx.GeoSearch(bucket, &q)
+}
+
+// geoPoint returns the position of the member key of the sorted set stored at bucket
+func (tx *Tx) geoPoint(bucket string, key []byte) (geo.Point, error) {
+	node, err := tx.ZGetByKey(bucket, key)
+	if err != nil {
+		return geo.Point{}, err
+	}
+
+	return geo.Decode(uint64(node.Score())), nil
+}
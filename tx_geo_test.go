@@ -0,0 +1,70 @@
+package nutsdb
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/HelloChenHZ/nutsdb/ds/geo"
+)
+
+func TestTx_Geo(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.GeoAdd("sicily", []byte("Palermo"), 13.361389, 38.115556, []byte("pa")); err != nil {
+			return err
+		}
+
+		if err := tx.GeoAdd("sicily", []byte("Catania"), 15.087269, 37.502669, []byte("ct")); err != nil {
+			return err
+		}
+
+		if err := tx.GeoAdd("sicily", []byte("Nowhere"), 0, 90, nil); err != geo.ErrCoordinates {
+			t.Errorf("GeoAdd at the pole returned %v, want ErrCoordinates", err)
+		}
+
+		return nil
+	})
+
+	db = reopenDB(t, db)
+
+	view(t, db, func(tx *Tx) error {
+		points, err := tx.GeoPos("sicily", []byte("Palermo"), []byte("missing"))
+		if err != nil {
+			return err
+		}
+
+		if points[0] == nil || math.Abs(points[0].Longitude-13.361389) > 1e-5 || points[1] != nil {
+			t.Errorf("GeoPos = %v, %v", points[0], points[1])
+		}
+
+		if d, err := tx.GeoDist("sicily", []byte("Palermo"), []byte("Catania"), geo.Kilometers); err != nil || math.Abs(d-166.2742) > 0.001 {
+			t.Errorf("GeoDist = %f, %v, want 166.2742", d, err)
+		}
+
+		if _, err = tx.GeoDist("sicily", []byte("Palermo"), []byte("missing"), geo.Meters); err != ErrNotFoundKey {
+			t.Errorf("GeoDist to a missing member returned %v, want ErrNotFoundKey", err)
+		}
+
+		locations, err := tx.GeoSearchByMember("sicily", []byte("Palermo"), &geo.Query{Radius: 200, Unit: geo.Kilometers, Sort: geo.SortDesc})
+		if err != nil {
+			return err
+		}
+
+		keys := []string{}
+		for _, l := range locations {
+			keys = append(keys, l.Key)
+		}
+
+		if !reflect.DeepEqual(keys, []string{"Catania", "Palermo"}) || string(locations[0].Value) != "ct" {
+			t.Errorf("GeoSearchByMember = %v", keys)
+		}
+
+		if _, err = tx.GeoSearch("sicily", &geo.Query{Radius: 1}); err != geo.ErrUnit {
+			t.Errorf("GeoSearch without unit returned %v, want ErrUnit", err)
+		}
+
+		return nil
+	})
+}
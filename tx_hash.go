@@ -0,0 +1,285 @@
+package nutsdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/HelloChenHZ/nutsdb/ds/hash"
+)
+
+// ErrHashValue is returned when the value of the hash entry is malformed
+var ErrHashValue = errors.New("err hash value")
+
+// HSet sets field in the hash stored in the bucket at given bucket and key to value
+func (tx *Tx) HSet(bucket string, key, field, value []byte) error {
+	return tx.put(bucket, key, encodeHashValue(field, value), Persistent, DataHSetFlag, uint64(time.Now().Unix()), DataStructureHash)
+}
+
+// HGet returns the value associated with field in the hash stored in the bucket at given bucket and key
+func (tx *Tx) HGet(bucket string, key, field []byte) ([]byte, error) {
+	value, exists, err := tx.hashFieldValue(bucket, key, field)
+	if err != nil || exists {
+		return value, err
+	}
+
+	if _, err := tx.getHash(bucket, key); err != nil {
+		return nil, err
+	}
+
+	return nil, hash.ErrFieldNotFound
+}
+
+// HMGet returns the values associated with the specified fields in the hash stored in the bucket at given bucket and key
+// For every field that does not exist in the hash, a nil value is returned
+func (tx *Tx) HMGet(bucket string, key []byte, fields ...[]byte) ([][]byte, error) {
+	all, err := tx.getHash(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, len(fields))
+	for i, field := range fields {
+		values[i] = all[string(field)]
+	}
+
+	return values, nil
+}
+
+// HDel removes the specified fields from the hash stored in the bucket at given bucket and key
+// It returns the number of the existing fields that will be removed, the fields set earlier in the same tx included
+func (tx *Tx) HDel(bucket string, key []byte, fields ...[]byte) (int, error) {
+	removed := 0
+	for _, field := range fields {
+		_, exists, err := tx.hashFieldValue(bucket, key, field)
+		if err != nil {
+			return 0, err
+		}
+
+		if !exists {
+			continue
+		}
+
+		if err := tx.put(bucket, key, encodeHashValue(field, nil), Persistent, DataHDelFlag, uint64(time.Now().Unix()), DataStructureHash); err != nil {
+			return 0, err
+		}
+
+		removed++
+	}
+
+	if removed == 0 {
+		// the error of a missing hash takes precedence over the count of the missing fields
+		if _, err := tx.getHash(bucket, key); err != nil {
+			return 0, err
+		}
+	}
+
+	return removed, nil
+}
+
+// HExists returns if field is an existing field in the hash stored in the bucket at given bucket and key
+func (tx *Tx) HExists(bucket string, key, field []byte) (bool, error) {
+	_, exists, err := tx.hashFieldValue(bucket, key, field)
+	if err != nil || exists {
+		return exists, err
+	}
+
+	if _, err := tx.getHash(bucket, key); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// HLen returns the number of fields contained in the hash stored in the bucket at given bucket and key
+func (tx *Tx) HLen(bucket string, key []byte) (int, error) {
+	all, err := tx.getHash(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(all), nil
+}
+
+// HKeys returns all field names in the hash stored in the bucket at given bucket and key
+func (tx *Tx) HKeys(bucket string, key []byte) ([]string, error) {
+	all, err := tx.getHash(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(all))
+	for field := range all {
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// HVals returns all values in the hash stored in the bucket at given bucket and key
+func (tx *Tx) HVals(bucket string, key []byte) ([][]byte, error) {
+	all, err := tx.getHash(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, 0, len(all))
+	for _, value := range all {
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// HGetAll returns all fields and values of the hash stored in the bucket at given bucket and key
+func (tx *Tx) HGetAll(bucket string, key []byte) (map[string][]byte, error) {
+	return tx.getHash(bucket, key)
+}
+
+// HIncrBy increments the number stored at field in the hash stored in the bucket at given bucket and key by increment
+// If field does not exist the value is set to 0 before the operation is performed
+// The increments made earlier in the same tx are taken into account, the result is logged as a HSet
+func (tx *Tx) HIncrBy(bucket string, key, field []byte, increment int64) (int64, error) {
+	value, _, err := tx.hashFieldValue(bucket, key, field)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := hash.IncrBy(value, increment)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.HSet(bucket, key, field, []byte(strconv.FormatInt(n, 10)))
+}
+
+// hashFieldValue returns the value of field in the hash stored in the bucket at given bucket and key and if it exists,
+// the pending writes of the tx are taken into account
+func (tx *Tx) hashFieldValue(bucket string, key, field []byte) (value []byte, exists bool, err error) {
+	if err := tx.checkTxIsClosed(); err != nil {
+		return nil, false, err
+	}
+
+	tx.trackKey(DataStructureHash, bucket, key)
+
+	for i := len(tx.pendingWrites) - 1; i >= 0; i-- {
+		e := tx.pendingWrites[i]
+		if e.Meta.ds != DataStructureHash || string(e.Meta.bucket) != bucket {
+			continue
+		}
+
+		// the committed fields were removed with the bucket
+		if e.Meta.Flag == DataDeleteBucketFlag {
+			return nil, false, nil
+		}
+
+		if string(e.Key) != string(key) {
+			continue
+		}
+
+		pendingField, value, err := decodeHashValue(e.Value)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if pendingField != string(field) {
+			continue
+		}
+
+		if e.Meta.Flag == DataHDelFlag {
+			return nil, false, nil
+		}
+
+		return value, true, nil
+	}
+
+	if h, ok := tx.snap.HashIdx[bucket]; ok {
+		value, exists = h.M[string(key)][string(field)]
+	}
+
+	return value, exists, nil
+}
+
+// getHash returns the fields and values of the hash stored in the bucket at given bucket and key as seen inside the tx,
+// the pending writes of the tx are applied on a copy of the committed fields
+func (tx *Tx) getHash(bucket string, key []byte) (map[string][]byte, error) {
+	exists, err := tx.keyBucketExists(DataStructureHash, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrBucket
+	}
+
+	all := make(map[string][]byte)
+	if h, ok := tx.snap.HashIdx[bucket]; ok {
+		for field, value := range h.M[string(key)] {
+			all[field] = value
+		}
+	}
+
+	for _, e := range tx.pendingWrites {
+		if e.Meta.ds != DataStructureHash || string(e.Meta.bucket) != bucket {
+			continue
+		}
+
+		if e.Meta.Flag == DataDeleteBucketFlag {
+			all = make(map[string][]byte)
+		}
+
+		if string(e.Key) != string(key) || e.Meta.Flag != DataHSetFlag && e.Meta.Flag != DataHDelFlag {
+			continue
+		}
+
+		field, value, err := decodeHashValue(e.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Meta.Flag == DataHSetFlag {
+			all[field] = value
+		} else {
+			delete(all, field)
+		}
+	}
+
+	if len(all) == 0 {
+		return nil, hash.ErrHashNotFound
+	}
+
+	return all, nil
+}
+
+// encodeHashValue returns the value of the hash entry
+//
+//  the hash entry value format:
+//  |--------------------------------|
+//  | fieldSize | field  |  value    |
+//  |--------------------------------|
+//  |  uint32   | []byte |  []byte   |
+//  |--------------------------------|
+//
+func encodeHashValue(field, value []byte) []byte {
+	buf := make([]byte, 4+len(field)+len(value))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(field)))
+	copy(buf[4:], field)
+	copy(buf[4+len(field):], value)
+
+	return buf
+}
+
+// decodeHashValue returns the field and the value at given hash entry value
+func decodeHashValue(buf []byte) (field string, value []byte, err error) {
+	if len(buf) < 4 {
+		return "", nil, ErrHashValue
+	}
+
+	fieldSize := int(binary.LittleEndian.Uint32(buf[0:4]))
+	if len(buf) < 4+fieldSize {
+		return "", nil, ErrHashValue
+	}
+
+	return string(buf[4 : 4+fieldSize]), buf[4+fieldSize:], nil
+}
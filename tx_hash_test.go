@@ -0,0 +1,243 @@
+package nutsdb
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/HelloChenHZ/nutsdb/ds/hash"
+)
+
+func TestTx_HashCommands(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			for _, field := range []string{"a", "b", "c", "d"} {
+				if err := tx.HSet("bucket", []byte("h"), []byte(field), []byte("v"+field)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.HSet("bucket", []byte("h"), []byte("e"), []byte("ve")); err != nil {
+				return err
+			}
+
+			// the field set in the same tx is removed, and a field removed twice is counted once
+			n, err := tx.HDel("bucket", []byte("h"), []byte("a"), []byte("a"), []byte("e"), []byte("missing"))
+			if err != nil || n != 2 {
+				t.Errorf("HDel = %d, %v, want 2", n, err)
+			}
+
+			if _, err = tx.HIncrBy("bucket", []byte("h"), []byte("n"), 5); err != nil {
+				return err
+			}
+
+			n64, err := tx.HIncrBy("bucket", []byte("h"), []byte("n"), -2)
+			if err != nil || n64 != 3 {
+				t.Errorf("HIncrBy twice = %d, %v, want 3", n64, err)
+			}
+
+			return err
+		})
+
+		check := func(db *DB) {
+			view(t, db, func(tx *Tx) error {
+				all, err := tx.HGetAll("bucket", []byte("h"))
+				if err != nil {
+					return err
+				}
+
+				want := map[string][]byte{"b": []byte("vb"), "c": []byte("vc"), "d": []byte("vd"), "n": []byte("3")}
+				if !reflect.DeepEqual(all, want) {
+					t.Errorf("HGetAll = %q, want %q", all, want)
+				}
+
+				if value, err := tx.HGet("bucket", []byte("h"), []byte("b")); err != nil || string(value) != "vb" {
+					t.Errorf("HGet(b) = %s, %v", value, err)
+				}
+
+				values, _ := tx.HMGet("bucket", []byte("h"), []byte("c"), []byte("a"))
+				if string(values[0]) != "vc" || values[1] != nil {
+					t.Errorf("HMGet = %q", values)
+				}
+
+				fields, _ := tx.HKeys("bucket", []byte("h"))
+				sort.Strings(fields)
+				if !reflect.DeepEqual(fields, []string{"b", "c", "d", "n"}) {
+					t.Errorf("HKeys = %v", fields)
+				}
+
+				if n, _ := tx.HLen("bucket", []byte("h")); n != 4 {
+					t.Errorf("HLen = %d, want 4", n)
+				}
+
+				if values, _ = tx.HVals("bucket", []byte("h")); len(values) != 4 {
+					t.Errorf("HVals returned %d values, want 4", len(values))
+				}
+
+				if ok, _ := tx.HExists("bucket", []byte("h"), []byte("a")); ok {
+					t.Error("the removed field still exists")
+				}
+
+				if _, err = tx.HGet("bucket", []byte("missing"), []byte("a")); err != hash.ErrHashNotFound {
+					t.Errorf("HGet of a missing hash returned %v, want ErrHashNotFound", err)
+				}
+
+				if _, err = tx.HGet("missing", []byte("h"), []byte("a")); err != ErrBucket {
+					t.Errorf("HGet of a missing bucket returned %v, want ErrBucket", err)
+				}
+
+				return nil
+			})
+		}
+
+		check(db)
+		db = reopenDB(t, db)
+		check(db)
+	})
+}
+
+func TestTx_HIncrByErrors(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.HSet("bucket", []byte("h"), []byte("s"), []byte("abc")); err != nil {
+			return err
+		}
+
+		return tx.HSet("bucket", []byte("h"), []byte("max"), []byte(strconv.FormatInt(math.MaxInt64, 10)))
+	})
+
+	update(t, db, func(tx *Tx) error {
+		if _, err := tx.HIncrBy("bucket", []byte("h"), []byte("s"), 1); err != ErrNotInteger {
+			t.Errorf("HIncrBy of a string returned %v, want ErrNotInteger", err)
+		}
+
+		if _, err := tx.HIncrBy("bucket", []byte("h"), []byte("max"), 1); err != ErrIncrOverflow {
+			t.Errorf("HIncrBy overflow returned %v, want ErrIncrOverflow", err)
+		}
+
+		return nil
+	})
+}
+
+func TestTx_HDelLastField(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		return tx.HSet("bucket", []byte("h"), []byte("a"), []byte("1"))
+	})
+
+	update(t, db, func(tx *Tx) error {
+		_, err := tx.HDel("bucket", []byte("h"), []byte("a"))
+		return err
+	})
+
+	db = reopenDB(t, db)
+
+	view(t, db, func(tx *Tx) error {
+		if _, err := tx.HLen("bucket", []byte("h")); err != hash.ErrHashNotFound {
+			t.Errorf("HLen of the emptied hash returned %v, want ErrHashNotFound", err)
+		}
+
+		return nil
+	})
+}
+
+func TestTx_HashReadOwnWrites(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		return tx.HSet("committed", []byte("h"), []byte("a"), []byte("1"))
+	})
+
+	update(t, db, func(tx *Tx) error {
+		// a bucket and a hash created by the tx
+		for _, field := range []string{"a", "b", "c"} {
+			if err := tx.HSet("bucket", []byte("h"), []byte(field), []byte(field+"1")); err != nil {
+				return err
+			}
+		}
+
+		if value, err := tx.HGet("bucket", []byte("h"), []byte("b")); err != nil || string(value) != "b1" {
+			t.Errorf("HGet of a pending field = %q, %v, want b1", value, err)
+		}
+
+		if _, err := tx.HGet("bucket", []byte("h"), []byte("x")); err != hash.ErrFieldNotFound {
+			t.Errorf("HGet of a missing field returned %v, want ErrFieldNotFound", err)
+		}
+
+		if n, err := tx.HDel("bucket", []byte("h"), []byte("c"), []byte("x")); n != 1 || err != nil {
+			t.Errorf("HDel of a pending field = %d, %v, want 1", n, err)
+		}
+
+		if n, err := tx.HLen("bucket", []byte("h")); n != 2 || err != nil {
+			t.Errorf("HLen = %d, %v, want 2", n, err)
+		}
+
+		if exists, err := tx.HExists("bucket", []byte("h"), []byte("c")); exists || err != nil {
+			t.Errorf("HExists of a removed field = %v, %v", exists, err)
+		}
+
+		keys, _ := tx.HKeys("bucket", []byte("h"))
+		sort.Strings(keys)
+		if !reflect.DeepEqual(keys, []string{"a", "b"}) {
+			t.Errorf("HKeys = %v, want [a b]", keys)
+		}
+
+		if values, _ := tx.HVals("bucket", []byte("h")); len(values) != 2 {
+			t.Errorf("HVals = %q, want 2 values", values)
+		}
+
+		if all, _ := tx.HGetAll("bucket", []byte("h")); !reflect.DeepEqual(all, map[string][]byte{"a": []byte("a1"), "b": []byte("b1")}) {
+			t.Errorf("HGetAll = %q", all)
+		}
+
+		if values, _ := tx.HMGet("bucket", []byte("h"), []byte("a"), []byte("c")); string(values[0]) != "a1" || values[1] != nil {
+			t.Errorf("HMGet = %q, want [a1 nil]", values)
+		}
+
+		// a hash emptied by the tx
+		if n, err := tx.HDel("committed", []byte("h"), []byte("a")); n != 1 || err != nil {
+			t.Errorf("HDel of a committed field = %d, %v, want 1", n, err)
+		}
+
+		if _, err := tx.HDel("committed", []byte("h"), []byte("a")); err != hash.ErrHashNotFound {
+			t.Errorf("HDel of an emptied hash returned %v, want ErrHashNotFound", err)
+		}
+
+		return nil
+	})
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.DeleteBucket(DataStructureHash, "bucket"); err != nil {
+			return err
+		}
+
+		if _, err := tx.HGet("bucket", []byte("h"), []byte("a")); err != ErrBucket {
+			t.Errorf("HGet of a deleted bucket returned %v, want ErrBucket", err)
+		}
+
+		if err := tx.HSet("bucket", []byte("h"), []byte("x"), []byte("1")); err != nil {
+			return err
+		}
+
+		// the fields of the deleted bucket are gone
+		if _, err := tx.HGet("bucket", []byte("h"), []byte("a")); err != hash.ErrFieldNotFound {
+			t.Errorf("HGet of a field of the deleted bucket returned %v, want ErrFieldNotFound", err)
+		}
+
+		if n, _ := tx.HLen("bucket", []byte("h")); n != 1 {
+			t.Errorf("HLen of a recreated bucket = %d, want 1", n)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,146 @@
+package nutsdb
+
+import (
+	"time"
+
+	"github.com/HelloChenHZ/nutsdb/ds/hll"
+)
+
+// PFAdd adds the elements to the HyperLogLog stored in the bucket at given bucket and key
+// Only the registers updated by the elements are logged, so adding a known element costs nothing
+// It returns true if the estimated cardinality may have changed or the HyperLogLog was created
+func (tx *Tx) PFAdd(bucket string, key []byte, elements ...[]byte) (bool, error) {
+	s, err := tx.getSketch(bucket, key)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	if s == nil {
+		if err := tx.put(bucket, key, hll.NewSketch().Marshal(), Persistent, DataPFMergeFlag, uint64(time.Now().Unix()), DataStructureHLL); err != nil {
+			return false, err
+		}
+
+		s, changed = tx.cacheSketch(bucket, key, hll.NewSketch()), true
+	}
+
+	for _, element := range elements {
+		index, rank := hll.Register(element)
+		if s.Get(index) >= rank {
+			continue
+		}
+
+		if err := tx.put(bucket, key, hll.EncodeRegister(index, rank), Persistent, DataPFAddFlag, uint64(time.Now().Unix()), DataStructureHLL); err != nil {
+			return false, err
+		}
+
+		s.Set(index, rank)
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// PFCount returns the estimated cardinality of the union of the HyperLogLogs stored in the bucket at given bucket and keys
+// The keys that not exist are treated as empty HyperLogLogs, the standard error is hll.StdError
+func (tx *Tx) PFCount(bucket string, keys ...[]byte) (uint64, error) {
+	return tx.PFCountByBuckets(bucketKeys(bucket, keys)...)
+}
+
+// PFCountByBuckets returns the estimated cardinality of the union of the given HyperLogLogs,
+// the HyperLogLogs may be in different buckets
+func (tx *Tx) PFCountByBuckets(keys ...BucketKey) (uint64, error) {
+	union, err := tx.pfUnion(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	return union.Count(), nil
+}
+
+// PFMerge merges the given HyperLogLogs into the HyperLogLog stored in the bucket at given bucket and dst
+// The destination is created if it not exists, otherwise its registers are merged with the sources
+func (tx *Tx) PFMerge(bucket string, dst []byte, srcs ...BucketKey) error {
+	union := hll.NewSketch()
+	if len(srcs) > 0 {
+		var err error
+		if union, err = tx.pfUnion(srcs); err != nil {
+			return err
+		}
+	}
+
+	s, err := tx.getSketch(bucket, dst)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.put(bucket, dst, union.Marshal(), Persistent, DataPFMergeFlag, uint64(time.Now().Unix()), DataStructureHLL); err != nil {
+		return err
+	}
+
+	if s == nil {
+		s = tx.cacheSketch(bucket, dst, hll.NewSketch())
+	}
+
+	s.Merge(union)
+
+	return nil
+}
+
+// pfUnion returns the union of the HyperLogLogs at given bucket keys
+func (tx *Tx) pfUnion(keys []BucketKey) (*hll.Sketch, error) {
+	if len(keys) == 0 {
+		return nil, hll.ErrKeysEmpty
+	}
+
+	union := hll.NewSketch()
+	for _, bk := range keys {
+		s, err := tx.getSketch(bk.Bucket, bk.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		if s != nil {
+			union.Merge(s)
+		}
+	}
+
+	return union, nil
+}
+
+// getSketch returns the HyperLogLog stored in the bucket at given bucket and key as seen inside the tx,
+// nil is returned if the key not exists
+// The committed HyperLogLog is copied into the tx on first access and the writes of the tx are applied on the copy,
+// so the pending writes never have to be replayed
+func (tx *Tx) getSketch(bucket string, key []byte) (*hll.Sketch, error) {
+	if err := tx.checkTxIsClosed(); err != nil {
+		return nil, err
+	}
+
+	tx.trackKey(DataStructureHLL, bucket, key)
+
+	if h, ok := tx.sketches[bucket]; ok && h.HasKey(string(key)) {
+		return h.M[string(key)], nil
+	}
+
+	if h, ok := tx.snap.HLLIdx[bucket]; ok && h.HasKey(string(key)) {
+		return tx.cacheSketch(bucket, key, h.M[string(key)].Clone()), nil
+	}
+
+	return nil, nil
+}
+
+// cacheSketch keeps the copy of the HyperLogLog in the tx and returns it
+func (tx *Tx) cacheSketch(bucket string, key []byte, s *hll.Sketch) *hll.Sketch {
+	if tx.sketches == nil {
+		tx.sketches = make(map[string]*hll.HLL)
+	}
+
+	if _, ok := tx.sketches[bucket]; !ok {
+		tx.sketches[bucket] = hll.New()
+	}
+
+	tx.sketches[bucket].M[string(key)] = s
+
+	return s
+}
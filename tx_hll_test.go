@@ -0,0 +1,114 @@
+package nutsdb
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/HelloChenHZ/nutsdb/ds/hll"
+)
+
+// pfCount returns the estimated cardinality of the HyperLogLogs stored in the bucket at given keys
+func pfCount(t *testing.T, db *DB, bucket string, keys ...string) (n uint64) {
+	t.Helper()
+
+	view(t, db, func(tx *Tx) (err error) {
+		list := make([][]byte, len(keys))
+		for i, key := range keys {
+			list[i] = []byte(key)
+		}
+
+		n, err = tx.PFCount(bucket, list...)
+		return err
+	})
+
+	return
+}
+
+func TestTx_PFAdd(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			changed, err := tx.PFAdd("bucket", []byte("a"))
+			if err != nil || !changed {
+				t.Errorf("PFAdd without element = %v, %v, want the HyperLogLog created", changed, err)
+			}
+
+			for i := 0; i < 1000; i++ {
+				if _, err = tx.PFAdd("bucket", []byte("a"), []byte(fmt.Sprint(i))); err != nil {
+					return err
+				}
+			}
+
+			// the pending registers are seen by the tx
+			if changed, _ = tx.PFAdd("bucket", []byte("a"), []byte("1")); changed {
+				t.Error("PFAdd of an added element reported a change")
+			}
+
+			n, err := tx.PFCount("bucket", []byte("a"))
+			if err != nil || n < 950 || n > 1050 {
+				t.Errorf("PFCount in the tx = %d, %v, want about 1000", n, err)
+			}
+
+			return nil
+		})
+
+		update(t, db, func(tx *Tx) error {
+			for i := 500; i < 1500; i++ {
+				if _, err := tx.PFAdd("bucket", []byte("b"), []byte(fmt.Sprint(i))); err != nil {
+					return err
+				}
+			}
+
+			return tx.PFMerge("bucket", []byte("c"), BucketKey{"bucket", []byte("a")}, BucketKey{"bucket", []byte("b")})
+		})
+
+		counts := func(db *DB) []uint64 {
+			return []uint64{pfCount(t, db, "bucket", "a"), pfCount(t, db, "bucket", "b"), pfCount(t, db, "bucket", "c"), pfCount(t, db, "bucket", "a", "b")}
+		}
+
+		before := counts(db)
+		if n := before[2]; n < 1425 || n > 1575 || before[3] != n {
+			t.Errorf("PFCount of the merged HyperLogLog = %d, union %d, want about 1500", n, before[3])
+		}
+
+		db = reopenDB(t, db)
+
+		if after := counts(db); fmt.Sprint(after) != fmt.Sprint(before) {
+			t.Errorf("PFCount after reopen = %v, want %v", after, before)
+		}
+	})
+}
+
+func TestTx_PFMergeInto(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		if _, err := tx.PFAdd("bucket", []byte("a"), []byte("1"), []byte("2")); err != nil {
+			return err
+		}
+
+		if _, err := tx.PFAdd("bucket", []byte("dst"), []byte("3")); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	update(t, db, func(tx *Tx) error {
+		// the registers of the existing destination are kept
+		if err := tx.PFMerge("bucket", []byte("dst"), BucketKey{"bucket", []byte("a")}, BucketKey{"other", []byte("missing")}); err != nil {
+			return err
+		}
+
+		if _, err := tx.PFCount("bucket"); err != hll.ErrKeysEmpty {
+			t.Errorf("PFCount without key returned %v, want ErrKeysEmpty", err)
+		}
+
+		return nil
+	})
+
+	if n := pfCount(t, db, "bucket", "dst"); n != 3 {
+		t.Errorf("PFCount(dst) = %d, want 3", n)
+	}
+}
@@ -0,0 +1,375 @@
+package nutsdb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/HelloChenHZ/nutsdb/ds/set"
+)
+
+// ErrSetNotExist is returned when the set at given bucket and key not exists
+var ErrSetNotExist = errors.New("set not exists")
+
+// BucketKey represents the key of the set in the bucket
+type BucketKey struct {
+	Bucket	string
+	Key		[]byte
+}
+
+// bucketKeys returns the BucketKey slice at given bucket and keys
+func bucketKeys(bucket string, keys [][]byte) []BucketKey {
+	bks := make([]BucketKey, len(keys))
+	for i, key := range keys {
+		bks[i] = BucketKey{Bucket: bucket, Key: key}
+	}
+
+	return bks
+}
+
+// SAdd adds the specified members to the set stored int the bucket at given bucket,key and items
+func (tx *Tx) SAdd(bucket string, key []byte, items ...[]byte) error {
+	for _, item := range items {
+		if err := tx.put(bucket, key, item, Persistent, DataSetFlag, uint64(time.Now().Unix()), DataStructureSet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SRem removes the specified members from the set stored int the bucket at given bucket,key and items
+func (tx *Tx) SRem(bucket string, key []byte, items ...[]byte) error {
+	if _, err := tx.getSet(bucket, key); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := tx.put(bucket, key, item, Persistent, DataDeleteFlag, uint64(time.Now().Unix()), DataStructureSet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SAreMembers returns if the specified members are the member of the set int the bucket at given bucket,key and items
+func (tx *Tx) SAreMembers(bucket string, key []byte, items ...[]byte) (bool, error) {
+	members, err := tx.getSet(bucket, key)
+	if err != nil {
+		return false, err
+	}
+
+	return set.AreMembers(members, items...)
+}
+
+// SIsMember returns if member is a member of the set stored int the bucket at given bucket,key and item
+func (tx *Tx) SIsMember(bucket string, key, item []byte) bool {
+	members, err := tx.getSet(bucket, key)
+	if err != nil {
+		return false
+	}
+
+	_, ok := members[string(item)]
+
+	return ok
+}
+
+// SMembers returns all the members of the set value stored int the bucket at given bucket and key
+func (tx *Tx) SMembers(bucket string, key []byte) (list [][]byte, err error) {
+	members, err := tx.getSet(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	for item := range members {
+		list = append(list, []byte(item))
+	}
+
+	return list, nil
+}
+
+// SHasKey returns if the set in the bucket at given bucket and key
+func (tx *Tx) SHasKey(bucket string, key []byte) bool {
+	_, err := tx.getSet(bucket, key)
+
+	return err == nil
+}
+
+// SPop removes and returns count random distinct elements from the set value store in the bucket at given bucket and key
+func (tx *Tx) SPop(bucket string, key []byte, count int) ([][]byte, error) {
+	if count < 0 {
+		return nil, set.ErrCount
+	}
+
+	list, err := tx.SRandMember(bucket, key, count)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range list {
+		if err := tx.put(bucket, key, item, Persistent, DataDeleteFlag, uint64(time.Now().Unix()), DataStructureSet); err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+// SRandMember returns count random elements from the set value store in the bucket at given bucket and key
+// If count is negative, it returns exactly -count elements that may be repeated
+// The elements are sampled from the members as seen inside the tx, so the members popped by the tx are not returned
+func (tx *Tx) SRandMember(bucket string, key []byte, count int) ([][]byte, error) {
+	members, err := tx.getSet(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// the random source of the bucket is used, a bucket created by the tx gets a new one
+	s, ok := tx.snap.SetIdx[bucket]
+	if !ok {
+		s = tx.db.newSet()
+	}
+
+	return s.RandMembers(members, count), nil
+}
+
+// SCard returns the set cardinality (number of elements) of the set stored in the bucket at given bucket and key
+func (tx *Tx) SCard(bucket string, key []byte) (int, error) {
+	members, err := tx.getSet(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(members), nil
+}
+
+// SDiff returns the members of the set resulting from the difference
+// between the first set and all the successive sets in the bucket at given bucket and keys
+func (tx *Tx) SDiff(bucket string, keys ...[]byte) (list [][]byte, err error) {
+	return tx.SDiffByBuckets(bucketKeys(bucket, keys)...)
+}
+
+// SInter returns the members of the set resulting from the intersection
+// of all the given sets in the bucket at given bucket and keys
+func (tx *Tx) SInter(bucket string, keys ...[]byte) (list [][]byte, err error) {
+	return tx.SInterByBuckets(bucketKeys(bucket, keys)...)
+}
+
+// SUnion returns the members of the set resulting from the union
+// of all the given sets in the bucket at given bucket and keys
+func (tx *Tx) SUnion(bucket string, keys ...[]byte) (list [][]byte, err error) {
+	return tx.SUnionByBuckets(bucketKeys(bucket, keys)...)
+}
+
+// SInterCard returns the cardinality of the intersection of all the given sets in the bucket at given bucket and keys
+// If limit is greater than 0, the counting stops as soon as the cardinality reaches limit
+func (tx *Tx) SInterCard(bucket string, limit int, keys ...[]byte) (int, error) {
+	return tx.SInterCardByBuckets(limit, bucketKeys(bucket, keys)...)
+}
+
+// SDiffByBuckets returns the members of the set resulting from the difference
+// between the first set and all the successive sets, the sets may be in different buckets
+func (tx *Tx) SDiffByBuckets(keys ...BucketKey) (list [][]byte, err error) {
+	sets, err := tx.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return set.Diff(sets...), nil
+}
+
+// SInterByBuckets returns the members of the set resulting from the intersection
+// of all the given sets, the sets may be in different buckets
+func (tx *Tx) SInterByBuckets(keys ...BucketKey) (list [][]byte, err error) {
+	sets, err := tx.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return set.Inter(sets...), nil
+}
+
+// SUnionByBuckets returns the members of the set resulting from the union
+// of all the given sets, the sets may be in different buckets
+func (tx *Tx) SUnionByBuckets(keys ...BucketKey) (list [][]byte, err error) {
+	sets, err := tx.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return set.Union(sets...), nil
+}
+
+// SInterCardByBuckets returns the cardinality of the intersection of all the given sets,
+// the sets may be in different buckets
+// If limit is greater than 0, the counting stops as soon as the cardinality reaches limit
+func (tx *Tx) SInterCardByBuckets(limit int, keys ...BucketKey) (int, error) {
+	sets, err := tx.getSets(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	return set.InterCard(limit, sets...), nil
+}
+
+// SDiffStore stores the difference of the given sets to the set in the bucket at given bucket and dst
+// If the destination set already exists, it is overwritten
+// It returns the number of the members in the resulting set
+func (tx *Tx) SDiffStore(bucket string, dst []byte, keys ...BucketKey) (int, error) {
+	list, err := tx.SDiffByBuckets(keys...)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(list), tx.sStore(bucket, dst, list)
+}
+
+// SInterStore stores the intersection of the given sets to the set in the bucket at given bucket and dst
+// If the destination set already exists, it is overwritten
+// It returns the number of the members in the resulting set
+func (tx *Tx) SInterStore(bucket string, dst []byte, keys ...BucketKey) (int, error) {
+	list, err := tx.SInterByBuckets(keys...)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(list), tx.sStore(bucket, dst, list)
+}
+
+// SUnionStore stores the union of the given sets to the set in the bucket at given bucket and dst
+// If the destination set already exists, it is overwritten
+// It returns the number of the members in the resulting set
+func (tx *Tx) SUnionStore(bucket string, dst []byte, keys ...BucketKey) (int, error) {
+	list, err := tx.SUnionByBuckets(keys...)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(list), tx.sStore(bucket, dst, list)
+}
+
+// sStore overwrites the set in the bucket at given bucket and key with the given members,
+// the set is removed if there is no member
+func (tx *Tx) sStore(bucket string, key []byte, list [][]byte) error {
+	tx.trackKey(DataStructureSet, bucket, key)
+
+	for item := range tx.setMembers(bucket, key) {
+		if err := tx.put(bucket, key, []byte(item), Persistent, DataDeleteFlag, uint64(time.Now().Unix()), DataStructureSet); err != nil {
+			return err
+		}
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	return tx.SAdd(bucket, key, list...)
+}
+
+// setMembers returns the members of the set in the bucket at given bucket and key as seen inside the tx,
+// the pending writes of the tx are applied on the committed members
+func (tx *Tx) setMembers(bucket string, key []byte) map[string]struct{} {
+	members := make(map[string]struct{})
+	if s, ok := tx.snap.SetIdx[bucket]; ok {
+		for item := range s.Members(string(key)) {
+			members[item] = struct{}{}
+		}
+	}
+
+	for _, e := range tx.pendingWrites {
+		if e.Meta.ds != DataStructureSet || string(e.Meta.bucket) != bucket {
+			continue
+		}
+
+		if e.Meta.Flag == DataDeleteBucketFlag {
+			members = make(map[string]struct{})
+		}
+
+		if string(e.Key) != string(key) {
+			continue
+		}
+
+		if e.Meta.Flag == DataSetFlag {
+			members[string(e.Value)] = struct{}{}
+		}
+
+		if e.Meta.Flag == DataDeleteFlag {
+			delete(members, string(e.Value))
+		}
+	}
+
+	return members
+}
+
+// SMove moves member from the set at source to the set at destination in the bucket at given bucket,key1,key2 and item
+func (tx *Tx) SMove(bucket string, key1, key2, item []byte) (bool, error) {
+	members, err := tx.getSet(bucket, key1)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := members[string(item)]; !ok {
+		return false, nil
+	}
+
+	if err = tx.put(bucket, key1, item, Persistent, DataDeleteFlag, uint64(time.Now().Unix()), DataStructureSet); err != nil {
+		return false, err
+	}
+
+	if err = tx.put(bucket, key2, item, Persistent, DataSetFlag, uint64(time.Now().Unix()), DataStructureSet); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// getSet returns the members of the set in the bucket at given bucket and key as seen inside the tx
+func (tx *Tx) getSet(bucket string, key []byte) (map[string]struct{}, error) {
+	exists, err := tx.keyBucketExists(DataStructureSet, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrBucket
+	}
+
+	members := tx.setMembers(bucket, key)
+	if len(members) == 0 {
+		return nil, ErrSetNotExist
+	}
+
+	return members, nil
+}
+
+// getSets returns the members of the sets at given bucket keys
+func (tx *Tx) getSets(keys []BucketKey) (sets []map[string]struct{}, err error) {
+	if len(keys) == 0 {
+		return nil, set.ErrKeysEmpty
+	}
+
+	for _, bk := range keys {
+		members, err := tx.getSet(bk.Bucket, bk.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		sets = append(sets, members)
+	}
+
+	return
+}
+
+// SScan incrementally iterates the members of the set stored in the bucket at given bucket and key
+// A nil cursor starts a new iteration and a nil next cursor means the iteration is complete
+// See set.SScan for the meaning of match and count
+func (tx *Tx) SScan(bucket string, key, cursor []byte, match string, count int) (next []byte, list [][]byte, err error) {
+	members, err := tx.getSet(bucket, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	next, list = set.Scan(members, cursor, match, count)
+
+	return next, list, nil
+}
@@ -0,0 +1,533 @@
+package nutsdb
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/HelloChenHZ/nutsdb/ds/set"
+)
+
+func TestTx_SetCommands(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.SAdd("bucket", []byte("s"), []byte("a"), []byte("b"), []byte("c")); err != nil {
+			return err
+		}
+
+		return tx.SAdd("bucket", []byte("t"), []byte("x"))
+	})
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.SRem("bucket", []byte("s"), []byte("b")); err != nil {
+			return err
+		}
+
+		moved, err := tx.SMove("bucket", []byte("s"), []byte("t"), []byte("a"))
+		if err != nil || !moved {
+			t.Errorf("SMove returned %v, %v", moved, err)
+		}
+
+		return nil
+	})
+
+	view(t, db, func(tx *Tx) error {
+		members, err := tx.SMembers("bucket", []byte("s"))
+		if err != nil {
+			return err
+		}
+
+		if got := sortedStrings(members); !reflect.DeepEqual(got, []string{"c"}) {
+			t.Errorf("SMembers(s) = %v, want [c]", got)
+		}
+
+		if n, _ := tx.SCard("bucket", []byte("t")); n != 2 {
+			t.Errorf("SCard(t) = %d, want 2", n)
+		}
+
+		if !tx.SIsMember("bucket", []byte("t"), []byte("a")) || tx.SIsMember("bucket", []byte("s"), []byte("a")) {
+			t.Error("SMove did not move the member")
+		}
+
+		if ok, err := tx.SAreMembers("bucket", []byte("t"), []byte("a"), []byte("x")); !ok || err != nil {
+			t.Errorf("SAreMembers returned %v, %v", ok, err)
+		}
+
+		if _, err := tx.SMembers("bucket", []byte("missing")); err != ErrSetNotExist {
+			t.Errorf("SMembers on a missing set returned %v, want ErrSetNotExist", err)
+		}
+
+		if _, err := tx.SMembers("other", []byte("s")); err != ErrBucket {
+			t.Errorf("SMembers on a missing bucket returned %v, want ErrBucket", err)
+		}
+
+		return nil
+	})
+}
+
+func TestTx_SetRollback(t *testing.T) {
+	db := openTestDB(t)
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = tx.SAdd("bucket", []byte("s"), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	view(t, db, func(tx *Tx) error {
+		if tx.SHasKey("bucket", []byte("s")) {
+			t.Error("the rolled back SAdd is visible")
+		}
+
+		return nil
+	})
+}
+
+func TestTx_SetRecovery(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			return tx.SAdd("bucket", []byte("s"), []byte("a"), []byte("b"), []byte("c"))
+		})
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.SRem("bucket", []byte("s"), []byte("a")); err != nil {
+				return err
+			}
+
+			_, err := tx.SMove("bucket", []byte("s"), []byte("t"), []byte("b"))
+
+			return err
+		})
+
+		db = reopenDB(t, db)
+
+		view(t, db, func(tx *Tx) error {
+			s, _ := tx.SMembers("bucket", []byte("s"))
+			u, _ := tx.SMembers("bucket", []byte("t"))
+
+			if got := sortedStrings(s); !reflect.DeepEqual(got, []string{"c"}) {
+				t.Errorf("SMembers(s) after reopen = %v, want [c]", got)
+			}
+
+			if got := sortedStrings(u); !reflect.DeepEqual(got, []string{"b"}) {
+				t.Errorf("SMembers(t) after reopen = %v, want [b]", got)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestTx_SetStore(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.SAdd("bucket", []byte("a"), []byte("1"), []byte("2"), []byte("3")); err != nil {
+				return err
+			}
+
+			if err := tx.SAdd("bucket", []byte("b"), []byte("2"), []byte("3"), []byte("4")); err != nil {
+				return err
+			}
+
+			return tx.SAdd("bucket", []byte("dst"), []byte("old"))
+		})
+
+		update(t, db, func(tx *Tx) error {
+			// the members added in the same tx are overwritten as well
+			if err := tx.SAdd("bucket", []byte("dst"), []byte("pending")); err != nil {
+				return err
+			}
+
+			n, err := tx.SInterStore("bucket", []byte("dst"), BucketKey{"bucket", []byte("a")}, BucketKey{"bucket", []byte("b")})
+			if err != nil {
+				return err
+			}
+
+			if n != 2 {
+				t.Errorf("SInterStore returned %d, want 2", n)
+			}
+
+			if n, err = tx.SUnionStore("bucket", []byte("union"), BucketKey{"bucket", []byte("a")}, BucketKey{"bucket", []byte("b")}); err != nil || n != 4 {
+				t.Errorf("SUnionStore returned %d, %v, want 4", n, err)
+			}
+
+			if err := tx.SAdd("bucket", []byte("empty"), []byte("x")); err != nil {
+				return err
+			}
+
+			// an empty result removes the destination
+			n, err = tx.SDiffStore("bucket", []byte("empty"), BucketKey{"bucket", []byte("a")}, BucketKey{"bucket", []byte("a")})
+			if err != nil || n != 0 {
+				t.Errorf("SDiffStore returned %d, %v, want 0", n, err)
+			}
+
+			return nil
+		})
+
+		check := func(db *DB) {
+			view(t, db, func(tx *Tx) error {
+				members, err := tx.SMembers("bucket", []byte("dst"))
+				if err != nil {
+					return err
+				}
+
+				if got := sortedStrings(members); !reflect.DeepEqual(got, []string{"2", "3"}) {
+					t.Errorf("SMembers(dst) = %v, want [2 3]", got)
+				}
+
+				members, err = tx.SMembers("bucket", []byte("union"))
+				if err != nil {
+					return err
+				}
+
+				if got := sortedStrings(members); !reflect.DeepEqual(got, []string{"1", "2", "3", "4"}) {
+					t.Errorf("SMembers(union) = %v, want [1 2 3 4]", got)
+				}
+
+				if tx.SHasKey("bucket", []byte("empty")) {
+					t.Error("the set stored with no member still exists")
+				}
+
+				return nil
+			})
+		}
+
+		check(db)
+		check(reopenDB(t, db))
+	})
+}
+
+func TestTx_SetRemoveLastMember(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			return tx.SAdd("bucket", []byte("s"), []byte("a"), []byte("b"))
+		})
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.SRem("bucket", []byte("s"), []byte("a"), []byte("b")); err != nil {
+				return err
+			}
+
+			// the tx sees its own removals, so the set is gone
+			if err := tx.SRem("bucket", []byte("s"), []byte("b")); err != ErrSetNotExist {
+				t.Errorf("SRem of a set emptied by the tx returned %v, want ErrSetNotExist", err)
+			}
+
+			return nil
+		})
+
+		db = reopenDB(t, db)
+
+		view(t, db, func(tx *Tx) error {
+			if tx.SHasKey("bucket", []byte("s")) {
+				t.Error("the set without member still exists")
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestTx_SetReadOwnWrites(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		return tx.SAdd("committed", []byte("s"), []byte("a"))
+	})
+
+	update(t, db, func(tx *Tx) error {
+		// a bucket and a set created by the tx
+		if err := tx.SAdd("bucket", []byte("s"), []byte("a"), []byte("b"), []byte("c")); err != nil {
+			return err
+		}
+
+		if list, err := tx.SMembers("bucket", []byte("s")); err != nil || len(list) != 3 {
+			t.Errorf("SMembers = %q, %v, want 3 members", list, err)
+		}
+
+		if err := tx.SRem("bucket", []byte("s"), []byte("c")); err != nil {
+			t.Errorf("SRem of a pending member returned %v", err)
+		}
+
+		if n, err := tx.SCard("bucket", []byte("s")); n != 2 || err != nil {
+			t.Errorf("SCard = %d, %v, want 2", n, err)
+		}
+
+		if !tx.SIsMember("bucket", []byte("s"), []byte("a")) || tx.SIsMember("bucket", []byte("s"), []byte("c")) {
+			t.Error("SIsMember does not see the pending writes")
+		}
+
+		if ok, err := tx.SAreMembers("bucket", []byte("s"), []byte("a"), []byte("b")); !ok || err != nil {
+			t.Errorf("SAreMembers = %v, %v", ok, err)
+		}
+
+		if moved, err := tx.SMove("bucket", []byte("s"), []byte("t"), []byte("b")); !moved || err != nil {
+			t.Errorf("SMove of a pending member returned %v, %v", moved, err)
+		}
+
+		if next, list, err := tx.SScan("bucket", []byte("t"), nil, "", 0); next != nil || len(list) != 1 || err != nil {
+			t.Errorf("SScan = %q, %q, %v, want [b]", next, list, err)
+		}
+
+		if list, err := tx.SUnionByBuckets(BucketKey{"bucket", []byte("s")}, BucketKey{"committed", []byte("s")}); err != nil || len(list) != 1 {
+			t.Errorf("SUnionByBuckets = %q, %v, want [a]", list, err)
+		}
+
+		// a set emptied by the tx
+		if err := tx.SRem("committed", []byte("s"), []byte("a")); err != nil {
+			return err
+		}
+
+		if _, err := tx.SMembers("committed", []byte("s")); err != ErrSetNotExist {
+			t.Errorf("SMembers of an emptied set returned %v, want ErrSetNotExist", err)
+		}
+
+		return nil
+	})
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.DeleteBucket(DataStructureSet, "bucket"); err != nil {
+			return err
+		}
+
+		if _, err := tx.SMembers("bucket", []byte("s")); err != ErrBucket {
+			t.Errorf("SMembers of a deleted bucket returned %v, want ErrBucket", err)
+		}
+
+		if err := tx.SAdd("bucket", []byte("s"), []byte("x")); err != nil {
+			return err
+		}
+
+		// the members of the deleted bucket are gone
+		if list, err := tx.SMembers("bucket", []byte("s")); err != nil || len(list) != 1 || string(list[0]) != "x" {
+			t.Errorf("SMembers of a recreated bucket = %q, %v, want [x]", list, err)
+		}
+
+		return nil
+	})
+
+	view(t, db, func(tx *Tx) error {
+		if list, err := tx.SMembers("bucket", []byte("s")); err != nil || len(list) != 1 || string(list[0]) != "x" {
+			t.Errorf("SMembers after the commit = %q, %v, want [x]", list, err)
+		}
+
+		return nil
+	})
+}
+
+func TestTx_SPop(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		opt.RandSeed = 7
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			return tx.SAdd("bucket", []byte("s"), []byte("a"), []byte("b"), []byte("c"), []byte("d"))
+		})
+
+		var popped [][]byte
+		update(t, db, func(tx *Tx) (err error) {
+			popped, err = tx.SPop("bucket", []byte("s"), 3)
+			return err
+		})
+
+		if len(popped) != 3 {
+			t.Fatalf("SPop returned %d members, want 3", len(popped))
+		}
+
+		db = reopenDB(t, db)
+
+		view(t, db, func(tx *Tx) error {
+			if n, _ := tx.SCard("bucket", []byte("s")); n != 1 {
+				t.Errorf("SCard after SPop = %d, want 1", n)
+			}
+
+			for _, item := range popped {
+				if tx.SIsMember("bucket", []byte("s"), item) {
+					t.Errorf("the popped member %s is still in the set", item)
+				}
+			}
+
+			if _, err := tx.SPop("bucket", []byte("s"), -1); err != set.ErrCount {
+				t.Errorf("SPop with a negative count returned %v, want ErrCount", err)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestTx_SPopPending(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		return tx.SAdd("bucket", []byte("s"), []byte("a"), []byte("b"), []byte("c"))
+	})
+
+	update(t, db, func(tx *Tx) error {
+		// the members popped by the tx are not sampled again
+		seen := make(map[string]bool)
+		for _, count := range []int{1, 2} {
+			list, err := tx.SPop("bucket", []byte("s"), count)
+			if err != nil || len(list) != count {
+				t.Fatalf("SPop(%d) returned %q, %v", count, list, err)
+			}
+
+			for _, item := range list {
+				if seen[string(item)] {
+					t.Errorf("SPop returned %s twice", item)
+				}
+				seen[string(item)] = true
+			}
+		}
+
+		if len(tx.pendingWrites) != 3 {
+			t.Errorf("the pops wrote %d entries, want 3", len(tx.pendingWrites))
+		}
+
+		if _, err := tx.SRandMember("bucket", []byte("s"), 1); err != ErrSetNotExist {
+			t.Errorf("SRandMember of a popped set returned %v, want ErrSetNotExist", err)
+		}
+
+		return nil
+	})
+
+	view(t, db, func(tx *Tx) error {
+		if tx.SHasKey("bucket", []byte("s")) {
+			t.Error("the set is not removed once all its members are popped")
+		}
+
+		return nil
+	})
+}
+
+func TestTx_SRandMemberConcurrent(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		return tx.SAdd("bucket", []byte("s"), []byte("a"), []byte("b"), []byte("c"))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				tx, err := db.Begin(false)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				list, err := tx.SRandMember("bucket", []byte("s"), -2)
+				if err != nil || len(list) != 2 {
+					t.Errorf("SRandMember returned %d members, %v", len(list), err)
+				}
+
+				_ = tx.Rollback()
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestTx_SScanConcurrentWrites(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		for i := 0; i < 200; i++ {
+			if err := tx.SAdd("bucket", []byte("s"), []byte(fmt.Sprintf("k%03d", i))); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		// the members written concurrently are not present for the full scan
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			item := []byte(fmt.Sprintf("k%03d.%d", i%200, i))
+			err := db.UpdateWithRetry(1, func(tx *Tx) error {
+				return tx.SAdd("bucket", []byte("s"), item)
+			})
+
+			if err == nil {
+				err = db.UpdateWithRetry(1, func(tx *Tx) error {
+					return tx.SRem("bucket", []byte("s"), item)
+				})
+			}
+
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	seen := make(map[string]int)
+	var cursor []byte
+	for {
+		var next []byte
+		view(t, db, func(tx *Tx) error {
+			var (
+				list	[][]byte
+				err		error
+			)
+
+			next, list, err = tx.SScan("bucket", []byte("s"), cursor, "k???", 9)
+			for _, item := range list {
+				seen[string(item)]++
+			}
+
+			return err
+		})
+
+		if next == nil {
+			break
+		}
+
+		cursor = next
+	}
+
+	close(done)
+	wg.Wait()
+
+	if len(seen) != 200 {
+		t.Errorf("SScan returned %d distinct members, want 200", len(seen))
+	}
+
+	for item, n := range seen {
+		if n != 1 {
+			t.Errorf("member %s returned %d times, want 1", item, n)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package nutsdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/HelloChenHZ/nutsdb/ds/stream"
+	"github.com/bwmarrin/snowflake"
+)
+
+// ErrStreamValue is returned when the value of the stream entry is malformed
+var ErrStreamValue = errors.New("err stream value")
+
+// XAdd appends value to the stream stored in the bucket at given bucket and key, the stream is created if it not exists
+// It returns the ID of the new entry, a snowflake ID greater than all the IDs ever added to the stream
+func (tx *Tx) XAdd(bucket string, key, value []byte) (uint64, error) {
+	if err := tx.checkTxIsClosed(); err != nil {
+		return 0, err
+	}
+
+	last, err := tx.streamLastID(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	id := uint64(tx.db.idNode.Generate().Int64())
+	if id <= last {
+		id = last + 1
+	}
+
+	return id, tx.put(bucket, key, encodeStreamEntry(id, value), Persistent, DataXAddFlag, uint64(time.Now().Unix()), DataStructureStream)
+}
+
+// XLen returns the number of entries of the stream stored in the bucket at given bucket and key
+func (tx *Tx) XLen(bucket string, key []byte) (int, error) {
+	s, err := tx.getStream(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.XLen(string(key))
+}
+
+// XRange returns the entries with an ID between start and end inclusive of the stream stored in the bucket
+// at given bucket and key, if count is greater than 0, at most count entries are returned
+func (tx *Tx) XRange(bucket string, key []byte, start, end uint64, count int) ([]*stream.Entry, error) {
+	s, err := tx.getStream(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.XRange(string(key), start, end, count)
+}
+
+// XRevRange returns the entries with an ID between end and start inclusive of the stream stored in the bucket
+// at given bucket and key, from the greatest ID to the smallest one
+func (tx *Tx) XRevRange(bucket string, key []byte, end, start uint64, count int) ([]*stream.Entry, error) {
+	s, err := tx.getStream(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.XRevRange(string(key), end, start, count)
+}
+
+// XRead returns the entries with an ID greater than after of the stream stored in the bucket at given bucket and key
+func (tx *Tx) XRead(bucket string, key []byte, after uint64, count int) ([]*stream.Entry, error) {
+	s, err := tx.getStream(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.XRead(string(key), after, count)
+}
+
+// XTrimMaxLen removes the oldest entries of the stream stored in the bucket at given bucket and key
+// so that it has at most maxLen entries, it returns the number of the removed entries
+func (tx *Tx) XTrimMaxLen(bucket string, key []byte, maxLen int) (int, error) {
+	s, err := tx.getStream(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	minID, err := s.MinIDForMaxLen(string(key), maxLen)
+	if err != nil {
+		return 0, err
+	}
+
+	return tx.XTrimMinID(bucket, key, minID)
+}
+
+// XTrimMaxAge removes the entries older than maxAge of the stream stored in the bucket at given bucket and key
+// It returns the number of the removed entries
+func (tx *Tx) XTrimMaxAge(bucket string, key []byte, maxAge time.Duration) (int, error) {
+	return tx.XTrimMinID(bucket, key, StreamIDAt(time.Now().Add(-maxAge)))
+}
+
+// XTrimMinID removes the entries with an ID smaller than minID of the stream stored in the bucket at given bucket and key
+// It returns the number of the removed entries
+func (tx *Tx) XTrimMinID(bucket string, key []byte, minID uint64) (int, error) {
+	s, err := tx.getStream(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if minID == 0 {
+		return 0, nil
+	}
+
+	entries, err := s.XRange(string(key), 0, minID-1, 0)
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, minID)
+
+	return len(entries), tx.put(bucket, key, buf, Persistent, DataXTrimFlag, uint64(time.Now().Unix()), DataStructureStream)
+}
+
+// XGroupCreate creates the consumer group of the stream stored in the bucket at given bucket and key,
+// the entries with an ID greater than startID will be delivered to the group
+// Use 0 to deliver the whole stream and stream.LastID to deliver only the entries added from now on
+func (tx *Tx) XGroupCreate(bucket string, key []byte, group string, startID uint64) error {
+	s, err := tx.getStream(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.GetGroup(string(key), group); err == nil {
+		return stream.ErrGroupExists
+	}
+
+	if startID == stream.LastID {
+		startID = s.M[string(key)].LastID
+	}
+
+	return tx.putGroupOp(bucket, key, DataXGroupCreateFlag, group, "", 0, startID)
+}
+
+// XGroupDestroy removes the consumer group of the stream stored in the bucket at given bucket and key
+func (tx *Tx) XGroupDestroy(bucket string, key []byte, group string) error {
+	if _, err := tx.getGroup(bucket, key, group); err != nil {
+		return err
+	}
+
+	return tx.putGroupOp(bucket, key, DataXGroupDestroyFlag, group, "", 0)
+}
+
+// XReadGroup delivers to the consumer at most count entries of the stream stored in the bucket at given bucket and key
+// that were never delivered to the group, the entries are pending until they are acknowledged by XAck
+// The deliveries made earlier in the same tx are taken into account, so an entry is never delivered twice
+func (tx *Tx) XReadGroup(bucket string, key []byte, group, consumer string, count int) ([]*stream.Entry, error) {
+	s, err := tx.getStream(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := s.GetGroup(string(key), group)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.XRead(string(key), g.LastDeliveredID, count)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	ids := make([]uint64, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+
+	return entries, tx.putGroupOp(bucket, key, DataXReadGroupFlag, group, consumer, time.Now().UnixNano()/int64(time.Millisecond), ids...)
+}
+
+// XAck acknowledges the pending entries of the group with the given IDs,
+// it returns the number of the entries that were pending
+func (tx *Tx) XAck(bucket string, key []byte, group string, ids ...uint64) (int, error) {
+	g, err := tx.getGroup(bucket, key, group)
+	if err != nil {
+		return 0, err
+	}
+
+	var acked []uint64
+	for _, id := range ids {
+		if _, ok := g.Pending[id]; ok {
+			acked = append(acked, id)
+		}
+	}
+
+	if len(acked) == 0 {
+		return 0, nil
+	}
+
+	return len(acked), tx.putGroupOp(bucket, key, DataXAckFlag, group, "", 0, acked...)
+}
+
+// XPending returns the pending entries of the group sorted by ID,
+// if consumer is not empty, only the entries delivered to the consumer are returned
+func (tx *Tx) XPending(bucket string, key []byte, group, consumer string) ([]*stream.PendingEntry, error) {
+	s, err := tx.getStream(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.XPending(string(key), group, consumer)
+}
+
+// StreamIDAt returns the smallest stream ID that can be generated at given time
+func StreamIDAt(t time.Time) uint64 {
+	ms := t.UnixNano()/int64(time.Millisecond) - snowflake.Epoch
+	if ms < 0 {
+		return 0
+	}
+
+	return uint64(ms) << (snowflake.NodeBits + snowflake.StepBits)
+}
+
+// StreamIDTime returns the time the stream ID was generated at
+func StreamIDTime(id uint64) time.Time {
+	ms := int64(id>>(snowflake.NodeBits+snowflake.StepBits)) + snowflake.Epoch
+
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// streamLastID returns the greatest ID of the stream stored in the bucket at given bucket and key,
+// the entries added earlier in the same tx are taken into account
+func (tx *Tx) streamLastID(bucket string, key []byte) (uint64, error) {
+	tx.trackKey(DataStructureStream, bucket, key)
+
+	s, err := tx.streamView(bucket)
+	if err != nil || s == nil || !s.HasKey(string(key)) {
+		return 0, err
+	}
+
+	return s.M[string(key)].LastID, nil
+}
+
+// putGroupOp logs the consumer group operation
+func (tx *Tx) putGroupOp(bucket string, key []byte, flag uint16, group, consumer string, deliveryTime int64, ids ...uint64) error {
+	op := &streamGroupOp{group: group, consumer: consumer, time: deliveryTime, ids: ids}
+
+	return tx.put(bucket, key, op.encode(), Persistent, flag, uint64(time.Now().Unix()), DataStructureStream)
+}
+
+// getStream returns the streams of the bucket as seen inside the tx, if the stream at given key exists
+func (tx *Tx) getStream(bucket string, key []byte) (*stream.Stream, error) {
+	exists, err := tx.keyBucketExists(DataStructureStream, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrBucket
+	}
+
+	s, err := tx.streamView(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if s == nil || !s.HasKey(string(key)) {
+		return nil, stream.ErrStreamNotFound
+	}
+
+	return s, nil
+}
+
+// streamView returns the streams of the bucket as seen inside the tx, nil if the bucket not exists
+// The committed streams are returned until the tx writes them, then the pending writes are applied on a copy of them,
+// the copy is kept by the tx so that only the later writes are applied at the next call
+func (tx *Tx) streamView(bucket string) (*stream.Stream, error) {
+	v, ok := tx.streams[bucket]
+	if !ok {
+		if len(tx.bucketWrites(DataStructureStream, bucket, 0)) == 0 {
+			return tx.snap.StreamIdx[bucket], nil
+		}
+
+		v = &txView{idx: stream.New()}
+		if s, ok := tx.snap.StreamIdx[bucket]; ok {
+			v.idx = s.Clone()
+		}
+
+		if tx.streams == nil {
+			tx.streams = make(map[string]*txView)
+		}
+
+		tx.streams[bucket] = v
+	}
+
+	for _, e := range tx.bucketWrites(DataStructureStream, bucket, v.applied) {
+		switch e.Meta.Flag {
+		case DataDeleteBucketFlag:
+			v.idx = stream.New()
+		case DataCreateBucketFlag:
+		default:
+			if err := applyStreamEntry(v.idx.(*stream.Stream), e); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	v.applied = len(tx.pendingWrites)
+
+	return v.idx.(*stream.Stream), nil
+}
+
+// getGroup returns the consumer group of the stream stored in the bucket at given bucket and key
+func (tx *Tx) getGroup(bucket string, key []byte, group string) (*stream.Group, error) {
+	s, err := tx.getStream(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(string(key), group)
+}
+
+// encodeStreamEntry returns the value of the XAdd entry
+//
+//  the XAdd entry value format:
+//  |-------------------|
+//  |   ID   |  value   |
+//  |-------------------|
+//  | uint64 |  []byte  |
+//  |-------------------|
+//
+func encodeStreamEntry(id uint64, value []byte) []byte {
+	buf := make([]byte, 8+len(value))
+	binary.LittleEndian.PutUint64(buf[0:8], id)
+	copy(buf[8:], value)
+
+	return buf
+}
+
+// decodeStreamEntry returns the ID and the value at given XAdd entry value
+func decodeStreamEntry(buf []byte) (id uint64, value []byte, err error) {
+	if len(buf) < 8 {
+		return 0, nil, ErrStreamValue
+	}
+
+	return binary.LittleEndian.Uint64(buf[0:8]), buf[8:], nil
+}
+
+// streamGroupOp represents a logged consumer group operation
+type streamGroupOp struct {
+	group		string
+	consumer	string
+	time		int64
+	ids			[]uint64
+}
+
+// encode returns the value of the consumer group entry
+//
+//  the consumer group entry value format:
+//  |--------------------------------------------------------------------|
+//  | groupSize | group  | consumerSize | consumer |  time  |  IDs       |
+//  |--------------------------------------------------------------------|
+//  |  uint32   | []byte |    uint32    |  []byte  | int64  | []uint64   |
+//  |--------------------------------------------------------------------|
+//
+func (op *streamGroupOp) encode() []byte {
+	buf := make([]byte, 4+len(op.group)+4+len(op.consumer)+8+8*len(op.ids))
+
+	off := 0
+	binary.LittleEndian.PutUint32(buf[off:], uint32(len(op.group)))
+	off += 4
+	off += copy(buf[off:], op.group)
+	binary.LittleEndian.PutUint32(buf[off:], uint32(len(op.consumer)))
+	off += 4
+	off += copy(buf[off:], op.consumer)
+	binary.LittleEndian.PutUint64(buf[off:], uint64(op.time))
+	off += 8
+
+	for _, id := range op.ids {
+		binary.LittleEndian.PutUint64(buf[off:], id)
+		off += 8
+	}
+
+	return buf
+}
+
+// decodeStreamGroupOp returns the consumer group operation at given consumer group entry value
+func decodeStreamGroupOp(buf []byte) (*streamGroupOp, error) {
+	op := &streamGroupOp{}
+
+	var err error
+	if op.group, buf, err = decodeStreamString(buf); err != nil {
+		return nil, err
+	}
+
+	if op.consumer, buf, err = decodeStreamString(buf); err != nil {
+		return nil, err
+	}
+
+	if len(buf) < 8 || (len(buf)-8)%8 != 0 {
+		return nil, ErrStreamValue
+	}
+
+	op.time = int64(binary.LittleEndian.Uint64(buf[0:8]))
+	for off := 8; off < len(buf); off += 8 {
+		op.ids = append(op.ids, binary.LittleEndian.Uint64(buf[off:]))
+	}
+
+	return op, nil
+}
+
+// decodeStreamString returns the size prefixed string at the head of buf and the rest of buf
+func decodeStreamString(buf []byte) (string, []byte, error) {
+	if len(buf) < 4 {
+		return "", nil, ErrStreamValue
+	}
+
+	size := int(binary.LittleEndian.Uint32(buf[0:4]))
+	if len(buf) < 4+size {
+		return "", nil, ErrStreamValue
+	}
+
+	return string(buf[4 : 4+size]), buf[4+size:], nil
+}
@@ -0,0 +1,299 @@
+package nutsdb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/HelloChenHZ/nutsdb/ds/stream"
+)
+
+// streamIDs returns the IDs of the stream entries
+func streamIDs(entries []*stream.Entry) []uint64 {
+	list := make([]uint64, len(entries))
+	for i, e := range entries {
+		list[i] = e.ID
+	}
+
+	return list
+}
+
+func TestTx_XAdd(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		var added []uint64
+		for i := 0; i < 3; i++ {
+			update(t, db, func(tx *Tx) error {
+				// the IDs generated in a same tx keep increasing
+				for j := 0; j < 2; j++ {
+					id, err := tx.XAdd("bucket", []byte("s"), []byte{byte(len(added))})
+					if err != nil {
+						return err
+					}
+
+					if len(added) > 0 && id <= added[len(added)-1] {
+						t.Errorf("XAdd returned %d after %d", id, added[len(added)-1])
+					}
+
+					added = append(added, id)
+				}
+
+				return nil
+			})
+		}
+
+		if at := StreamIDTime(added[0]); time.Since(at) > time.Minute || at.After(time.Now()) {
+			t.Errorf("StreamIDTime = %v, want about now", at)
+		}
+
+		update(t, db, func(tx *Tx) error {
+			n, err := tx.XTrimMaxLen("bucket", []byte("s"), 4)
+			if n != 2 || err != nil {
+				t.Errorf("XTrimMaxLen = %d, %v, want 2", n, err)
+			}
+
+			return nil
+		})
+
+		check := func(db *DB) {
+			view(t, db, func(tx *Tx) error {
+				entries, err := tx.XRange("bucket", []byte("s"), 0, stream.LastID, 0)
+				if err != nil {
+					return err
+				}
+
+				if !reflect.DeepEqual(streamIDs(entries), added[2:]) || entries[0].Value[0] != 2 {
+					t.Errorf("XRange = %v, want %v", streamIDs(entries), added[2:])
+				}
+
+				if entries, _ = tx.XRevRange("bucket", []byte("s"), stream.LastID, 0, 1); !reflect.DeepEqual(streamIDs(entries), added[5:]) {
+					t.Errorf("XRevRange = %v, want %v", streamIDs(entries), added[5:])
+				}
+
+				if entries, _ = tx.XRead("bucket", []byte("s"), added[3], 0); !reflect.DeepEqual(streamIDs(entries), added[4:]) {
+					t.Errorf("XRead = %v, want %v", streamIDs(entries), added[4:])
+				}
+
+				if _, err = tx.XLen("bucket", []byte("missing")); err != stream.ErrStreamNotFound {
+					t.Errorf("XLen of a missing stream returned %v, want ErrStreamNotFound", err)
+				}
+
+				return nil
+			})
+		}
+
+		check(db)
+		db = reopenDB(t, db)
+		check(db)
+
+		// the IDs stay monotonic after the recovery, even for a trimmed stream
+		update(t, db, func(tx *Tx) error {
+			if _, err := tx.XTrimMaxLen("bucket", []byte("s"), 0); err != nil {
+				return err
+			}
+
+			id, err := tx.XAdd("bucket", []byte("s"), nil)
+			if id <= added[len(added)-1] {
+				t.Errorf("XAdd after reopen returned %d, want more than %d", id, added[len(added)-1])
+			}
+
+			return err
+		})
+	})
+}
+
+func TestTx_XReadGroup(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		var added []uint64
+		update(t, db, func(tx *Tx) error {
+			for i := 0; i < 5; i++ {
+				id, err := tx.XAdd("bucket", []byte("s"), []byte{byte(i)})
+				if err != nil {
+					return err
+				}
+
+				added = append(added, id)
+			}
+
+			return nil
+		})
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.XGroupCreate("bucket", []byte("s"), "g", 0); err != nil {
+				return err
+			}
+
+			if err := tx.XGroupCreate("bucket", []byte("s"), "g", 0); err != stream.ErrGroupExists {
+				t.Errorf("XGroupCreate of a pending group returned %v, want ErrGroupExists", err)
+			}
+
+			return tx.XGroupCreate("bucket", []byte("s"), "late", stream.LastID)
+		})
+
+		update(t, db, func(tx *Tx) error {
+			// the deliveries made earlier in the tx are skipped
+			first, err := tx.XReadGroup("bucket", []byte("s"), "g", "alice", 2)
+			if err != nil {
+				return err
+			}
+
+			second, err := tx.XReadGroup("bucket", []byte("s"), "g", "bob", 2)
+			if err != nil {
+				return err
+			}
+
+			if !reflect.DeepEqual(streamIDs(first), added[:2]) || !reflect.DeepEqual(streamIDs(second), added[2:4]) {
+				t.Errorf("XReadGroup = %v then %v", streamIDs(first), streamIDs(second))
+			}
+
+			if entries, _ := tx.XReadGroup("bucket", []byte("s"), "late", "alice", 0); len(entries) != 0 {
+				t.Errorf("XReadGroup of a group created at the last ID = %v", streamIDs(entries))
+			}
+
+			return nil
+		})
+
+		update(t, db, func(tx *Tx) error {
+			n, err := tx.XAck("bucket", []byte("s"), "g", added[0], added[4])
+			if n != 1 || err != nil {
+				t.Errorf("XAck = %d, %v, want 1", n, err)
+			}
+
+			return tx.XGroupDestroy("bucket", []byte("s"), "late")
+		})
+
+		check := func(db *DB) {
+			view(t, db, func(tx *Tx) error {
+				pending, err := tx.XPending("bucket", []byte("s"), "g", "")
+				if err != nil {
+					return err
+				}
+
+				got := []uint64{}
+				for _, pe := range pending {
+					got = append(got, pe.ID)
+				}
+
+				if !reflect.DeepEqual(got, added[1:4]) || pending[0].Consumer != "alice" || pending[1].Consumer != "bob" {
+					t.Errorf("XPending = %v, want %v", got, added[1:4])
+				}
+
+				if pending, _ = tx.XPending("bucket", []byte("s"), "g", "bob"); len(pending) != 2 {
+					t.Errorf("XPending of bob returned %d entries, want 2", len(pending))
+				}
+
+				if _, err = tx.XPending("bucket", []byte("s"), "late", ""); err != stream.ErrGroupNotFound {
+					t.Errorf("XPending of a destroyed group returned %v, want ErrGroupNotFound", err)
+				}
+
+				return nil
+			})
+		}
+
+		check(db)
+		db = reopenDB(t, db)
+		check(db)
+
+		update(t, db, func(tx *Tx) error {
+			entries, err := tx.XReadGroup("bucket", []byte("s"), "g", "alice", 0)
+			if !reflect.DeepEqual(streamIDs(entries), added[4:]) {
+				t.Errorf("XReadGroup after reopen = %v, want %v", streamIDs(entries), added[4:])
+			}
+
+			return err
+		})
+	})
+}
+
+func TestTx_StreamReadOwnWrites(t *testing.T) {
+	db := openTestDB(t)
+
+	var ids []uint64
+	update(t, db, func(tx *Tx) error {
+		for i := 0; i < 3; i++ {
+			id, err := tx.XAdd("bucket", []byte("s"), []byte{byte(i)})
+			if err != nil {
+				return err
+			}
+
+			ids = append(ids, id)
+		}
+
+		if n, err := tx.XLen("bucket", []byte("s")); err != nil || n != 3 {
+			t.Errorf("XLen = %d, %v, want 3", n, err)
+		}
+
+		if entries, _ := tx.XRange("bucket", []byte("s"), 0, stream.LastID, 0); !reflect.DeepEqual(streamIDs(entries), ids) {
+			t.Errorf("XRange = %v, want %v", streamIDs(entries), ids)
+		}
+
+		// the group of a stream created in the tx
+		if err := tx.XGroupCreate("bucket", []byte("s"), "g", 0); err != nil {
+			t.Errorf("XGroupCreate after XAdd returned %v", err)
+		}
+
+		if err := tx.XGroupCreate("bucket", []byte("s"), "g", 0); err != stream.ErrGroupExists {
+			t.Errorf("XGroupCreate twice returned %v, want ErrGroupExists", err)
+		}
+
+		entries, err := tx.XReadGroup("bucket", []byte("s"), "g", "c", 2)
+		if err != nil || !reflect.DeepEqual(streamIDs(entries), ids[:2]) {
+			t.Errorf("XReadGroup = %v, %v, want %v", streamIDs(entries), err, ids[:2])
+		}
+
+		// the entries delivered in the tx are pending
+		if n, err := tx.XAck("bucket", []byte("s"), "g", ids[0]); err != nil || n != 1 {
+			t.Errorf("XAck = %d, %v, want 1", n, err)
+		}
+
+		if pending, _ := tx.XPending("bucket", []byte("s"), "g", ""); len(pending) != 1 || pending[0].ID != ids[1] {
+			t.Errorf("XPending = %v, want the entry %d", pending, ids[1])
+		}
+
+		if n, err := tx.XTrimMinID("bucket", []byte("s"), ids[1]); err != nil || n != 1 {
+			t.Errorf("XTrimMinID = %d, %v, want 1", n, err)
+		}
+
+		if n, _ := tx.XLen("bucket", []byte("s")); n != 2 {
+			t.Errorf("XLen after XTrimMinID = %d, want 2", n)
+		}
+
+		return nil
+	})
+
+	update(t, db, func(tx *Tx) error {
+		// the streams removed with the bucket
+		if err := tx.DeleteBucket(DataStructureStream, "bucket"); err != nil {
+			return err
+		}
+
+		if _, err := tx.XLen("bucket", []byte("s")); err != ErrBucket {
+			t.Errorf("XLen of a deleted bucket returned %v, want ErrBucket", err)
+		}
+
+		if _, err := tx.XAdd("bucket", []byte("s"), nil); err != nil {
+			return err
+		}
+
+		if n, err := tx.XLen("bucket", []byte("s")); err != nil || n != 1 {
+			t.Errorf("XLen after DeleteBucket = %d, %v, want 1", n, err)
+		}
+
+		if _, err := tx.XPending("bucket", []byte("s"), "g", ""); err != stream.ErrGroupNotFound {
+			t.Errorf("XPending after DeleteBucket returned %v, want ErrGroupNotFound", err)
+		}
+
+		return nil
+	})
+
+	view(t, db, func(tx *Tx) error {
+		if n, err := tx.XLen("bucket", []byte("s")); err != nil || n != 1 {
+			t.Errorf("XLen after commit = %d, %v, want 1", n, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,140 @@
+package nutsdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+// dataFiles returns the number of the data files of the db
+func dataFiles(t *testing.T, db *DB) int {
+	t.Helper()
+
+	files, err := ioutil.ReadDir(db.opt.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for _, f := range files {
+		if path.Ext(f.Name()) == DataSuffix {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestTx_PendingLimits(t *testing.T) {
+	opt := testOptions(t)
+	opt.MaxTxPendingCount = 3
+	opt.MaxTxPendingSize = 3 * (DataEntryHeaderSize + int64(len("bucket")) + 2 + 10)
+	db := openDB(t, opt)
+
+	tx := beginWritable(t, db)
+
+	for i := 0; i < 3; i++ {
+		if err := tx.Put("bucket", []byte(fmt.Sprintf("k%d", i)), make([]byte, 10), Persistent); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tx.Put("bucket", []byte("k3"), nil, Persistent); err != ErrTxnTooBig {
+		t.Errorf("Put over MaxTxPendingCount returned %v, want ErrTxnTooBig", err)
+	}
+
+	_ = tx.Rollback()
+
+	opt.Dir, opt.MaxTxPendingCount = t.TempDir(), 0
+	db = openDB(t, opt)
+
+	tx = beginWritable(t, db)
+	for i := 0; i < 2; i++ {
+		if err := tx.Put("bucket", []byte(fmt.Sprintf("k%d", i)), make([]byte, 10), Persistent); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tx.Put("bucket", []byte("k2"), make([]byte, 11), Persistent); err != ErrTxnTooBig {
+		t.Errorf("Put over MaxTxPendingSize returned %v, want ErrTxnTooBig", err)
+	}
+
+	// the rejected entry is not pending, a smaller one still fits
+	if err := tx.Put("bucket", []byte("k2"), make([]byte, 10), Persistent); err != nil {
+		t.Errorf("Put at MaxTxPendingSize returned %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTx_SpanSegments(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		opt.SegmentSize = 1024
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			for i := 0; i < 50; i++ {
+				if err := tx.Put("bucket", []byte(fmt.Sprintf("k%02d", i)), make([]byte, 50), Persistent); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if n := dataFiles(t, db); n < 5 {
+			t.Errorf("the tx was written in %d data files, want at least 5", n)
+		}
+
+		update(t, db, func(tx *Tx) error {
+			return tx.Put("bucket", []byte("after"), []byte("1"), Persistent)
+		})
+
+		// an entry larger than a segment never fits
+		err := db.UpdateWithRetry(1, func(tx *Tx) error {
+			return tx.Put("bucket", []byte("huge"), make([]byte, 1024), Persistent)
+		})
+		if err != ErrKeyAndValSize {
+			t.Errorf("Commit of an entry larger than a segment returned %v, want ErrKeyAndValSize", err)
+		}
+
+		check := func(db *DB) {
+			view(t, db, func(tx *Tx) error {
+				for i := 0; i < 50; i++ {
+					if e, err := tx.Get("bucket", []byte(fmt.Sprintf("k%02d", i))); err != nil || len(e.Value) != 50 {
+						t.Fatalf("Get(k%02d) = %v, %v", i, e, err)
+					}
+				}
+
+				if got := getValue(tx, "bucket", "after"); got != "1" {
+					t.Errorf("Get(after) = %q, want 1", got)
+				}
+
+				return nil
+			})
+		}
+
+		check(db)
+		db = reopenDB(t, db)
+		check(db)
+
+		// the writes go on in the last segment after the recovery
+		update(t, db, func(tx *Tx) error {
+			return tx.Put("bucket", []byte("reopened"), []byte("1"), Persistent)
+		})
+
+		db = reopenDB(t, db)
+		check(db)
+
+		view(t, db, func(tx *Tx) error {
+			if got := getValue(tx, "bucket", "reopened"); got != "1" {
+				t.Errorf("Get(reopened) = %q, want 1", got)
+			}
+
+			return nil
+		})
+	})
+}
@@ -0,0 +1,536 @@
+package nutsdb
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HelloChenHZ/nutsdb/ds/zset"
+	"github.com/xujiajun/utils/strconv2"
+)
+
+// ErrZSetKey is returned when the key of the ZAdd entry is malformed
+var ErrZSetKey = errors.New("err zset key")
+
+// ZAdd adds the specified member key with the specified score and specified val to the sorted set stored at bucket
+// zset.ErrScoreNaN is returned if score is NaN
+func (tx *Tx) ZAdd(bucket string, key []byte, score float64, val []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+
+	if math.IsNaN(score) {
+		return zset.ErrScoreNaN
+	}
+
+	return tx.put(bucket, encodeZSetKey(key, score), val, Persistent, DataZAddFlag, uint64(time.Now().Unix()), DataStructureSortedSet)
+}
+
+// ZAddWithOptions adds the specified member key with the specified score and specified val
+// to the sorted set stored at bucket under the given options (NX, XX, GT, LT and CH)
+// It returns the number of the added members, or the changed members if CH is set
+func (tx *Tx) ZAddWithOptions(bucket string, key []byte, score float64, val []byte, opts *zset.ZAddOptions) (int, error) {
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+
+	if math.IsNaN(score) {
+		return 0, zset.ErrScoreNaN
+	}
+
+	exists, current, _, err := tx.zsetMember(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	write, count := opts.Check(exists, zset.SCORE(current), zset.SCORE(score))
+	if !write {
+		return 0, nil
+	}
+
+	return count, tx.ZAdd(bucket, key, score, val)
+}
+
+// ZIncrBy increments the score of member in the sorted set stored at bucket by increment
+// If member does not exist in the sorted set, it is added with increment as its score
+// The increments made earlier in the same tx are taken into account. The sorted set is read by the tx,
+// so the tx fails to commit with ErrConflict if another tx writes it first, and no increment is lost
+// when the tx is retried, see DB.UpdateWithRetry
+// zset.ErrScoreNaN is returned if increment is NaN or the new score would be NaN
+func (tx *Tx) ZIncrBy(bucket string, key []byte, increment float64) (float64, error) {
+	if math.IsNaN(increment) {
+		return 0, zset.ErrScoreNaN
+	}
+
+	_, score, val, err := tx.zsetMember(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	score += increment
+	if math.IsNaN(score) {
+		return 0, zset.ErrScoreNaN
+	}
+
+	return score, tx.ZAdd(bucket, key, score, val)
+}
+
+// zsetMember returns if the member exists in the sorted set stored at bucket, its score and value
+// The pending writes of the tx are taken into account
+func (tx *Tx) zsetMember(bucket string, key []byte) (exists bool, score float64, val []byte, err error) {
+	if err = tx.checkTxIsClosed(); err != nil {
+		return
+	}
+
+	tx.trackBucket(DataStructureSortedSet, bucket)
+
+	ss, err := tx.sortedSetView(bucket)
+	if err != nil || ss == nil {
+		return false, 0, nil, err
+	}
+
+	if node := ss.GetByKey(string(key)); node != nil {
+		return true, float64(node.Score()), node.Value, nil
+	}
+
+	return false, 0, nil, nil
+}
+
+// ZMembers returns all the members of the set value stored at bucket
+func (tx *Tx) ZMembers(bucket string) (map[string]*zset.SortedSetNode, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.Members(), nil
+}
+
+// ZCard returns the sorted set cardinality (number of elements) of the sorted set stored at bucket
+func (tx *Tx) ZCard(bucket string) (int, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	return ss.Size(), nil
+}
+
+// ZRangeByRank returns all the elements in the sorted set in one bucket and key
+// with a rank between start and end (including elements with rank equal to start or end)
+// Note that the rank is 1-based integer. Rank 1 means the first node; Rank -1 means the last node
+func (tx *Tx) ZRangeByRank(bucket string, start, end int) ([]*zset.SortedSetNode, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.GetByRankRange(start, end, false), nil
+}
+
+// ZRem removes the member at given bucket and key
+func (tx *Tx) ZRem(bucket string, key []byte) error {
+	if _, err := tx.getSortedSet(bucket); err != nil {
+		return err
+	}
+
+	exists, _, _, err := tx.zsetMember(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNotFoundKey
+	}
+
+	return tx.put(bucket, key, nil, Persistent, DataZRemFlag, uint64(time.Now().Unix()), DataStructureSortedSet)
+}
+
+// ZRemRangeByRank removes all elements in the sorted set stored at bucket with rank between start and end
+// The rank is 1-based integer. Rank 1 means the first node; Rank -1 means the last node
+func (tx *Tx) ZRemRangeByRank(bucket string, start, end int) error {
+	if _, err := tx.getSortedSet(bucket); err != nil {
+		return err
+	}
+
+	startStr := []byte(strconv2.IntToStr(start))
+	endStr := []byte(strconv2.IntToStr(end))
+
+	return tx.put(bucket, startStr, endStr, Persistent, DataZRemRangeByRankFlag, uint64(time.Now().Unix()), DataStructureSortedSet)
+}
+
+// ZRank returns the rank of member in the sorted set stored in the bucket at given bucket and key,
+// with the scores ordered from low to high
+// Note that the rank is 1-based integer
+func (tx *Tx) ZRank(bucket string, key []byte) (int, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	rank := ss.FindRank(string(key))
+	if rank == 0 {
+		return 0, ErrNotFoundKey
+	}
+
+	return rank, nil
+}
+
+// ZRevRank returns the rank of member in the sorted set stored in the bucket at given bucket and key,
+// with the scores ordered from high to low
+// Note that the rank is 1-based integer
+func (tx *Tx) ZRevRank(bucket string, key []byte) (int, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	rank := ss.FindRank(string(key))
+	if rank == 0 {
+		return 0, ErrNotFoundKey
+	}
+
+	return ss.Size() - rank + 1, nil
+}
+
+// ZScore returns the score of member in the sorted set in the bucket at given bucket and key
+func (tx *Tx) ZScore(bucket string, key []byte) (float64, error) {
+	node, err := tx.ZGetByKey(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(node.Score()), nil
+}
+
+// ZGetByKey returns node in the bucket at given bucket and key
+func (tx *Tx) ZGetByKey(bucket string, key []byte) (*zset.SortedSetNode, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	node := ss.GetByKey(string(key))
+	if node == nil {
+		return nil, ErrNotFoundKey
+	}
+
+	return node, nil
+}
+
+// ZPeekMin returns the member with the lowest score in the sorted set stored at bucket
+// The pending writes of the tx are taken into account
+func (tx *Tx) ZPeekMin(bucket string) (*zset.SortedSetNode, error) {
+	return tx.zsetPeek(bucket, false)
+}
+
+// ZPeekMax returns the member with the highest score in the sorted set stored at bucket
+// The pending writes of the tx are taken into account
+func (tx *Tx) ZPeekMax(bucket string) (*zset.SortedSetNode, error) {
+	return tx.zsetPeek(bucket, true)
+}
+
+// zsetPeek returns the member with the lowest score, or the highest one if max is true,
+// in the sorted set stored at bucket as seen inside the tx
+func (tx *Tx) zsetPeek(bucket string, max bool) (*zset.SortedSetNode, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	node := ss.PeekMin()
+	if max {
+		node = ss.PeekMax()
+	}
+
+	if node == nil {
+		return nil, ErrNotFoundKey
+	}
+
+	return node, nil
+}
+
+// ZPopMin removes and returns the member with the lowest score in the sorted set stored at bucket
+func (tx *Tx) ZPopMin(bucket string) (*zset.SortedSetNode, error) {
+	node, err := tx.ZPeekMin(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return node, tx.put(bucket, []byte(node.Key()), nil, Persistent, DataZPopMinFlag, uint64(time.Now().Unix()), DataStructureSortedSet)
+}
+
+// ZPopMax removes and returns the member with the highest score in the sorted set stored at bucket
+func (tx *Tx) ZPopMax(bucket string) (*zset.SortedSetNode, error) {
+	node, err := tx.ZPeekMax(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return node, tx.put(bucket, []byte(node.Key()), nil, Persistent, DataZPopMaxFlag, uint64(time.Now().Unix()), DataStructureSortedSet)
+}
+
+// getSortedSet returns the sorted set stored at bucket as seen inside the tx
+func (tx *Tx) getSortedSet(bucket string) (*zset.SortedSet, error) {
+	exists, err := tx.bucketExists(DataStructureSortedSet, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrBucket
+	}
+
+	ss, err := tx.sortedSetView(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	// the bucket was only created by the tx
+	if ss == nil {
+		ss = zset.New()
+	}
+
+	return ss, nil
+}
+
+// sortedSetView returns the sorted set stored at bucket as seen inside the tx, nil if the bucket not exists
+// The committed sorted set is returned until the tx writes it, then the pending writes are applied on a copy of it,
+// the copy is kept by the tx so that only the later writes are applied at the next call
+func (tx *Tx) sortedSetView(bucket string) (*zset.SortedSet, error) {
+	v, ok := tx.sortedSets[bucket]
+	if !ok {
+		if len(tx.bucketWrites(DataStructureSortedSet, bucket, 0)) == 0 {
+			return tx.snap.SortedSetIdx[bucket], nil
+		}
+
+		v = &txView{idx: zset.New()}
+		if ss, ok := tx.snap.SortedSetIdx[bucket]; ok {
+			v.idx = ss.Clone()
+		}
+
+		if tx.sortedSets == nil {
+			tx.sortedSets = make(map[string]*txView)
+		}
+
+		tx.sortedSets[bucket] = v
+	}
+
+	for _, e := range tx.bucketWrites(DataStructureSortedSet, bucket, v.applied) {
+		switch e.Meta.Flag {
+		case DataDeleteBucketFlag:
+			v.idx = zset.New()
+		case DataCreateBucketFlag:
+		default:
+			if err := applySortedSetEntry(v.idx.(*zset.SortedSet), e); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	v.applied = len(tx.pendingWrites)
+
+	return v.idx.(*zset.SortedSet), nil
+}
+
+// encodeZSetKey returns the key of the ZAdd entry, the key with the score appended
+func encodeZSetKey(key []byte, score float64) []byte {
+	return []byte(string(key) + SeparatorForZSetKey + strconv.FormatFloat(score, 'g', -1, 64))
+}
+
+// decodeZSetKey returns the key and the score at given ZAdd entry key
+func decodeZSetKey(zKey []byte) (key string, score float64, err error) {
+	i := strings.LastIndex(string(zKey), SeparatorForZSetKey)
+	if i < 0 {
+		return "", 0, ErrZSetKey
+	}
+
+	score, err = strconv.ParseFloat(string(zKey[i+len(SeparatorForZSetKey):]), 64)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return string(zKey[:i]), score, nil
+}
+
+// ZRangeByScore returns all the elements in the sorted set at bucket with a score between min and max
+// ordered from low to high scores, the returned nodes carry their scores
+// Use math.Inf for the unbounded ranges, see zset.GetByScoreRangeOptions for the exclusive bounds and LIMIT offset count
+func (tx *Tx) ZRangeByScore(bucket string, min, max float64, opts *zset.GetByScoreRangeOptions) ([]*zset.SortedSetNode, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.ZRangeByScore(zset.SCORE(min), zset.SCORE(max), opts), nil
+}
+
+// ZRevRangeByScore returns all the elements in the sorted set at bucket with a score between max and min
+// ordered from high to low scores, the returned nodes carry their scores
+func (tx *Tx) ZRevRangeByScore(bucket string, max, min float64, opts *zset.GetByScoreRangeOptions) ([]*zset.SortedSetNode, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.ZRevRangeByScore(zset.SCORE(max), zset.SCORE(min), opts), nil
+}
+
+// ZCount returns the number of elements in the sorted set at bucket with a score between min and max
+func (tx *Tx) ZCount(bucket string, min, max float64, opts *zset.GetByScoreRangeOptions) (int, error) {
+	ss, err := tx.getSortedSet(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	return ss.ZCount(zset.SCORE(min), zset.SCORE(max), opts), nil
+}
+
+// ZRangeByLex returns all the elements in the sorted set at bucket between min and max
+// in lexicographic order of the keys, when all the elements have the same score
+// min and max are in the form of "[a", "(a", "-" or "+", see zset.ParseLexBound
+func (tx *Tx) ZRangeByLex(bucket string, min, max string, offset, limit int) ([]*zset.SortedSetNode, error) {
+	ss, minBound, maxBound, err := tx.getSortedSetAndLexBounds(bucket, min, max)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.ZRangeByLex(minBound, maxBound, offset, limit), nil
+}
+
+// ZRevRangeByLex returns all the elements in the sorted set at bucket between max and min
+// in reverse lexicographic order of the keys, when all the elements have the same score
+func (tx *Tx) ZRevRangeByLex(bucket string, max, min string, offset, limit int) ([]*zset.SortedSetNode, error) {
+	ss, minBound, maxBound, err := tx.getSortedSetAndLexBounds(bucket, min, max)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.ZRevRangeByLex(maxBound, minBound, offset, limit), nil
+}
+
+// ZLexCount returns the number of elements in the sorted set at bucket between min and max,
+// when all the elements have the same score
+func (tx *Tx) ZLexCount(bucket string, min, max string) (int, error) {
+	ss, minBound, maxBound, err := tx.getSortedSetAndLexBounds(bucket, min, max)
+	if err != nil {
+		return 0, err
+	}
+
+	return ss.ZLexCount(minBound, maxBound), nil
+}
+
+// ZRemRangeByLex removes all elements in the sorted set at bucket between min and max,
+// when all the elements have the same score
+func (tx *Tx) ZRemRangeByLex(bucket string, min, max string) error {
+	if _, _, _, err := tx.getSortedSetAndLexBounds(bucket, min, max); err != nil {
+		return err
+	}
+
+	return tx.put(bucket, []byte(min), []byte(max), Persistent, DataZRemRangeByLexFlag, uint64(time.Now().Unix()), DataStructureSortedSet)
+}
+
+// getSortedSetAndLexBounds returns the sorted set stored at bucket and the parsed lexicographic range bounds
+func (tx *Tx) getSortedSetAndLexBounds(bucket string, min, max string) (ss *zset.SortedSet, minBound, maxBound zset.LexBound, err error) {
+	if ss, err = tx.getSortedSet(bucket); err != nil {
+		return
+	}
+
+	if minBound, err = zset.ParseLexBound(min); err != nil {
+		return
+	}
+
+	maxBound, err = zset.ParseLexBound(max)
+
+	return
+}
+
+// ZUnion returns the union of the sorted sets stored at the given buckets ordered from low to high scores
+// The score of every member is the aggregation of its scores multiplied by the weights of the sets
+// If weights is nil, every weight is 1. A missing bucket is treated as an empty sorted set
+func (tx *Tx) ZUnion(buckets []string, weights []float64, aggregate zset.Aggregate) ([]*zset.SortedSetNode, error) {
+	result, err := tx.zAggregate(buckets, weights, aggregate, zset.ZUnion)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.GetByRankRange(1, -1, false), nil
+}
+
+// ZInter returns the intersection of the sorted sets stored at the given buckets ordered from low to high scores
+// The score of every member is the aggregation of its scores multiplied by the weights of the sets
+// If weights is nil, every weight is 1. A missing bucket is treated as an empty sorted set
+func (tx *Tx) ZInter(buckets []string, weights []float64, aggregate zset.Aggregate) ([]*zset.SortedSetNode, error) {
+	result, err := tx.zAggregate(buckets, weights, aggregate, zset.ZInter)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.GetByRankRange(1, -1, false), nil
+}
+
+// ZUnionStore stores the union of the sorted sets stored at the given buckets to the sorted set at dst
+// If the destination sorted set already exists, it is overwritten
+// It returns the number of the members in the resulting sorted set
+func (tx *Tx) ZUnionStore(dst string, buckets []string, weights []float64, aggregate zset.Aggregate) (int, error) {
+	result, err := tx.zAggregate(buckets, weights, aggregate, zset.ZUnion)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Size(), tx.zStore(dst, result)
+}
+
+// ZInterStore stores the intersection of the sorted sets stored at the given buckets to the sorted set at dst
+// If the destination sorted set already exists, it is overwritten
+// It returns the number of the members in the resulting sorted set
+func (tx *Tx) ZInterStore(dst string, buckets []string, weights []float64, aggregate zset.Aggregate) (int, error) {
+	result, err := tx.zAggregate(buckets, weights, aggregate, zset.ZInter)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Size(), tx.zStore(dst, result)
+}
+
+// zAggregate applies the aggregation fn to the sorted sets stored at the given buckets as seen inside the tx
+func (tx *Tx) zAggregate(buckets []string, weights []float64, aggregate zset.Aggregate,
+	fn func([]*zset.SortedSet, []float64, zset.Aggregate) (*zset.SortedSet, error)) (*zset.SortedSet, error) {
+	if err := tx.checkTxIsClosed(); err != nil {
+		return nil, err
+	}
+
+	sets := make([]*zset.SortedSet, len(buckets))
+	for i, bucket := range buckets {
+		tx.trackBucket(DataStructureSortedSet, bucket)
+
+		ss, err := tx.sortedSetView(bucket)
+		if err != nil {
+			return nil, err
+		}
+
+		sets[i] = ss
+	}
+
+	return fn(sets, weights, aggregate)
+}
+
+// zStore overwrites the sorted set stored at bucket with the members of the given sorted set
+func (tx *Tx) zStore(bucket string, result *zset.SortedSet) error {
+	tx.trackBucket(DataStructureSortedSet, bucket)
+
+	// every member is in the lexicographic range "-" "+", the members added earlier in the tx included
+	if err := tx.put(bucket, []byte("-"), []byte("+"), Persistent, DataZRemRangeByLexFlag, uint64(time.Now().Unix()), DataStructureSortedSet); err != nil {
+		return err
+	}
+
+	for _, node := range result.GetByRankRange(1, -1, false) {
+		if err := tx.ZAdd(bucket, []byte(node.Key()), float64(node.Score()), node.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
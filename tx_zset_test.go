@@ -0,0 +1,715 @@
+package nutsdb
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/HelloChenHZ/nutsdb/ds/zset"
+)
+
+// zsetKeys returns the keys of the sorted set nodes
+func zsetKeys(nodes []*zset.SortedSetNode) []string {
+	list := make([]string, len(nodes))
+	for i, node := range nodes {
+		list[i] = node.Key()
+	}
+
+	return list
+}
+
+// zaddAll adds the members with the scores 1, 2, ... to the sorted set stored at bucket
+func zaddAll(t *testing.T, db *DB, bucket string, members ...string) {
+	t.Helper()
+
+	update(t, db, func(tx *Tx) error {
+		for i, member := range members {
+			if err := tx.ZAdd(bucket, []byte(member), float64(i+1), []byte("v"+member)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// zsetAll returns the keys of all the members of the sorted set stored at bucket in order
+func zsetAll(t *testing.T, db *DB, bucket string) (list []string) {
+	t.Helper()
+
+	view(t, db, func(tx *Tx) error {
+		nodes, err := tx.ZRangeByRank(bucket, 1, -1)
+		list = zsetKeys(nodes)
+		return err
+	})
+
+	return
+}
+
+func TestTx_SortedSetCommands(t *testing.T) {
+	db := openTestDB(t)
+	zaddAll(t, db, "zs", "a", "b", "c", "d")
+
+	view(t, db, func(tx *Tx) error {
+		if n, _ := tx.ZCard("zs"); n != 4 {
+			t.Errorf("ZCard = %d, want 4", n)
+		}
+
+		if rank, _ := tx.ZRank("zs", []byte("c")); rank != 3 {
+			t.Errorf("ZRank(c) = %d, want 3", rank)
+		}
+
+		if rank, _ := tx.ZRevRank("zs", []byte("c")); rank != 2 {
+			t.Errorf("ZRevRank(c) = %d, want 2", rank)
+		}
+
+		if score, _ := tx.ZScore("zs", []byte("b")); score != 2 {
+			t.Errorf("ZScore(b) = %v, want 2", score)
+		}
+
+		if node, _ := tx.ZGetByKey("zs", []byte("d")); node == nil || string(node.Value) != "vd" {
+			t.Errorf("ZGetByKey(d) = %v", node)
+		}
+
+		if _, err := tx.ZScore("zs", []byte("missing")); err != ErrNotFoundKey {
+			t.Errorf("ZScore(missing) returned %v, want ErrNotFoundKey", err)
+		}
+
+		if _, err := tx.ZCard("missing"); err == nil {
+			t.Error("ZCard of a missing sorted set returned no error")
+		}
+
+		return nil
+	})
+}
+
+func TestTx_ZPopPending(t *testing.T) {
+	db := openTestDB(t)
+	zaddAll(t, db, "zs", "a", "b", "c", "d", "e")
+
+	update(t, db, func(tx *Tx) error {
+		// two pops in the same tx return different members
+		first, err := tx.ZPopMin("zs")
+		if err != nil {
+			return err
+		}
+
+		second, err := tx.ZPopMin("zs")
+		if err != nil {
+			return err
+		}
+
+		if first.Key() != "a" || second.Key() != "b" {
+			t.Errorf("ZPopMin twice returned %s, %s, want a, b", first.Key(), second.Key())
+		}
+
+		// a member removed in the tx is skipped
+		if err = tx.ZRem("zs", []byte("e")); err != nil {
+			return err
+		}
+
+		if node, _ := tx.ZPeekMax("zs"); node == nil || node.Key() != "d" {
+			t.Errorf("ZPeekMax after ZRem = %v, want d", node)
+		}
+
+		// a new member with the lowest score is seen
+		if err = tx.ZAdd("zs", []byte("z"), 0, nil); err != nil {
+			return err
+		}
+
+		// a rescored member moves
+		if err = tx.ZAdd("zs", []byte("c"), 100, []byte("vc2")); err != nil {
+			return err
+		}
+
+		node, err := tx.ZPopMin("zs")
+		if err != nil || node.Key() != "z" {
+			t.Errorf("ZPopMin after ZAdd = %v, %v, want z", node, err)
+		}
+
+		node, err = tx.ZPopMax("zs")
+		if err != nil || node.Key() != "c" || node.Score() != 100 || string(node.Value) != "vc2" {
+			t.Errorf("ZPopMax after rescore = %v, %v, want c", node, err)
+		}
+
+		return nil
+	})
+
+	check := func(db *DB) {
+		if got := zsetAll(t, db, "zs"); !reflect.DeepEqual(got, []string{"d"}) {
+			t.Errorf("members = %v, want [d]", got)
+		}
+	}
+
+	check(db)
+	db = reopenDB(t, db)
+	check(db)
+
+	update(t, db, func(tx *Tx) error {
+		if _, err := tx.ZPopMin("zs"); err != nil {
+			return err
+		}
+
+		if _, err := tx.ZPopMax("zs"); err != ErrNotFoundKey {
+			t.Errorf("ZPopMax of an emptied sorted set returned %v, want ErrNotFoundKey", err)
+		}
+
+		return nil
+	})
+}
+
+func TestTx_SortedSetRecovery(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+		zaddAll(t, db, "zs", "a", "b", "c", "d", "e", "f", "g", "h")
+		zaddAll(t, db, "lex", "a", "b", "c", "d")
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.ZRem("zs", []byte("c")); err != nil {
+				return err
+			}
+
+			if _, err := tx.ZPopMin("zs"); err != nil {
+				return err
+			}
+
+			if _, err := tx.ZPopMax("zs"); err != nil {
+				return err
+			}
+
+			return tx.ZRemRangeByLex("lex", "(a", "[c")
+		})
+
+		update(t, db, func(tx *Tx) error {
+			// the ranks are the ones after the previous tx: b d e f g
+			return tx.ZRemRangeByRank("zs", 2, 3)
+		})
+
+		want := zsetAll(t, db, "zs")
+		if !reflect.DeepEqual(want, []string{"b", "f", "g"}) {
+			t.Fatalf("members = %v, want [b f g]", want)
+		}
+
+		db = reopenDB(t, db)
+
+		if got := zsetAll(t, db, "zs"); !reflect.DeepEqual(got, want) {
+			t.Errorf("members after reopen = %v, want %v", got, want)
+		}
+
+		if got := zsetAll(t, db, "lex"); !reflect.DeepEqual(got, []string{"a", "d"}) {
+			t.Errorf("lex members after reopen = %v, want [a d]", got)
+		}
+
+		view(t, db, func(tx *Tx) error {
+			if node, err := tx.ZGetByKey("zs", []byte("f")); err != nil || string(node.Value) != "vf" || node.Score() != 6 {
+				t.Errorf("ZGetByKey(f) after reopen = %v, %v", node, err)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestTx_ZRem(t *testing.T) {
+	db := openTestDB(t)
+	zaddAll(t, db, "zs", "a", "b")
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.ZRem("zs", []byte("missing")); err != ErrNotFoundKey {
+			t.Errorf("ZRem of a missing member returned %v, want ErrNotFoundKey", err)
+		}
+
+		return tx.ZRem("zs", []byte("a"))
+	})
+
+	if got := zsetAll(t, db, "zs"); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("members = %v, want [b]", got)
+	}
+}
+
+func TestTx_SortedSetReadOwnWrites(t *testing.T) {
+	db := openTestDB(t)
+	zaddAll(t, db, "zs", "a", "b", "c")
+
+	update(t, db, func(tx *Tx) error {
+		// a member added in the tx is seen by all the reads
+		if err := tx.ZAdd("zs", []byte("n"), 1.5, []byte("vn")); err != nil {
+			return err
+		}
+
+		if n, _ := tx.ZCard("zs"); n != 4 {
+			t.Errorf("ZCard = %d, want 4", n)
+		}
+
+		if rank, _ := tx.ZRank("zs", []byte("n")); rank != 2 {
+			t.Errorf("ZRank(n) = %d, want 2", rank)
+		}
+
+		if rank, _ := tx.ZRevRank("zs", []byte("c")); rank != 1 {
+			t.Errorf("ZRevRank(c) = %d, want 1", rank)
+		}
+
+		if score, err := tx.ZScore("zs", []byte("n")); err != nil || score != 1.5 {
+			t.Errorf("ZScore(n) = %v, %v, want 1.5", score, err)
+		}
+
+		if nodes, _ := tx.ZRangeByScore("zs", 1, 2, nil); !reflect.DeepEqual(zsetKeys(nodes), []string{"a", "n", "b"}) {
+			t.Errorf("ZRangeByScore = %v, want [a n b]", zsetKeys(nodes))
+		}
+
+		// the ranks of a ZRemRangeByRank are the ones seen inside the tx: a n b c
+		if err := tx.ZRemRangeByRank("zs", 2, 3); err != nil {
+			return err
+		}
+
+		if nodes, _ := tx.ZRangeByRank("zs", 1, -1); !reflect.DeepEqual(zsetKeys(nodes), []string{"a", "c"}) {
+			t.Errorf("ZRangeByRank after ZRemRangeByRank = %v, want [a c]", zsetKeys(nodes))
+		}
+
+		if err := tx.ZRem("zs", []byte("b")); err != ErrNotFoundKey {
+			t.Errorf("ZRem of a removed member returned %v, want ErrNotFoundKey", err)
+		}
+
+		// a member added and removed in the tx
+		if err := tx.ZAdd("zs", []byte("m"), 9, nil); err != nil {
+			return err
+		}
+
+		if err := tx.ZRem("zs", []byte("m")); err != nil {
+			t.Errorf("ZRem of a member added in the tx returned %v", err)
+		}
+
+		if _, err := tx.ZScore("zs", []byte("m")); err != ErrNotFoundKey {
+			t.Errorf("ZScore of a removed member returned %v, want ErrNotFoundKey", err)
+		}
+
+		// a sorted set created in the tx
+		if err := tx.ZAdd("new", []byte("x"), 1, nil); err != nil {
+			return err
+		}
+
+		if n, err := tx.ZCard("new"); err != nil || n != 1 {
+			t.Errorf("ZCard of a new sorted set = %d, %v, want 1", n, err)
+		}
+
+		// the members removed with the bucket
+		if err := tx.DeleteBucket(DataStructureSortedSet, "zs"); err != nil {
+			return err
+		}
+
+		if _, err := tx.ZCard("zs"); err != ErrBucket {
+			t.Errorf("ZCard of a deleted bucket returned %v, want ErrBucket", err)
+		}
+
+		if err := tx.ZAdd("zs", []byte("d"), 4, nil); err != nil {
+			return err
+		}
+
+		if nodes, _ := tx.ZRangeByRank("zs", 1, -1); !reflect.DeepEqual(zsetKeys(nodes), []string{"d"}) {
+			t.Errorf("ZRangeByRank after DeleteBucket = %v, want [d]", zsetKeys(nodes))
+		}
+
+		return nil
+	})
+
+	if got := zsetAll(t, db, "zs"); !reflect.DeepEqual(got, []string{"d"}) {
+		t.Errorf("members after commit = %v, want [d]", got)
+	}
+}
+
+func TestTx_ZRangeByScore(t *testing.T) {
+	db := openTestDB(t)
+	zaddAll(t, db, "zs", "a", "b", "c", "d", "e")
+
+	view(t, db, func(tx *Tx) error {
+		nodes, err := tx.ZRangeByScore("zs", 2, math.Inf(1), &zset.GetByScoreRangeOptions{ExcludeStart: true, Limit: 2})
+		if err != nil {
+			return err
+		}
+
+		if got := zsetKeys(nodes); !reflect.DeepEqual(got, []string{"c", "d"}) {
+			t.Errorf("ZRangeByScore = %v, want [c d]", got)
+		}
+
+		if nodes, _ = tx.ZRevRangeByScore("zs", 4, math.Inf(-1), nil); !reflect.DeepEqual(zsetKeys(nodes), []string{"d", "c", "b", "a"}) {
+			t.Errorf("ZRevRangeByScore = %v, want [d c b a]", zsetKeys(nodes))
+		}
+
+		if n, _ := tx.ZCount("zs", 2, 4, &zset.GetByScoreRangeOptions{ExcludeEnd: true}); n != 2 {
+			t.Errorf("ZCount = %d, want 2", n)
+		}
+
+		if _, err = tx.ZRangeByScore("missing", 0, 1, nil); err != ErrBucket {
+			t.Errorf("ZRangeByScore of a missing sorted set returned %v, want ErrBucket", err)
+		}
+
+		return nil
+	})
+}
+
+func TestTx_ZRangeByLex(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+
+		update(t, db, func(tx *Tx) error {
+			for _, member := range []string{"apple", "banana", "cherry", "date", "fig"} {
+				if err := tx.ZAdd("lex", []byte(member), 0, nil); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		view(t, db, func(tx *Tx) error {
+			nodes, err := tx.ZRangeByLex("lex", "[b", "(d", 0, 0)
+			if err != nil {
+				return err
+			}
+
+			if got := zsetKeys(nodes); !reflect.DeepEqual(got, []string{"banana", "cherry"}) {
+				t.Errorf("ZRangeByLex = %v, want [banana cherry]", got)
+			}
+
+			if nodes, _ = tx.ZRevRangeByLex("lex", "+", "-", 0, 2); !reflect.DeepEqual(zsetKeys(nodes), []string{"fig", "date"}) {
+				t.Errorf("ZRevRangeByLex = %v, want [fig date]", zsetKeys(nodes))
+			}
+
+			if n, _ := tx.ZLexCount("lex", "(apple", "+"); n != 4 {
+				t.Errorf("ZLexCount = %d, want 4", n)
+			}
+
+			if _, err = tx.ZRangeByLex("lex", "b", "+", 0, 0); err != zset.ErrLexBound {
+				t.Errorf("ZRangeByLex with a malformed bound returned %v, want ErrLexBound", err)
+			}
+
+			return nil
+		})
+
+		update(t, db, func(tx *Tx) error {
+			if err := tx.ZRemRangeByLex("lex", "[banana", "[date"); err != nil {
+				return err
+			}
+
+			// the removed member is not seen by the tx anymore, so it is added again with the increment as its score
+			score, err := tx.ZIncrBy("lex", []byte("cherry"), 1)
+			if err != nil || score != 1 {
+				t.Errorf("ZIncrBy of a removed member = %v, %v, want 1", score, err)
+			}
+
+			return err
+		})
+
+		db = reopenDB(t, db)
+
+		if got := zsetAll(t, db, "lex"); !reflect.DeepEqual(got, []string{"apple", "fig", "cherry"}) {
+			t.Errorf("members after reopen = %v, want [apple fig cherry]", got)
+		}
+	})
+}
+
+func TestTx_ZAddWithOptions(t *testing.T) {
+	db := openTestDB(t)
+	zaddAll(t, db, "zs", "a", "b")
+
+	update(t, db, func(tx *Tx) error {
+		if n, err := tx.ZAddWithOptions("zs", []byte("a"), 10, nil, &zset.ZAddOptions{GT: true, CH: true}); err != nil || n != 1 {
+			t.Errorf("ZAddWithOptions GT = %d, %v, want 1", n, err)
+		}
+
+		// the pending score 10 is the current score
+		if n, err := tx.ZAddWithOptions("zs", []byte("a"), 5, nil, &zset.ZAddOptions{GT: true, CH: true}); err != nil || n != 0 {
+			t.Errorf("ZAddWithOptions GT lower = %d, %v, want 0", n, err)
+		}
+
+		if n, err := tx.ZAddWithOptions("zs", []byte("c"), 3, nil, &zset.ZAddOptions{XX: true}); err != nil || n != 0 {
+			t.Errorf("ZAddWithOptions XX new = %d, %v, want 0", n, err)
+		}
+
+		if n, err := tx.ZAddWithOptions("zs", []byte("b"), 3, nil, &zset.ZAddOptions{NX: true}); err != nil || n != 0 {
+			t.Errorf("ZAddWithOptions NX existing = %d, %v, want 0", n, err)
+		}
+
+		if _, err := tx.ZAddWithOptions("zs", []byte("b"), 3, nil, &zset.ZAddOptions{NX: true, XX: true}); err != zset.ErrZAddOptions {
+			t.Errorf("ZAddWithOptions NX XX returned %v, want ErrZAddOptions", err)
+		}
+
+		return nil
+	})
+
+	view(t, db, func(tx *Tx) error {
+		if score, _ := tx.ZScore("zs", []byte("a")); score != 10 {
+			t.Errorf("ZScore(a) = %v, want 10", score)
+		}
+
+		if _, err := tx.ZScore("zs", []byte("c")); err != ErrNotFoundKey {
+			t.Errorf("ZScore(c) returned %v, want ErrNotFoundKey", err)
+		}
+
+		return nil
+	})
+}
+
+func TestTx_ZIncrByNaN(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		if err := tx.ZAdd("zs", []byte("a"), math.NaN(), nil); err != zset.ErrScoreNaN {
+			t.Errorf("ZAdd with a NaN score returned %v, want ErrScoreNaN", err)
+		}
+
+		if _, err := tx.ZAddWithOptions("zs", []byte("a"), math.NaN(), nil, nil); err != zset.ErrScoreNaN {
+			t.Errorf("ZAddWithOptions with a NaN score returned %v, want ErrScoreNaN", err)
+		}
+
+		if _, err := tx.ZIncrBy("zs", []byte("a"), math.NaN()); err != zset.ErrScoreNaN {
+			t.Errorf("ZIncrBy with a NaN increment returned %v, want ErrScoreNaN", err)
+		}
+
+		if err := tx.ZAdd("zs", []byte("inf"), math.Inf(1), nil); err != nil {
+			return err
+		}
+
+		if _, err := tx.ZIncrBy("zs", []byte("inf"), math.Inf(-1)); err != zset.ErrScoreNaN {
+			t.Errorf("ZIncrBy to NaN returned %v, want ErrScoreNaN", err)
+		}
+
+		return nil
+	})
+
+	db = reopenDB(t, db)
+
+	view(t, db, func(tx *Tx) error {
+		if score, err := tx.ZScore("zs", []byte("inf")); err != nil || !math.IsInf(score, 1) {
+			t.Errorf("ZScore(inf) = %v, %v, want +Inf", score, err)
+		}
+
+		return nil
+	})
+}
+
+func TestTx_ZIncrByConcurrent(t *testing.T) {
+	db := openTestDB(t)
+
+	update(t, db, func(tx *Tx) error {
+		if _, err := tx.ZIncrBy("zs", []byte("a"), 1); err != nil {
+			return err
+		}
+
+		// the pending increment is taken into account
+		score, err := tx.ZIncrBy("zs", []byte("a"), 1)
+		if score != 2 {
+			t.Errorf("ZIncrBy twice = %v, want 2", score)
+		}
+
+		return err
+	})
+
+	// the conflicting increments are retried, so none of them is lost
+	const workers, increments = 4, 25
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < increments; j++ {
+				err := db.UpdateWithRetry(1000, func(tx *Tx) error {
+					_, err := tx.ZIncrBy("zs", []byte("a"), 1)
+					return err
+				})
+
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	db = reopenDB(t, db)
+
+	view(t, db, func(tx *Tx) error {
+		if score, _ := tx.ZScore("zs", []byte("a")); score != 2+workers*increments {
+			t.Errorf("ZScore(a) = %v, want %d", score, 2+workers*increments)
+		}
+
+		return nil
+	})
+}
+
+func TestTx_ZAggregateStore(t *testing.T) {
+	forEachIdxMode(t, func(t *testing.T, opt Options) {
+		db := openDB(t, opt)
+		zaddAll(t, db, "daily", "a", "b", "c")
+		zaddAll(t, db, "dst", "old")
+
+		update(t, db, func(tx *Tx) error {
+			for i, member := range []string{"b", "c", "d"} {
+				if err := tx.ZAdd("weekly", []byte(member), float64(10*(i+1)), nil); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		update(t, db, func(tx *Tx) error {
+			nodes, err := tx.ZUnion([]string{"daily", "weekly", "missing"}, []float64{1, 10, 1}, zset.AggregateSum)
+			if err != nil {
+				return err
+			}
+
+			// daily {a:1 b:2 c:3}, weekly {b:10 c:20 d:30}
+			if got := zsetKeys(nodes); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+				t.Errorf("ZUnion = %v, want [a b c d]", got)
+			}
+
+			// the member added earlier in the tx is overwritten as well
+			if err = tx.ZAdd("dst", []byte("pending"), 0, nil); err != nil {
+				return err
+			}
+
+			n, err := tx.ZInterStore("dst", []string{"daily", "weekly"}, nil, zset.AggregateMax)
+			if err != nil || n != 2 {
+				t.Errorf("ZInterStore = %d, %v, want 2", n, err)
+			}
+
+			if node, err := tx.ZPeekMin("dst"); err != nil || node.Key() != "b" || node.Score() != 10 {
+				t.Errorf("ZPeekMin(dst) = %v, %v, want b 10", node, err)
+			}
+
+			// as well as the member of a sorted set created in the tx
+			if err = tx.ZAdd("fresh", []byte("pending"), 0, nil); err != nil {
+				return err
+			}
+
+			if n, err = tx.ZInterStore("fresh", []string{"daily", "weekly"}, nil, zset.AggregateMax); err != nil || n != 2 {
+				t.Errorf("ZInterStore(fresh) = %d, %v, want 2", n, err)
+			}
+
+			if err = tx.ZAdd("empty", []byte("x"), 0, nil); err != nil {
+				return err
+			}
+
+			n, err = tx.ZInterStore("empty", []string{"daily", "missing"}, nil, zset.AggregateSum)
+			if err != nil || n != 0 {
+				t.Errorf("ZInterStore with a missing set = %d, %v, want 0", n, err)
+			}
+
+			n, err = tx.ZUnionStore("union", []string{"daily", "weekly"}, []float64{2, 1}, zset.AggregateMin)
+			if err != nil || n != 4 {
+				t.Errorf("ZUnionStore = %d, %v, want 4", n, err)
+			}
+
+			if _, err = tx.ZUnionStore("union", []string{"daily"}, []float64{1, 2}, zset.AggregateSum); err != zset.ErrWeights {
+				t.Errorf("ZUnionStore with a wrong number of weights returned %v, want ErrWeights", err)
+			}
+
+			return nil
+		})
+
+		check := func(db *DB) {
+			view(t, db, func(tx *Tx) error {
+				nodes, err := tx.ZRangeByRank("dst", 1, -1)
+				if err != nil {
+					return err
+				}
+
+				if got := zsetKeys(nodes); !reflect.DeepEqual(got, []string{"b", "c"}) || nodes[1].Score() != 20 {
+					t.Errorf("dst = %v, want [b c] with the max scores", got)
+				}
+
+				if n, _ := tx.ZCard("empty"); n != 0 {
+					t.Errorf("ZCard(empty) = %d, want 0", n)
+				}
+
+				// daily*2 {a:2 b:4 c:6}, weekly {b:10 c:20 d:30}
+				nodes, err = tx.ZRangeByRank("union", 1, -1)
+				if err != nil {
+					return err
+				}
+
+				want := []float64{2, 4, 6, 30}
+				for i, node := range nodes {
+					if float64(node.Score()) != want[i] {
+						t.Errorf("union[%d] = %s %v, want %v", i, node.Key(), node.Score(), want[i])
+					}
+				}
+
+				return nil
+			})
+
+			if got := zsetAll(t, db, "fresh"); !reflect.DeepEqual(got, []string{"b", "c"}) {
+				t.Errorf("fresh = %v, want [b c]", got)
+			}
+		}
+
+		check(db)
+		db = reopenDB(t, db)
+		check(db)
+	})
+}
+
+func TestTx_ZAggregatePending(t *testing.T) {
+	db := openTestDB(t)
+	zaddAll(t, db, "s1", "a", "b")
+	zaddAll(t, db, "s2", "b", "c")
+
+	update(t, db, func(tx *Tx) error {
+		// s1 {a:1 c:5}, s2 {b:1 c:2 d:7}
+		if err := tx.ZRem("s1", []byte("b")); err != nil {
+			return err
+		}
+
+		if err := tx.ZAdd("s1", []byte("c"), 5, nil); err != nil {
+			return err
+		}
+
+		if err := tx.ZAdd("s2", []byte("d"), 7, nil); err != nil {
+			return err
+		}
+
+		nodes, err := tx.ZUnion([]string{"s1", "s2"}, nil, zset.AggregateSum)
+		if err != nil {
+			return err
+		}
+
+		if got := zsetKeys(nodes); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) || nodes[2].Score() != 7 {
+			t.Errorf("ZUnion = %v, want [a b c d] with c 7", got)
+		}
+
+		nodes, err = tx.ZInter([]string{"s1", "s2"}, nil, zset.AggregateMax)
+		if err != nil {
+			return err
+		}
+
+		if got := zsetKeys(nodes); !reflect.DeepEqual(got, []string{"c"}) || nodes[0].Score() != 5 {
+			t.Errorf("ZInter = %v, want [c] with c 5", got)
+		}
+
+		// a source created in the tx
+		if err = tx.ZAdd("s3", []byte("a"), 1, nil); err != nil {
+			return err
+		}
+
+		if n, err := tx.ZInterStore("dst", []string{"s1", "s3"}, nil, zset.AggregateSum); err != nil || n != 1 {
+			t.Errorf("ZInterStore = %d, %v, want 1", n, err)
+		}
+
+		// the destination is a source as well
+		if n, err := tx.ZUnionStore("dst", []string{"dst", "s2"}, nil, zset.AggregateSum); err != nil || n != 4 {
+			t.Errorf("ZUnionStore = %d, %v, want 4", n, err)
+		}
+
+		return nil
+	})
+
+	if got := zsetAll(t, db, "dst"); !reflect.DeepEqual(got, []string{"b", "a", "c", "d"}) {
+		t.Errorf("dst = %v, want [b a c d]", got)
+	}
+}